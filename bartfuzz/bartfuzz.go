@@ -0,0 +1,160 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+// Package bartfuzz decodes fuzzer input into a deterministic sequence
+// of table operations, to standardize fuzzing of [bart.Table] and to
+// reproduce failing corpus entries attached to issues.
+//
+// The input is a sequence of ops, each starting with an op byte:
+//
+//	op&3 == 0, 3:  insert, followed by bits byte and address octets
+//	op&3 == 1:     delete, followed by bits byte and address octets
+//	op&3 == 2:     lookup, followed by address octets
+//
+// Bit 2 of the op byte selects IPv6 (16 address octets) over IPv4 (4 octets).
+// The prefix length is the bits byte modulo the maximum length plus one,
+// the prefix is masked. An inserted value is the index of its op.
+// A truncated trailing op is ignored.
+package bartfuzz
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/metacubex/bart"
+)
+
+const (
+	opInsert = iota
+	opDelete
+	opLookup
+)
+
+// op is a decoded table operation.
+type op struct {
+	kind int
+	pfx  netip.Prefix // insert and delete
+	ip   netip.Addr   // lookup
+	val  int          // insert
+}
+
+// BuildFromFuzz interprets data as a sequence of insert, delete and lookup ops
+// and returns the resulting table. Equal data always builds an equal table.
+func BuildFromFuzz(data []byte) *bart.Table[int] {
+	tbl := new(bart.Table[int])
+
+	for _, o := range decode(data) {
+		switch o.kind {
+		case opInsert:
+			tbl.Insert(o.pfx, o.val)
+		case opDelete:
+			tbl.Delete(o.pfx)
+		case opLookup:
+			tbl.Lookup(o.ip)
+		}
+	}
+
+	return tbl
+}
+
+// CheckFromFuzz replays the ops from data against a table and a simple,
+// slow reference implementation, like the gold table comparison in the
+// tests of package bart. It returns an error for the first divergence.
+//
+// Every lookup is compared, after each insert and delete also
+// the exact match and the table size.
+func CheckFromFuzz(data []byte) error {
+	tbl := new(bart.Table[int])
+	gold := make(map[netip.Prefix]int)
+
+	for i, o := range decode(data) {
+		switch o.kind {
+		case opInsert:
+			tbl.Insert(o.pfx, o.val)
+			gold[o.pfx] = o.val
+
+		case opDelete:
+			tbl.Delete(o.pfx)
+			delete(gold, o.pfx)
+
+		case opLookup:
+			gotVal, gotOK := tbl.Lookup(o.ip)
+			wantVal, wantOK := goldLookup(gold, o.ip)
+
+			if gotOK != wantOK || gotVal != wantVal {
+				return fmt.Errorf("bartfuzz: op %d, Lookup(%s) = (%d, %v), want (%d, %v)",
+					i, o.ip, gotVal, gotOK, wantVal, wantOK)
+			}
+			continue
+		}
+
+		gotVal, gotOK := tbl.Get(o.pfx)
+		wantVal, wantOK := gold[o.pfx]
+
+		if gotOK != wantOK || gotVal != wantVal {
+			return fmt.Errorf("bartfuzz: op %d, Get(%s) = (%d, %v), want (%d, %v)",
+				i, o.pfx, gotVal, gotOK, wantVal, wantOK)
+		}
+
+		if got, want := tbl.Size(), len(gold); got != want {
+			return fmt.Errorf("bartfuzz: op %d, Size() = %d, want %d", i, got, want)
+		}
+	}
+
+	return nil
+}
+
+// goldLookup, longest prefix match by linear search.
+func goldLookup(gold map[netip.Prefix]int, ip netip.Addr) (val int, ok bool) {
+	bits := -1
+	for pfx, v := range gold {
+		if pfx.Bits() > bits && pfx.Contains(ip) {
+			bits = pfx.Bits()
+			val = v
+			ok = true
+		}
+	}
+	return val, ok
+}
+
+// decode the data into ops, a truncated trailing op is dropped.
+func decode(data []byte) (ops []op) {
+	for i := 0; len(data) > 0; i++ {
+		code := data[0]
+		data = data[1:]
+
+		kind := int(code & 3)
+		if kind == 3 {
+			kind = opInsert
+		}
+
+		addrLen, maxBits := 4, 32
+		if code&4 != 0 {
+			addrLen, maxBits = 16, 128
+		}
+
+		var bits int
+		if kind != opLookup {
+			if len(data) < 1 {
+				return ops
+			}
+			bits = int(data[0]) % (maxBits + 1)
+			data = data[1:]
+		}
+
+		if len(data) < addrLen {
+			return ops
+		}
+		ip, _ := netip.AddrFromSlice(data[:addrLen])
+		data = data[addrLen:]
+
+		o := op{kind: kind, ip: ip, val: i}
+		if kind != opLookup {
+			o.pfx = netip.PrefixFrom(ip, bits).Masked()
+		}
+
+		ops = append(ops, o)
+	}
+
+	return ops
+}
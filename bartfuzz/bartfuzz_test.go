@@ -0,0 +1,68 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bartfuzz_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/metacubex/bart/bartfuzz"
+)
+
+func TestBuildFromFuzzDeterministic(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	data := make([]byte, 100_000)
+	prng.Read(data)
+
+	a := bartfuzz.BuildFromFuzz(data)
+	b := bartfuzz.BuildFromFuzz(data)
+
+	if a.Size() == 0 {
+		t.Fatalf("BuildFromFuzz, expected a non-empty table")
+	}
+
+	if a.String() != b.String() {
+		t.Errorf("BuildFromFuzz isn't deterministic")
+	}
+
+	if err := bartfuzz.CheckFromFuzz(data); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBuildFromFuzzTruncated(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data []byte
+		size int
+	}{
+		{"nil", nil, 0},
+		{"op only", []byte{0}, 0},
+		{"short addr", []byte{0, 8, 10, 0}, 0},
+		{"insert v4", []byte{0, 8, 10, 1, 2, 3}, 1},
+		{"insert v4, truncated delete", []byte{0, 8, 10, 1, 2, 3, 1, 8, 10}, 1},
+		{"insert and delete v4", []byte{0, 8, 10, 1, 2, 3, 1, 8, 10, 0, 0, 0}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := bartfuzz.BuildFromFuzz(tt.data).Size(); got != tt.size {
+			t.Errorf("%s: Size() = %d, want %d", tt.name, got, tt.size)
+		}
+	}
+}
+
+func FuzzTable(f *testing.F) {
+	f.Add([]byte{0, 8, 10, 1, 2, 3, 2, 10, 1, 1, 1})
+	f.Add([]byte{4, 32, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := bartfuzz.CheckFromFuzz(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
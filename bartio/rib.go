@@ -0,0 +1,198 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+// Package bartio parses routing table text dumps into routes,
+// ready to be inserted into a [bart.Table].
+//
+// Supported are the common RIB formats of Cisco IOS
+//
+//	      10.0.0.0/8 is variably subnetted, 3 subnets, 2 masks
+//	S        10.0.0.0/8 [1/0] via 192.168.1.1
+//	O        10.1.0.0/16 [110/2] via 10.0.0.1, 00:00:12, GigabitEthernet0/0
+//	                     [110/2] via 10.0.0.2, 00:00:12, GigabitEthernet0/1
+//	C        10.2.0.0/24 is directly connected, GigabitEthernet0/2
+//	S   2001:DB8::/32 [1/0]
+//	     via 2001:DB8:1::1
+//
+// and of Juniper Junos
+//
+//	10.0.0.0/8         *[Static/5] 00:01:02
+//	                    >  to 192.168.1.1 via ge-0/0/0.0
+//
+// Prefixes are recognized in CIDR notation or, below a Cisco
+// "is subnetted" header, as address with the mask of the header.
+// Cisco next hops follow "via", ECMP continuation lines add a route per
+// next hop. Junos next hops follow "to" and are only taken from the
+// selected next hop (">") of the active route ("*").
+// Directly connected routes have no next hop.
+// Lines not matching these patterns, e.g. legends and headers, are skipped.
+package bartio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// Route is a prefix with its next hop, parsed from a RIB dump.
+// The prefix is masked, NextHop is the zero Addr for connected routes.
+type Route struct {
+	Pfx     netip.Prefix
+	NextHop netip.Addr
+}
+
+// ParseRIB parses the routes of a Cisco IOS or Junos RIB text dump,
+// see the package documentation for the supported formats.
+// The routes are returned in input order.
+//
+// An error is returned for read errors and for a next hop
+// without a preceding prefix.
+func ParseRIB(r io.Reader) ([]Route, error) {
+	var routes []Route
+
+	// index of the current route in routes, -1 for none
+	cur := -1
+
+	// the current route already has a next hop, more hops add routes
+	hasHop := false
+
+	// the current Junos route entry is active
+	active := true
+
+	// mask bits of the last Cisco "is subnetted" header, -1 for none
+	mask := -1
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		line := scanner.Text()
+
+		// Cisco classful header, the subnets may follow without mask
+		if strings.Contains(line, "subnetted") {
+			mask = -1
+			if !strings.Contains(line, "variably") {
+				if pfx, err := netip.ParsePrefix(fields[0]); err == nil {
+					mask = pfx.Bits()
+				}
+			}
+			continue
+		}
+
+		// Cisco legend and gateway of last resort
+		if strings.HasPrefix(line, "Codes:") || strings.HasPrefix(line, "Gateway") {
+			continue
+		}
+
+		// the Junos selected next hop marker
+		selected := false
+
+		// the prefix must be in the leading fields, before any keyword
+		pfxDone := false
+
+		for i := 0; i < len(fields); i++ {
+			tok := strings.TrimRight(fields[i], ",")
+
+			switch {
+			case tok == ">":
+				selected = true
+				continue
+
+			case strings.HasPrefix(tok, "*["):
+				active = true
+				pfxDone = true
+				continue
+
+			case strings.HasPrefix(tok, "["):
+				active = false
+				pfxDone = true
+				continue
+
+			case tok == "is":
+				pfxDone = true
+				continue
+
+			case tok == "via" || tok == "to":
+				pfxDone = true
+				if i+1 >= len(fields) {
+					continue
+				}
+
+				// Cisco "via <addr>" or Junos "to <addr>", Junos "via <iface>" is skipped
+				hop, err := netip.ParseAddr(strings.TrimRight(fields[i+1], ","))
+				if err != nil {
+					continue
+				}
+				i++
+
+				if tok == "to" && !(selected && active) {
+					continue
+				}
+
+				if cur < 0 {
+					return nil, fmt.Errorf("bartio: line %d: next hop %s without prefix", lineNum, hop)
+				}
+
+				if hasHop {
+					routes = append(routes, Route{Pfx: routes[cur].Pfx, NextHop: hop})
+					continue
+				}
+
+				routes[cur].NextHop = hop
+				hasHop = true
+				continue
+			}
+
+			if pfxDone {
+				continue
+			}
+
+			pfx, ok := parsePrefix(tok, mask)
+			if !ok {
+				continue
+			}
+
+			routes = append(routes, Route{Pfx: pfx})
+			cur = len(routes) - 1
+			hasHop = false
+			active = true
+			pfxDone = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("bartio: %w", err)
+	}
+
+	return routes, nil
+}
+
+// parsePrefix parses tok in CIDR notation or, with a mask from a Cisco
+// "is subnetted" header, as bare address. The prefix is masked.
+func parsePrefix(tok string, mask int) (netip.Prefix, bool) {
+	if pfx, err := netip.ParsePrefix(tok); err == nil {
+		return pfx.Masked(), true
+	}
+
+	if mask < 0 {
+		return netip.Prefix{}, false
+	}
+
+	// classful subnetting is IPv4 only
+	ip, err := netip.ParseAddr(tok)
+	if err != nil || !ip.Is4() {
+		return netip.Prefix{}, false
+	}
+
+	pfx, err := ip.Prefix(mask)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+
+	return pfx, true
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bartio_test
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/metacubex/bart"
+	"github.com/metacubex/bart/bartio"
+)
+
+var (
+	mpa = netip.MustParseAddr
+	mpp = netip.MustParsePrefix
+)
+
+const ciscoRIB = `Codes: L - local, C - connected, S - static, R - RIP, M - mobile, B - BGP
+       D - EIGRP, EX - EIGRP external, O - OSPF, IA - OSPF inter area
+
+Gateway of last resort is 192.168.1.1 to network 0.0.0.0
+
+S*    0.0.0.0/0 [1/0] via 192.168.1.1
+      10.0.0.0/8 is variably subnetted, 3 subnets, 2 masks
+S        10.0.0.0/8 [1/0] via 192.168.1.1
+O IA     10.1.0.0/16 [110/2] via 10.0.0.1, 00:00:12, GigabitEthernet0/0
+                     [110/2] via 10.0.0.2, 00:00:12, GigabitEthernet0/1
+C        10.2.0.0/24 is directly connected, GigabitEthernet0/2
+      172.16.0.0/24 is subnetted, 2 subnets
+B        172.16.1.0 [20/0] via 203.0.113.1, 1w2d
+C        172.16.2.0 is directly connected, GigabitEthernet0/3
+`
+
+const ciscoRIB6 = `IPv6 Routing Table - default - 4 entries
+Codes: C - Connected, L - Local, S - Static, U - Per-user Static route
+S   2001:DB8::/32 [1/0]
+     via 2001:DB8:1::1
+C   2001:DB8:1::/64 [0/0]
+     via GigabitEthernet0/0, directly connected
+L   2001:DB8:1::2/128 [0/0]
+     via GigabitEthernet0/0, receive
+`
+
+const junosRIB = `inet.0: 4 destinations, 5 routes (4 active, 0 holddown, 0 hidden)
++ = Active Route, - = Last Active, * = Both
+
+0.0.0.0/0          *[Static/5] 2w0d 01:02:03
+                    >  to 192.168.1.1 via ge-0/0/0.0
+10.1.0.0/16        *[OSPF/10] 00:10:00, metric 2
+                    >  to 10.0.0.1 via ge-0/0/1.0
+                       to 10.0.0.2 via ge-0/0/2.0
+                    [Static/20] 00:01:02
+                    >  to 10.0.0.9 via ge-0/0/3.0
+192.168.1.0/24     *[Direct/0] 2w0d 01:02:03
+                    >  via ge-0/0/0.0
+2001:db8::/32      *[Static/5] 1d 00:00:01
+                    >  to fe80::1 via ge-0/0/0.0
+`
+
+func TestParseRIB(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		dump string
+		want []bartio.Route
+	}{
+		{
+			name: "cisco",
+			dump: ciscoRIB,
+			want: []bartio.Route{
+				{mpp("0.0.0.0/0"), mpa("192.168.1.1")},
+				{mpp("10.0.0.0/8"), mpa("192.168.1.1")},
+				{mpp("10.1.0.0/16"), mpa("10.0.0.1")},
+				{mpp("10.1.0.0/16"), mpa("10.0.0.2")},
+				{mpp("10.2.0.0/24"), netip.Addr{}},
+				{mpp("172.16.1.0/24"), mpa("203.0.113.1")},
+				{mpp("172.16.2.0/24"), netip.Addr{}},
+			},
+		},
+		{
+			name: "cisco ipv6",
+			dump: ciscoRIB6,
+			want: []bartio.Route{
+				{mpp("2001:db8::/32"), mpa("2001:db8:1::1")},
+				{mpp("2001:db8:1::/64"), netip.Addr{}},
+				{mpp("2001:db8:1::2/128"), netip.Addr{}},
+			},
+		},
+		{
+			name: "junos",
+			dump: junosRIB,
+			want: []bartio.Route{
+				{mpp("0.0.0.0/0"), mpa("192.168.1.1")},
+				{mpp("10.1.0.0/16"), mpa("10.0.0.1")},
+				{mpp("192.168.1.0/24"), netip.Addr{}},
+				{mpp("2001:db8::/32"), mpa("fe80::1")},
+			},
+		},
+		{
+			name: "empty",
+			dump: "",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := bartio.ParseRIB(strings.NewReader(tt.dump))
+		if err != nil {
+			t.Fatalf("%s: ParseRIB, unexpected error: %v", tt.name, err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseRIB\ngot:  %v\nwant: %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRIBError(t *testing.T) {
+	t.Parallel()
+
+	_, err := bartio.ParseRIB(strings.NewReader("    [1/0] via 10.0.0.1\n"))
+	if err == nil {
+		t.Errorf("ParseRIB, next hop without prefix, expected error")
+	}
+}
+
+func TestParseRIBInsert(t *testing.T) {
+	t.Parallel()
+
+	routes, err := bartio.ParseRIB(strings.NewReader(ciscoRIB))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tbl := new(bart.Table[netip.Addr])
+	for _, r := range routes {
+		tbl.Insert(r.Pfx, r.NextHop)
+	}
+
+	if got, _ := tbl.Lookup(mpa("172.16.1.99")); got != mpa("203.0.113.1") {
+		t.Errorf("Lookup(172.16.1.99) = %s, want 203.0.113.1", got)
+	}
+
+	if got, _ := tbl.Lookup(mpa("8.8.8.8")); got != mpa("192.168.1.1") {
+		t.Errorf("Lookup(8.8.8.8) = %s, want 192.168.1.1", got)
+	}
+}
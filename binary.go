@@ -0,0 +1,430 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"github.com/metacubex/bart/internal/bitset"
+)
+
+// The binary trie format, written by [Table.WriteBinary] and read back
+// by [Table.ReadBinary]. It's a pre-order serialization of the nodes,
+// all integers are little endian:
+//
+//	table:  magic "BART" | version uint8 | size4 uint64 | size6 uint64 | node(root4) | node(root6)
+//
+//	node:   prefixes [4]uint64 | value ... (one per bit set in prefixes, ascending)
+//	        children [4]uint64 | child ... (one per bit set in children, ascending)
+//
+//	child:  kind uint8 | payload
+//	        kind 0, node:   node
+//	        kind 1, leaf:   bits uint8 | addr [4]byte or [16]byte | value
+//	        kind 2, fringe: value
+//
+//	value:  len uint32 | enc(val) [len]byte
+//
+// The trie is written as is, no prefix is reinserted when reading it back.
+// A reader must reject unknown versions.
+const (
+	binaryMagic   = "BART"
+	binaryVersion = 1
+)
+
+// child kinds in the binary trie format
+const (
+	binaryKindNode byte = iota
+	binaryKindLeaf
+	binaryKindFringe
+)
+
+// ErrBinaryFormat is returned by ReadBinary for malformed input.
+var ErrBinaryFormat = errors.New("bart: invalid binary format")
+
+// WriteBinary serializes the table node-by-node in a versioned
+// binary trie format to w. The values are encoded with enc,
+// enc may be nil if V carries no data, e.g. for struct{}.
+//
+// It returns the number of bytes written and the first write error, if any.
+//
+// The trie structure is preserved, reading it back with [Table.ReadBinary]
+// is much faster than reinserting all prefixes, e.g. for a fast startup
+// with a full Internet routing table.
+//
+// The method isn't named WriteTo, since the signature with the value encoder
+// doesn't match the [io.WriterTo] interface.
+func (t *Table[V]) WriteBinary(w io.Writer, enc func(V) []byte) (int64, error) {
+	if enc == nil {
+		enc = func(V) []byte { return nil }
+	}
+
+	bw := &binaryWriter{w: w}
+
+	bw.write([]byte(binaryMagic))
+	bw.write([]byte{binaryVersion})
+	bw.uint64(uint64(t.size4))
+	bw.uint64(uint64(t.size6))
+
+	t.root4.writeBinaryRec(bw, true, enc)
+	t.root6.writeBinaryRec(bw, false, enc)
+
+	return bw.n, bw.err
+}
+
+// writeBinaryRec, write the node and all descendants in pre-order.
+func (n *node[V]) writeBinaryRec(bw *binaryWriter, is4 bool, enc func(V) []byte) {
+	bw.bitset(&n.prefixes.BitSet256)
+	for _, val := range n.prefixes.Items {
+		bw.value(enc(val))
+	}
+
+	bw.bitset(&n.children.BitSet256)
+	for _, kidAny := range n.children.Items {
+		switch kid := kidAny.(type) {
+		case *node[V]:
+			bw.kind(binaryKindNode)
+			kid.writeBinaryRec(bw, is4, enc)
+
+		case *leafNode[V]:
+			bw.leaf(kid.prefix, is4)
+			bw.value(enc(kid.value))
+
+		case *fringeNode[V]:
+			bw.kind(binaryKindFringe)
+			bw.value(enc(kid.value))
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+}
+
+// ReadBinary replaces the content of the receiver with the table read from r,
+// serialized by [Table.WriteBinary]. The values are decoded with dec,
+// dec may be nil if V carries no data, e.g. for struct{}.
+// The buffer passed to dec is reused, dec must copy the data
+// if it wishes to retain it.
+//
+// It returns the number of bytes read and an error, if any.
+// The receiver is only modified if the whole table could be read.
+//
+// The method isn't named ReadFrom, since the signature with the value decoder
+// doesn't match the [io.ReaderFrom] interface.
+func (t *Table[V]) ReadBinary(r io.Reader, dec func([]byte) (V, error)) (int64, error) {
+	if dec == nil {
+		dec = func([]byte) (val V, err error) { return }
+	}
+
+	// intern while decoding, a rewrite afterwards would be reported to the observer
+	if t.intern != nil {
+		decode := dec
+		dec = func(buf []byte) (V, error) {
+			val, err := decode(buf)
+			return t.internVal(val), err
+		}
+	}
+
+	br := &binaryReader{r: r}
+
+	magic := make([]byte, len(binaryMagic)+1)
+	if br.read(magic); br.err != nil {
+		return br.n, br.err
+	}
+
+	if string(magic[:len(binaryMagic)]) != binaryMagic {
+		return br.n, fmt.Errorf("%w: wrong magic %q", ErrBinaryFormat, magic[:len(binaryMagic)])
+	}
+
+	if version := magic[len(binaryMagic)]; version != binaryVersion {
+		return br.n, fmt.Errorf("%w: unsupported version %d", ErrBinaryFormat, version)
+	}
+
+	size4 := br.uint64()
+	size6 := br.uint64()
+
+	var root4, root6 node[V]
+
+	count4 := root4.readBinaryRec(br, stridePath{}, 0, true, dec)
+	count6 := root6.readBinaryRec(br, stridePath{}, 0, false, dec)
+
+	if br.err != nil {
+		return br.n, br.err
+	}
+
+	if uint64(count4) != size4 || uint64(count6) != size6 {
+		return br.n, fmt.Errorf("%w: size mismatch, got (%d, %d), want (%d, %d)",
+			ErrBinaryFormat, count4, count6, size4, size6)
+	}
+
+	t.gen++
+	t.root4 = root4
+	t.root6 = root6
+	t.shared.Store(false)
+	t.sources = nil
+	t.size4 = count4
+	t.size6 = count6
+	t.missFilterRebuild()
+
+	return br.n, nil
+}
+
+// readBinaryRec, read the node and all descendants in pre-order,
+// returns the number of prefixes read.
+//
+// The leaves are checked against their storage location like in
+// validateRec, a leaf must match the path and the child slot.
+func (n *node[V]) readBinaryRec(br *binaryReader, path stridePath, depth int, is4 bool, dec func([]byte) (V, error)) (count int) {
+	// only IPv6 nodes may be 16 levels deep
+	if (is4 && depth >= 4) || depth >= maxTreeDepth {
+		br.fail(fmt.Errorf("%w: node too deep at depth %d", ErrBinaryFormat, depth))
+		return
+	}
+
+	pfxBits := br.bitset()
+	idxs := pfxBits.AsSlice(&[256]uint8{})
+
+	// build the sparse arrays in place, the indices are ascending
+	n.prefixes.BitSet256 = pfxBits
+	n.prefixes.Items = make([]V, 0, len(idxs))
+
+	for _, idx := range idxs {
+		// idx 0 is no valid baseIndex
+		if idx == 0 {
+			br.fail(fmt.Errorf("%w: invalid prefix index 0", ErrBinaryFormat))
+			return
+		}
+
+		val := readBinaryValue(br, dec)
+		if br.err != nil {
+			return
+		}
+
+		n.prefixes.Items = append(n.prefixes.Items, val)
+		count++
+	}
+
+	kidBits := br.bitset()
+	addrs := kidBits.AsSlice(&[256]uint8{})
+
+	n.children.BitSet256 = kidBits
+	n.children.Items = make([]any, 0, len(addrs))
+
+	for _, addr := range addrs {
+		kind := br.scratch[:1]
+		if br.read(kind); br.err != nil {
+			return
+		}
+
+		switch kind[0] {
+		case binaryKindNode:
+			kid := new(node[V])
+			path[depth] = addr
+			count += kid.readBinaryRec(br, path, depth+1, is4, dec)
+			n.children.Items = append(n.children.Items, kid)
+
+			// subtries must not be empty
+			if br.err == nil && kid.isEmpty() {
+				br.fail(fmt.Errorf("%w: empty node at depth %d", ErrBinaryFormat, depth+1))
+			}
+
+		case binaryKindLeaf:
+			pfx := br.prefix(is4)
+			if br.err != nil {
+				return
+			}
+
+			// the leaf must belong to this child slot
+			octets := pfx.Addr().AsSlice()
+			if string(octets[:depth]) != string(path[:depth]) || octets[depth] != addr {
+				br.fail(fmt.Errorf("%w: leaf %s in wrong slot at depth %d", ErrBinaryFormat, pfx, depth))
+				return
+			}
+
+			// leaves have more bits than the fringe at this depth
+			if pfx.Bits() <= (depth+1)<<3 {
+				br.fail(fmt.Errorf("%w: invalid leaf prefix length %d at depth %d", ErrBinaryFormat, pfx.Bits(), depth))
+				return
+			}
+
+			val := readBinaryValue(br, dec)
+			n.children.Items = append(n.children.Items, newLeafNode(pfx, val))
+			count++
+
+		case binaryKindFringe:
+			val := readBinaryValue(br, dec)
+			n.children.Items = append(n.children.Items, newFringeNode(val))
+			count++
+
+		default:
+			br.fail(fmt.Errorf("%w: unknown child kind %d", ErrBinaryFormat, kind[0]))
+		}
+
+		if br.err != nil {
+			return
+		}
+	}
+
+	return count
+}
+
+// binaryWriter, helper type with sticky error and byte counter.
+type binaryWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+
+	// reused for all fixed size fields
+	scratch [18]byte
+}
+
+func (bw *binaryWriter) write(buf []byte) {
+	if bw.err != nil {
+		return
+	}
+
+	n, err := bw.w.Write(buf)
+	bw.n += int64(n)
+	bw.err = err
+}
+
+func (bw *binaryWriter) uint64(u uint64) {
+	binary.LittleEndian.PutUint64(bw.scratch[:8], u)
+	bw.write(bw.scratch[:8])
+}
+
+func (bw *binaryWriter) kind(kind byte) {
+	bw.scratch[0] = kind
+	bw.write(bw.scratch[:1])
+}
+
+func (bw *binaryWriter) leaf(pfx netip.Prefix, is4 bool) {
+	buf := append(bw.scratch[:0], binaryKindLeaf, byte(pfx.Bits()))
+	if is4 {
+		a4 := pfx.Addr().As4()
+		buf = append(buf, a4[:]...)
+	} else {
+		a16 := pfx.Addr().As16()
+		buf = append(buf, a16[:]...)
+	}
+	bw.write(buf)
+}
+
+func (bw *binaryWriter) bitset(bs *bitset.BitSet256) {
+	for _, word := range bs {
+		bw.uint64(word)
+	}
+}
+
+func (bw *binaryWriter) value(buf []byte) {
+	binary.LittleEndian.PutUint32(bw.scratch[:4], uint32(len(buf)))
+	bw.write(bw.scratch[:4])
+	bw.write(buf)
+}
+
+// binaryReader, helper type with sticky error and byte counter.
+type binaryReader struct {
+	r   io.Reader
+	n   int64
+	err error
+
+	// reused for all fixed size fields and small values
+	scratch [maxTrustedValueLen]byte
+}
+
+func (br *binaryReader) fail(err error) {
+	if br.err == nil {
+		br.err = err
+	}
+}
+
+func (br *binaryReader) read(buf []byte) {
+	if br.err != nil {
+		return
+	}
+
+	n, err := io.ReadFull(br.r, buf)
+	br.n += int64(n)
+
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	br.err = err
+}
+
+func (br *binaryReader) uint64() uint64 {
+	buf := br.scratch[:8]
+	br.read(buf)
+	return binary.LittleEndian.Uint64(buf)
+}
+
+func (br *binaryReader) bitset() (bs bitset.BitSet256) {
+	for i := range bs {
+		bs[i] = br.uint64()
+	}
+	return bs
+}
+
+func (br *binaryReader) prefix(is4 bool) netip.Prefix {
+	buf := br.scratch[:17]
+	if is4 {
+		buf = br.scratch[:5]
+	}
+
+	br.read(buf)
+	if br.err != nil {
+		return netip.Prefix{}
+	}
+
+	ip, _ := netip.AddrFromSlice(buf[1:])
+	pfx := netip.PrefixFrom(ip, int(buf[0]))
+	if !pfx.IsValid() || pfx != pfx.Masked() {
+		br.fail(fmt.Errorf("%w: invalid leaf prefix %s", ErrBinaryFormat, pfx))
+	}
+
+	return pfx
+}
+
+// maxTrustedValueLen, values up to this length are read into the scratch buffer.
+const maxTrustedValueLen = 4096
+
+// readBinaryValue, read and decode a value, generic helper function,
+// methods can't have type parameters.
+func readBinaryValue[V any](br *binaryReader, dec func([]byte) (V, error)) (val V) {
+	lenBuf := br.scratch[:4]
+	br.read(lenBuf)
+	if br.err != nil {
+		return
+	}
+
+	size := int64(binary.LittleEndian.Uint32(lenBuf))
+
+	var buf []byte
+	if size <= maxTrustedValueLen {
+		buf = br.scratch[:size]
+		br.read(buf)
+	} else {
+		// don't trust big lengths, the buffer grows only with the data read
+		var err error
+		buf, err = io.ReadAll(io.LimitReader(br.r, size))
+		br.n += int64(len(buf))
+
+		if err == nil && int64(len(buf)) != size {
+			err = io.ErrUnexpectedEOF
+		}
+		br.fail(err)
+	}
+
+	if br.err != nil {
+		return
+	}
+
+	val, err := dec(buf)
+	br.fail(err)
+
+	return val
+}
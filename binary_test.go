@@ -0,0 +1,403 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func encInt(val int) []byte {
+	return binary.LittleEndian.AppendUint64(nil, uint64(val))
+}
+
+func decInt(buf []byte) (int, error) {
+	if len(buf) != 8 {
+		return 0, errors.New("wrong length")
+	}
+	return int(binary.LittleEndian.Uint64(buf)), nil
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for _, item := range randomPrefixes(prng, 10_000) {
+		tbl.Insert(item.pfx, item.val)
+	}
+
+	buf := new(bytes.Buffer)
+	nw, err := tbl.WriteBinary(buf, encInt)
+	if err != nil {
+		t.Fatalf("WriteBinary: %v", err)
+	}
+
+	if nw != int64(buf.Len()) {
+		t.Errorf("WriteBinary, n: %d, want: %d", nw, buf.Len())
+	}
+
+	got := new(Table[int])
+	nr, err := got.ReadBinary(buf, decInt)
+	if err != nil {
+		t.Fatalf("ReadBinary: %v", err)
+	}
+
+	if nr != nw {
+		t.Errorf("ReadBinary, n: %d, want: %d", nr, nw)
+	}
+
+	if got.Size4() != tbl.Size4() || got.Size6() != tbl.Size6() {
+		t.Errorf("ReadBinary, sizes: (%d, %d), want: (%d, %d)", got.Size4(), got.Size6(), tbl.Size4(), tbl.Size6())
+	}
+
+	if got.dumpString() != tbl.dumpString() {
+		t.Fatalf("ReadBinary, trie structure differs")
+	}
+
+	want := tbl.dumpAsGoldTable()
+	for i, item := range got.dumpAsGoldTable() {
+		if item != want[i] {
+			t.Fatalf("ReadBinary, items[%d] = %v, want %v", i, item, want[i])
+		}
+	}
+}
+
+func TestBinaryEmptyLite(t *testing.T) {
+	t.Parallel()
+
+	lt := new(Lite)
+
+	buf := new(bytes.Buffer)
+	if _, err := lt.WriteBinary(buf, nil); err != nil {
+		t.Fatalf("WriteBinary: %v", err)
+	}
+
+	lt.Insert(mpp("10.0.0.0/8"))
+	if _, err := lt.ReadBinary(buf, nil); err != nil {
+		t.Fatalf("ReadBinary: %v", err)
+	}
+
+	if lt.Size() != 0 {
+		t.Errorf("ReadBinary, receiver not replaced, Size() = %d", lt.Size())
+	}
+}
+
+func TestBinaryInvalid(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Insert(mpp("10.1.2.3/32"), 2)
+	tbl.Insert(mpp("2001:db8::/32"), 3)
+
+	buf := new(bytes.Buffer)
+	if _, err := tbl.WriteBinary(buf, encInt); err != nil {
+		t.Fatalf("WriteBinary: %v", err)
+	}
+	data := buf.Bytes()
+
+	t.Run("wrong magic", func(t *testing.T) {
+		t.Parallel()
+		bad := append([]byte("TRAB"), data[4:]...)
+
+		_, err := new(Table[int]).ReadBinary(bytes.NewReader(bad), decInt)
+		if !errors.Is(err, ErrBinaryFormat) {
+			t.Errorf("ReadBinary, err: %v, want: %v", err, ErrBinaryFormat)
+		}
+	})
+
+	t.Run("wrong version", func(t *testing.T) {
+		t.Parallel()
+		bad := append([]byte(nil), data...)
+		bad[len(binaryMagic)] = binaryVersion + 1
+
+		_, err := new(Table[int]).ReadBinary(bytes.NewReader(bad), decInt)
+		if !errors.Is(err, ErrBinaryFormat) {
+			t.Errorf("ReadBinary, err: %v, want: %v", err, ErrBinaryFormat)
+		}
+	})
+
+	t.Run("wrong size", func(t *testing.T) {
+		t.Parallel()
+		bad := append([]byte(nil), data...)
+		bad[len(binaryMagic)+1]++
+
+		_, err := new(Table[int]).ReadBinary(bytes.NewReader(bad), decInt)
+		if !errors.Is(err, ErrBinaryFormat) {
+			t.Errorf("ReadBinary, err: %v, want: %v", err, ErrBinaryFormat)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		t.Parallel()
+		for i := 0; i < len(data); i++ {
+			tbl := new(Table[int])
+			_, err := tbl.ReadBinary(bytes.NewReader(data[:i]), decInt)
+			if !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, ErrBinaryFormat) {
+				t.Fatalf("ReadBinary(data[:%d]), err: %v, want: %v", i, err, io.ErrUnexpectedEOF)
+			}
+			if tbl.Size() != 0 {
+				t.Fatalf("ReadBinary(data[:%d]), receiver modified", i)
+			}
+		}
+	})
+
+	t.Run("misplaced leaf", func(t *testing.T) {
+		t.Parallel()
+
+		// the leaf 10.1.2.3/32 is stored below the path 10, in the slot 1
+		leaf := bytes.Index(data, []byte{32, 10, 1, 2, 3})
+		if leaf < 0 {
+			t.Fatal("leaf 10.1.2.3/32 not found in the serialized table")
+		}
+
+		tests := []struct {
+			name  string
+			patch []byte
+		}{
+			{"wrong path", []byte{32, 11, 1, 2, 3}},
+			{"wrong slot", []byte{32, 10, 2, 2, 3}},
+			{"fringe as leaf", []byte{16, 10, 1, 0, 0}},
+			{"prefix as leaf", []byte{12, 10, 0, 0, 0}},
+		}
+
+		for _, tt := range tests {
+			bad := append([]byte(nil), data...)
+			copy(bad[leaf:], tt.patch)
+
+			tbl := new(Table[int])
+			_, err := tbl.ReadBinary(bytes.NewReader(bad), decInt)
+			if !errors.Is(err, ErrBinaryFormat) {
+				t.Errorf("%s: ReadBinary, err: %v, want: %v", tt.name, err, ErrBinaryFormat)
+			}
+			if tbl.Size() != 0 {
+				t.Errorf("%s: ReadBinary, receiver modified", tt.name)
+			}
+		}
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		t.Parallel()
+
+		// corrupt every byte, an accepted table must be valid
+		for i := len(binaryMagic) + 1; i < len(data); i++ {
+			for _, b := range []byte{0, 1, 2, 0x7f, 0xfe, 0xff} {
+				bad := append([]byte(nil), data...)
+				bad[i] = b
+
+				got := new(Table[int])
+				if _, err := got.ReadBinary(bytes.NewReader(bad), decInt); err == nil {
+					if verr := got.Validate(); verr != nil {
+						t.Fatalf("ReadBinary, byte %d set to %d, invalid table accepted: %v", i, b, verr)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("decoder error", func(t *testing.T) {
+		t.Parallel()
+		errDec := errors.New("decoder error")
+
+		_, err := new(Table[int]).ReadBinary(bytes.NewReader(data), func([]byte) (int, error) { return 0, errDec })
+		if !errors.Is(err, errDec) {
+			t.Errorf("ReadBinary, err: %v, want: %v", err, errDec)
+		}
+	})
+}
+
+func BenchmarkFullTableReadBinary(b *testing.B) {
+	tbl := new(Table[int])
+	for i, route := range routes {
+		tbl.Insert(route.CIDR, i)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := tbl.WriteBinary(buf, encInt); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	b.Run("ReadBinary", func(b *testing.B) {
+		for j := 0; j < b.N; j++ {
+			rt := new(Table[int])
+			_, _ = rt.ReadBinary(bytes.NewReader(data), decInt)
+		}
+	})
+
+	b.Run("Insert", func(b *testing.B) {
+		for j := 0; j < b.N; j++ {
+			rt := new(Table[int])
+			for i, route := range routes {
+				rt.Insert(route.CIDR, i)
+			}
+		}
+	})
+}
+
+func TestLiteBinaryRoundTrip(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	for _, n := range []int{0, 1, 10, 10_000} {
+		lt := new(Lite)
+		for _, item := range randomPrefixes(prng, n) {
+			lt.Insert(item.pfx)
+		}
+
+		buf := new(bytes.Buffer)
+		nw, err := lt.WriteTo(buf)
+		if err != nil {
+			t.Fatalf("WriteTo: %v", err)
+		}
+		if nw != int64(buf.Len()) {
+			t.Errorf("WriteTo, n: %d, want: %d", nw, buf.Len())
+		}
+
+		got := new(Lite)
+		got.Insert(mpp("10.0.0.0/8"))
+
+		nr, err := got.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("ReadFrom: %v", err)
+		}
+		if nr != nw {
+			t.Errorf("ReadFrom, n: %d, want: %d", nr, nw)
+		}
+
+		if !got.Equal(lt) {
+			t.Fatalf("ReadFrom, %d prefixes, not equal", n)
+		}
+		if got.dumpString() != lt.dumpString() {
+			t.Fatalf("ReadFrom, %d prefixes, trie structure differs", n)
+		}
+		if err := got.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLiteBinarySize(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	lt := new(Lite)
+	for _, pfx := range randomRealWorldPrefixes(prng, 10_000) {
+		lt.Insert(pfx)
+	}
+
+	liteBuf := new(bytes.Buffer)
+	if _, err := lt.WriteTo(liteBuf); err != nil {
+		t.Fatal(err)
+	}
+
+	tblBuf := new(bytes.Buffer)
+	if _, err := lt.WriteBinary(tblBuf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if liteBuf.Len()*2 > tblBuf.Len() {
+		t.Errorf("WriteTo, %d bytes, want less than half of WriteBinary, %d bytes", liteBuf.Len(), tblBuf.Len())
+	}
+}
+
+func TestLiteBinaryInvalid(t *testing.T) {
+	t.Parallel()
+
+	lt := new(Lite)
+	lt.Insert(mpp("10.0.0.0/8"))
+	lt.Insert(mpp("10.1.2.3/32"))
+	lt.Insert(mpp("10.1.0.0/17"))
+	lt.Insert(mpp("2001:db8::/32"))
+
+	buf := new(bytes.Buffer)
+	if _, err := lt.WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	data := buf.Bytes()
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"wrong magic", append([]byte("BART"), data[4:]...)},
+		{"wrong version", append(append([]byte(liteBinaryMagic), liteBinaryVersion+1), data[5:]...)},
+		{"table format", func() []byte {
+			b := new(bytes.Buffer)
+			_, _ = lt.WriteBinary(b, nil)
+			return b.Bytes()
+		}()},
+		{"wrong size", func() []byte {
+			bad := append([]byte(nil), data...)
+			bad[len(liteBinaryMagic)+1]++
+			return bad
+		}()},
+		{"empty node", func() []byte {
+			bad := append([]byte(liteBinaryMagic), liteBinaryVersion)
+			bad = append(bad, make([]byte, 16)...)          // size4, size6
+			bad = append(bad, 0, 1, 10, liteKindNode, 0, 0) // root4 with an empty child node
+			return append(bad, 0, 0)                        // empty root6
+		}()},
+	}
+
+	for _, tt := range tests {
+		_, err := new(Lite).ReadFrom(bytes.NewReader(tt.data))
+		if !errors.Is(err, ErrBinaryFormat) {
+			t.Errorf("%s: ReadFrom, err: %v, want: %v", tt.name, err, ErrBinaryFormat)
+		}
+	}
+
+	for i := 0; i < len(data); i++ {
+		got := new(Lite)
+		_, err := got.ReadFrom(bytes.NewReader(data[:i]))
+		if !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, ErrBinaryFormat) {
+			t.Fatalf("ReadFrom(data[:%d]), err: %v, want: %v", i, err, io.ErrUnexpectedEOF)
+		}
+		if got.Size() != 0 {
+			t.Fatalf("ReadFrom(data[:%d]), receiver modified", i)
+		}
+	}
+
+	// corrupt every byte, the reader must never panic
+	for i := len(liteBinaryMagic) + 1; i < len(data); i++ {
+		for _, b := range []byte{0, 1, 2, 0x7f, 0xfe, 0xff} {
+			bad := append([]byte(nil), data...)
+			bad[i] = b
+
+			got := new(Lite)
+			if _, err := got.ReadFrom(bytes.NewReader(bad)); err == nil {
+				if verr := got.Validate(); verr != nil {
+					t.Fatalf("ReadFrom, byte %d set to %d, invalid table accepted: %v", i, b, verr)
+				}
+			}
+		}
+	}
+}
+
+func TestLiteEqual(t *testing.T) {
+	t.Parallel()
+
+	a := new(Lite)
+	b := new(Lite)
+	var n *Lite
+
+	if !a.Equal(b) || !a.Equal(n) || !n.Equal(a) || !n.Equal(n) {
+		t.Errorf("Equal, empty tables must be equal")
+	}
+
+	a.Insert(mpp("10.0.0.0/8"))
+	if a.Equal(b) || a.Equal(n) || n.Equal(a) {
+		t.Errorf("Equal, different tables must not be equal")
+	}
+
+	b.Insert(mpp("10.0.0.0/8"))
+	if !a.Equal(b) {
+		t.Errorf("Equal, same prefixes must be equal")
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+// Package bitset exports the fixed-length bitset for the range [0..255]
+// used internally by the routing trie, for building sibling structures
+// on top of it.
+//
+// The following methods of [BitSet256] are a stable, public API:
+//
+//	Set, Clear, Test, IsEmpty, Count, Rank,
+//	FirstSet, NextSet, LastSet, AsSlice, Bits,
+//	Intersects, Intersection, IntersectionTop, Union
+package bitset
+
+import "github.com/metacubex/bart/internal/bitset"
+
+// BitSet256 represents a fixed size bitset from [0..255].
+//
+// The zero value is an empty set, ready to use.
+type BitSet256 = bitset.BitSet256
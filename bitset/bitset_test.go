@@ -0,0 +1,43 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bitset_test
+
+import (
+	"testing"
+
+	"github.com/metacubex/bart/bitset"
+)
+
+func TestPublicAPI(t *testing.T) {
+	t.Parallel()
+
+	var b bitset.BitSet256
+	b.Set(3)
+	b.Set(5)
+	b.Set(120)
+
+	if got := b.Count(); got != 3 {
+		t.Errorf("Count, want 3, got %d", got)
+	}
+
+	if got := b.Rank(119); got != 2 {
+		t.Errorf("Rank(119), want 2, got %d", got)
+	}
+
+	if next, ok := b.NextSet(6); !ok || next != 120 {
+		t.Errorf("NextSet(6), want (120, true), got (%d, %v)", next, ok)
+	}
+
+	var c bitset.BitSet256
+	c.Set(5)
+
+	if !b.Intersects(&c) {
+		t.Errorf("Intersects, want true")
+	}
+
+	is := b.Intersection(&c)
+	if got := is.Bits(); len(got) != 1 || got[0] != 5 {
+		t.Errorf("Intersection, want [5], got %v", got)
+	}
+}
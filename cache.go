@@ -0,0 +1,106 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"container/list"
+	"net/netip"
+)
+
+// CachedTable memoizes the results of [Table.Lookup] for the most
+// recently used addresses in an LRU cache, created by [Table.WithCache].
+//
+// It can only be a win under high address locality, e.g. if most lookups
+// hit a few thousand hot addresses. Measure it for your workload, the trie
+// walk of Lookup is already fast: with the hot part of the trie in the CPU
+// cache, a plain Lookup is about twice as fast as the map probe and the LRU
+// update, see BenchmarkCachedTableHot. For random addresses the cache
+// maintenance is pure overhead.
+//
+// Any modification of the underlying table flushes the whole cache,
+// detected by a modification counter of the table on the next Lookup.
+// Values modified in place via [Table.GetPtr] after the call
+// aren't detected.
+//
+// A CachedTable is not safe for concurrent use, not even for
+// concurrent lookups, since every Lookup updates the LRU order.
+type CachedTable[V any] struct {
+	t    *Table[V]
+	gen  uint64
+	size int
+
+	// LRU list, most recently used in front, with index by address
+	lru   *list.List
+	items map[netip.Addr]*list.Element
+}
+
+// cacheEntry is a memoized lookup result, misses are cached too.
+type cacheEntry[V any] struct {
+	ip  netip.Addr
+	val V
+	ok  bool
+}
+
+// WithCache returns a lookup cache for the table, holding
+// the results for at most size addresses, see [CachedTable].
+// If size is less than 1, nothing is cached.
+func (t *Table[V]) WithCache(size int) *CachedTable[V] {
+	return &CachedTable[V]{
+		t:     t,
+		gen:   t.gen,
+		size:  size,
+		lru:   list.New(),
+		items: make(map[netip.Addr]*list.Element),
+	}
+}
+
+// Table returns the underlying table.
+func (c *CachedTable[V]) Table() *Table[V] {
+	return c.t
+}
+
+// Len returns the number of cached lookup results.
+func (c *CachedTable[V]) Len() int {
+	return c.lru.Len()
+}
+
+// Lookup is like [Table.Lookup], but the result is served from the
+// cache if ip was looked up recently and the table wasn't modified since.
+func (c *CachedTable[V]) Lookup(ip netip.Addr) (val V, ok bool) {
+	if c.size < 1 {
+		return c.t.Lookup(ip)
+	}
+
+	// table modified, flush the cache
+	if c.gen != c.t.gen {
+		c.gen = c.t.gen
+		c.lru.Init()
+		c.items = make(map[netip.Addr]*list.Element, c.size)
+	}
+
+	if elem, exists := c.items[ip]; exists {
+		c.lru.MoveToFront(elem)
+		e := elem.Value.(*cacheEntry[V])
+		return e.val, e.ok
+	}
+
+	val, ok = c.t.Lookup(ip)
+
+	// cache full, recycle the least recently used entry
+	if c.lru.Len() >= c.size {
+		elem := c.lru.Back()
+		e := elem.Value.(*cacheEntry[V])
+		delete(c.items, e.ip)
+
+		e.ip, e.val, e.ok = ip, val, ok
+		c.lru.MoveToFront(elem)
+		c.items[ip] = elem
+
+		return val, ok
+	}
+
+	c.items[ip] = c.lru.PushFront(&cacheEntry[V]{ip: ip, val: val, ok: ok})
+
+	return val, ok
+}
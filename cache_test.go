@@ -0,0 +1,187 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"bytes"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+func TestCachedTableCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for _, pfx := range randomPrefixes(prng, 1_000) {
+		tbl.Insert(pfx.pfx, pfx.val)
+	}
+
+	// a small hot set, the cache is full most of the time
+	hot := make([]netip.Addr, 200)
+	for i := range hot {
+		hot[i] = randomAddr(prng)
+	}
+
+	c := tbl.WithCache(100)
+
+	for i := 0; i < 20_000; i++ {
+		// interleave modifications, they must flush the cache
+		switch i % 1_000 {
+		case 100:
+			pfx := randomPrefixes(prng, 1)[0]
+			tbl.Insert(pfx.pfx, pfx.val)
+		case 200:
+			pfx := randomPrefixes(prng, 1)[0]
+			tbl.Delete(pfx.pfx)
+		case 300:
+			tbl.Insert(netip.PrefixFrom(hot[0], hot[0].BitLen()), -1)
+		case 400:
+			tbl.Delete(netip.PrefixFrom(hot[0], hot[0].BitLen()))
+		}
+
+		ip := hot[prng.Intn(len(hot))]
+
+		gotVal, gotOK := c.Lookup(ip)
+		wantVal, wantOK := tbl.Lookup(ip)
+
+		if gotVal != wantVal || gotOK != wantOK {
+			t.Fatalf("step %d, CachedTable.Lookup(%s) = (%d, %v), want (%d, %v)",
+				i, ip, gotVal, gotOK, wantVal, wantOK)
+		}
+
+		if c.Len() > 100 {
+			t.Fatalf("step %d, CachedTable.Len() = %d, want <= 100", i, c.Len())
+		}
+	}
+}
+
+func TestCachedTableFlush(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+
+	c := tbl.WithCache(10)
+	ip := mpa("10.1.1.1")
+
+	if val, ok := c.Lookup(ip); val != 1 || !ok {
+		t.Fatalf("Lookup(%s) = (%d, %v), want (1, true)", ip, val, ok)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", c.Len())
+	}
+
+	tests := []struct {
+		name   string
+		modify func()
+		val    int
+		ok     bool
+	}{
+		{"Insert", func() { tbl.Insert(mpp("10.1.0.0/16"), 2) }, 2, true},
+		{"Update", func() { tbl.Update(mpp("10.1.0.0/16"), func(int, bool) int { return 3 }) }, 3, true},
+		{"SetAll", func() { tbl.SetAll(4) }, 4, true},
+		{"Delete", func() { tbl.Delete(mpp("10.1.0.0/16")) }, 4, true},
+		{"Union", func() {
+			o := new(Table[int])
+			o.Insert(mpp("10.1.1.0/24"), 5)
+			tbl.Union(o)
+		}, 5, true},
+		{"ReadBinary", func() {
+			o := new(Table[int])
+			var buf bytes.Buffer
+			if _, err := o.WriteBinary(&buf, nil); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tbl.ReadBinary(&buf, nil); err != nil {
+				t.Fatal(err)
+			}
+		}, 0, false},
+	}
+
+	for _, tt := range tests {
+		tt.modify()
+		if val, ok := c.Lookup(ip); val != tt.val || ok != tt.ok {
+			t.Errorf("%s: Lookup(%s) = (%d, %v), want (%d, %v)", tt.name, ip, val, ok, tt.val, tt.ok)
+		}
+		if c.Len() != 1 {
+			t.Errorf("%s: Len() = %d, want 1", tt.name, c.Len())
+		}
+	}
+}
+
+func TestCachedTableDisabled(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("::/0"), 1)
+
+	c := tbl.WithCache(0)
+	if val, ok := c.Lookup(mpa("::1")); val != 1 || !ok {
+		t.Errorf("Lookup(::1) = (%d, %v), want (1, true)", val, ok)
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", c.Len())
+	}
+	if c.Table() != tbl {
+		t.Errorf("Table() returned a different table")
+	}
+}
+
+func BenchmarkCachedTableHot(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for _, pfx := range randomRealWorldPrefixes(prng, 100_000) {
+		tbl.Insert(pfx, 0)
+	}
+
+	hot := make([]netip.Addr, 1_000)
+	for i := range hot {
+		hot[i] = randomAddr(prng)
+	}
+	c := tbl.WithCache(len(hot))
+
+	b.Run("Lookup", func(b *testing.B) {
+		for j := 0; j < b.N; j++ {
+			_, boolSink = tbl.Lookup(hot[j%len(hot)])
+		}
+	})
+
+	b.Run("CachedLookup", func(b *testing.B) {
+		for j := 0; j < b.N; j++ {
+			_, boolSink = c.Lookup(hot[j%len(hot)])
+		}
+	})
+}
+
+func TestCachedTableFallback(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+
+	c := tbl.WithCache(10)
+	ip := mpa("11.1.1.1")
+
+	tests := []struct {
+		name   string
+		modify func()
+		val    int
+		ok     bool
+	}{
+		{"none", func() {}, 0, false},
+		{"SetFallback", func() { tbl.SetFallback(4, 6) }, 4, false},
+		{"WithFallbackMatches", func() { tbl.WithFallbackMatches(true) }, 4, true},
+		{"ClearFallback", func() { tbl.ClearFallback() }, 0, false},
+	}
+
+	for _, tt := range tests {
+		tt.modify()
+		if val, ok := c.Lookup(ip); val != tt.val || ok != tt.ok {
+			t.Errorf("%s: Lookup(%s) = (%d, %v), want (%d, %v)", tt.name, ip, val, ok, tt.val, tt.ok)
+		}
+	}
+}
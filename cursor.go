@@ -0,0 +1,92 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import "net/netip"
+
+// Cursor is a read-only position at an internal node of the trie,
+// for custom structural traversals beyond the prefix iterators.
+//
+// A Cursor is only valid as long as the table isn't modified.
+type Cursor[V any] struct {
+	n     *node[V]
+	is4   bool
+	path  stridePath
+	depth int
+}
+
+// Root4 returns a Cursor at the root node of the IPv4 trie.
+func (t *Table[V]) Root4() Cursor[V] {
+	return Cursor[V]{n: &t.root4, is4: true}
+}
+
+// Root6 returns a Cursor at the root node of the IPv6 trie.
+func (t *Table[V]) Root6() Cursor[V] {
+	return Cursor[V]{n: &t.root6, is4: false}
+}
+
+// Depth returns the depth of the node in the trie, the root node is at depth 0.
+// Every depth corresponds to one octet (stride) of the address.
+func (c Cursor[V]) Depth() int {
+	return c.depth
+}
+
+// Children returns an iterator over the inner child nodes, as octet and Cursor,
+// in ascending order of the octet.
+//
+// Path-compressed children aren't nodes, they are yielded
+// by [Cursor.Prefixes] of this node.
+func (c Cursor[V]) Children() func(yield func(byte, Cursor[V]) bool) {
+	return func(yield func(byte, Cursor[V]) bool) {
+		if c.n == nil {
+			return
+		}
+
+		for i, addr := range c.n.children.AsSlice(&[256]uint8{}) {
+			kid, ok := c.n.children.Items[i].(*node[V])
+			if !ok {
+				continue
+			}
+
+			path := c.path
+			path[c.depth] = addr
+
+			if !yield(addr, Cursor[V]{n: kid, is4: c.is4, path: path, depth: c.depth + 1}) {
+				return
+			}
+		}
+	}
+}
+
+// Prefixes returns an iterator over the prefixes and values stored at this node.
+//
+// First the prefixes within the stride of this node are yielded, then
+// the path-compressed prefixes (leaves and fringes) in the child slots.
+// The prefixes of the inner child nodes are not included.
+func (c Cursor[V]) Prefixes() func(yield func(netip.Prefix, V) bool) {
+	return func(yield func(netip.Prefix, V) bool) {
+		if c.n == nil {
+			return
+		}
+
+		for i, idx := range c.n.prefixes.AsSlice(&[256]uint8{}) {
+			if !yield(cidrFromPath(c.path, c.depth, c.is4, idx), c.n.prefixes.Items[i]) {
+				return
+			}
+		}
+
+		for i, addr := range c.n.children.AsSlice(&[256]uint8{}) {
+			switch kid := c.n.children.Items[i].(type) {
+			case *leafNode[V]:
+				if !yield(kid.prefix, kid.value) {
+					return
+				}
+			case *fringeNode[V]:
+				if !yield(cidrForFringe(c.path[:], c.depth, c.is4, addr), kid.value) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+func TestCursorWalk(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for _, pfx := range randomPrefixes(prng, 10_000) {
+		tbl.Insert(pfx.pfx, pfx.val)
+	}
+
+	seen := make(map[netip.Prefix]int, tbl.Size())
+
+	var walk func(c Cursor[int], depth int)
+	walk = func(c Cursor[int], depth int) {
+		if c.Depth() != depth {
+			t.Fatalf("Cursor.Depth, want %d, got %d", depth, c.Depth())
+		}
+
+		c.Prefixes()(func(pfx netip.Prefix, val int) bool {
+			if _, ok := seen[pfx]; ok {
+				t.Fatalf("Cursor.Prefixes, %s yielded twice", pfx)
+			}
+			seen[pfx] = val
+			return true
+		})
+
+		c.Children()(func(_ byte, kid Cursor[int]) bool {
+			walk(kid, depth+1)
+			return true
+		})
+	}
+
+	walk(tbl.Root4(), 0)
+	walk(tbl.Root6(), 0)
+
+	if len(seen) != tbl.Size() {
+		t.Fatalf("Cursor walk, want %d prefixes, got %d", tbl.Size(), len(seen))
+	}
+
+	tbl.All()(func(pfx netip.Prefix, val int) bool {
+		if got, ok := seen[pfx]; !ok || got != val {
+			t.Fatalf("Cursor walk, %s: want (%d, true), got (%d, %v)", pfx, val, got, ok)
+		}
+		return true
+	})
+}
+
+func TestCursorEmpty(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	c := tbl.Root6()
+
+	c.Children()(func(byte, Cursor[int]) bool {
+		t.Fatalf("empty table, unexpected child")
+		return false
+	})
+
+	c.Prefixes()(func(netip.Prefix, int) bool {
+		t.Fatalf("empty table, unexpected prefix")
+		return false
+	})
+
+	// zero Cursor
+	var zero Cursor[int]
+	zero.Prefixes()(func(netip.Prefix, int) bool {
+		t.Fatalf("zero Cursor, unexpected prefix")
+		return false
+	})
+}
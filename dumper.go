@@ -116,7 +116,7 @@ func (n *node[V]) dump(w io.Writer, path stridePath, depth int, is4 bool) {
 		leafAddrs := make([]uint8, 0, maxItems)
 		fringeAddrs := make([]uint8, 0, maxItems)
 
-		// the node has recursive child nodes or path-compressed leaves
+		// classify the children by type: subtries, leaves and fringes
 		for i, addr := range n.children.Bits() {
 			switch n.children.Items[i].(type) {
 			case *node[V]:
@@ -138,7 +138,7 @@ func (n *node[V]) dump(w io.Writer, path stridePath, depth int, is4 bool) {
 		fmt.Fprintf(w, "%soctets(#%d): %s\n", indent, n.children.Len(), n.children.String())
 
 		if leafCount := len(leafAddrs); leafCount > 0 {
-			// print the pathcomp prefixes for this node
+			// print the leaves for this node, with their full prefixes
 			fmt.Fprintf(w, "%sleaves(#%d):", indent, leafCount)
 
 			for _, addr := range leafAddrs {
@@ -158,7 +158,7 @@ func (n *node[V]) dump(w io.Writer, path stridePath, depth int, is4 bool) {
 		}
 
 		if fringeCount := len(fringeAddrs); fringeCount > 0 {
-			// print the pathcomp prefixes for this node
+			// print the fringes for this node, the prefix is implicit in the path
 			fmt.Fprintf(w, "%sfringe(#%d):", indent, fringeCount)
 
 			for _, addr := range fringeAddrs {
@@ -193,6 +193,87 @@ func (n *node[V]) dump(w io.Writer, path stridePath, depth int, is4 bool) {
 	}
 }
 
+// DebugTree returns the trie structure as nested maps, for assertions in
+// tests and for JSON dumps during debugging.
+//
+// DEBUG ONLY: the layout reflects the internal trie structure and is NOT
+// a stable API, it may change with any release.
+//
+// The top level has the keys "ipv4" and "ipv6" for the non-empty tries.
+// Every node is a map with the keys "depth", "path", "type" and,
+// if not empty, "prefixes", "leaves" and "fringes" (prefix string → value)
+// and "children" (octet string → node map).
+func (t *Table[V]) DebugTree() map[string]any {
+	tree := make(map[string]any)
+	if t == nil {
+		return tree
+	}
+
+	if t.size4 > 0 {
+		tree["ipv4"] = t.root4.debugTreeRec(stridePath{}, 0, true)
+	}
+
+	if t.size6 > 0 {
+		tree["ipv6"] = t.root6.debugTreeRec(stridePath{}, 0, false)
+	}
+
+	return tree
+}
+
+// debugTreeRec, rec-descent the trie like dumpRec, but build nested maps.
+func (n *node[V]) debugTreeRec(path stridePath, depth int, is4 bool) map[string]any {
+	m := map[string]any{
+		"depth": depth,
+		"path":  ipStridePath(path, depth, is4),
+		"type":  n.hasType().String(),
+	}
+
+	if n.prefixes.Len() != 0 {
+		pfxs := make(map[string]any, n.prefixes.Len())
+		for i, idx := range n.prefixes.Bits() {
+			pfxs[cidrFromPath(path, depth, is4, idx).String()] = n.prefixes.Items[i]
+		}
+		m["prefixes"] = pfxs
+	}
+
+	if n.children.Len() == 0 {
+		return m
+	}
+
+	leaves := make(map[string]any)
+	fringes := make(map[string]any)
+	children := make(map[string]any)
+
+	for i, addr := range n.children.Bits() {
+		switch kid := n.children.Items[i].(type) {
+		case *node[V]:
+			path[depth&15] = addr
+			children[addrFmt(addr, is4)] = kid.debugTreeRec(path, depth+1, is4)
+
+		case *fringeNode[V]:
+			fringes[cidrForFringe(path[:], depth, is4, addr).String()] = kid.value
+
+		case *leafNode[V]:
+			leaves[kid.prefix.String()] = kid.value
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	if len(leaves) != 0 {
+		m["leaves"] = leaves
+	}
+	if len(fringes) != 0 {
+		m["fringes"] = fringes
+	}
+	if len(children) != 0 {
+		m["children"] = children
+	}
+
+	return m
+}
+
 // hasType returns the nodeType.
 func (n *node[V]) hasType() nodeType {
 	s := n.nodeStats()
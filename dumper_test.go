@@ -4,6 +4,7 @@
 package bart
 
 import (
+	"encoding/json"
 	"net/netip"
 	"strings"
 	"testing"
@@ -125,6 +126,38 @@ childs(#3): 10 127 192
 	})
 }
 
+func TestDumpFringeValues(t *testing.T) {
+	t.Parallel()
+	tbl := new(Table[int])
+
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Insert(mpp("10.1.0.0/16"), 2)
+	tbl.Insert(mpp("11.1.0.0/20"), 3)
+	tbl.Insert(mpp("12.0.0.0/8"), 4)
+
+	want := `
+### IPv4: size(4), nodes(2), pfxs(1), leaves(1), fringes(2),
+[HALF] depth:  0 path: [] / 0
+octets(#3): [10 11 12]
+leaves(#1): 11:{11.1.0.0/20, 3}
+fringe(#1): 12:{12.0.0.0/8, 4}
+childs(#1): 10
+
+.[STOP] depth:  1 path: [10] / 8
+.indexs(#1): [1]
+.prefxs(#1): 10.0.0.0/8
+.values(#1): 1
+.octets(#1): [1]
+.fringe(#1): 1:{10.1.0.0/16, 2}
+`
+
+	w := new(strings.Builder)
+	tbl.dump(w)
+	if got := w.String(); got != want {
+		t.Errorf("Dump got:\n%swant:\n%s", got, want)
+	}
+}
+
 func TestDumpSampleV6(t *testing.T) {
 	t.Parallel()
 	tbl := new(Table[any])
@@ -302,3 +335,54 @@ func checkLiteDump(t *testing.T, tbl *Lite, tt dumpTest) {
 		t.Errorf("Dump got:\n%swant:\n%s", got, tt.want)
 	}
 }
+
+func TestDebugTree(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	if got := tbl.DebugTree(); len(got) != 0 {
+		t.Errorf("empty table, DebugTree: %v, want empty map", got)
+	}
+
+	tbl.Insert(mpp("10.0.0.0/8"), 8)
+	tbl.Insert(mpp("10.1.0.0/16"), 16)
+	tbl.Insert(mpp("10.0.0.0/7"), 7)
+	tbl.Insert(mpp("192.168.1.1/32"), 32)
+
+	tree := tbl.DebugTree()
+	if _, ok := tree["ipv6"]; ok {
+		t.Errorf("DebugTree, unexpected ipv6 trie")
+	}
+
+	root, ok := tree["ipv4"].(map[string]any)
+	if !ok {
+		t.Fatalf("DebugTree, missing ipv4 trie: %v", tree)
+	}
+
+	if got := root["prefixes"].(map[string]any)["10.0.0.0/7"]; got != 7 {
+		t.Errorf("DebugTree, root prefix 10.0.0.0/7, got value %v, want 7", got)
+	}
+
+	if got := root["leaves"].(map[string]any)["192.168.1.1/32"]; got != 32 {
+		t.Errorf("DebugTree, root leaf 192.168.1.1/32, got value %v, want 32", got)
+	}
+
+	kid, ok := root["children"].(map[string]any)["10"].(map[string]any)
+	if !ok {
+		t.Fatalf("DebugTree, missing child node at octet 10: %v", root)
+	}
+
+	if kid["depth"] != 1 || kid["path"] != "10" {
+		t.Errorf("DebugTree, child at 10, got depth %v and path %v", kid["depth"], kid["path"])
+	}
+
+	// 10.1.0.0/16 is path compressed as fringe
+	fringes := kid["fringes"].(map[string]any)
+	if fringes["10.1.0.0/16"] != 16 {
+		t.Errorf("DebugTree, child at 10, got fringes %v", fringes)
+	}
+
+	if _, err := json.Marshal(tree); err != nil {
+		t.Errorf("DebugTree, json.Marshal: %v", err)
+	}
+}
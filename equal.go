@@ -0,0 +1,102 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import "net/netip"
+
+// SamePrefixes reports whether the tables a and b hold the same set of prefixes,
+// the values are ignored. The payload types may differ, e.g. to compare
+// a Table[int] with a Table[string] or a [Lite] table.
+//
+// Both tries are descended in parallel, comparing the prefix bitsets and
+// the child shapes. Only where the path compression differs, the sorted
+// prefixes of both subtries are compared.
+func SamePrefixes[V, W any](a *Table[V], b *Table[W]) bool {
+	if a == nil || b == nil {
+		return a.IsEmpty() && b.IsEmpty()
+	}
+
+	if a.size4 != b.size4 || a.size6 != b.size6 {
+		return false
+	}
+
+	return samePrefixesRec(&a.root4, &b.root4, stridePath{}, 0, true) &&
+		samePrefixesRec(&a.root6, &b.root6, stridePath{}, 0, false)
+}
+
+// samePrefixesRec, compare the prefixes of both nodes and all descendants.
+func samePrefixesRec[V, W any](a *node[V], b *node[W], path stridePath, depth int, is4 bool) bool {
+	// both nodes are at the same path, the prefixes in this stride must match
+	if a.prefixes.BitSet256 != b.prefixes.BitSet256 {
+		return false
+	}
+
+	// the other trie can't hold the prefixes below a child elsewhere
+	if a.children.BitSet256 != b.children.BitSet256 {
+		return false
+	}
+
+	for i, addr := range a.children.AsSlice(&[256]uint8{}) {
+		aKid := a.children.Items[i]
+		bKid := b.children.Items[i]
+
+		switch aKid := aKid.(type) {
+		case *node[V]:
+			if bKid, ok := bKid.(*node[W]); ok {
+				path[depth] = addr
+				if !samePrefixesRec(aKid, bKid, path, depth+1, is4) {
+					return false
+				}
+				continue
+			}
+		case *leafNode[V]:
+			if bKid, ok := bKid.(*leafNode[W]); ok {
+				if aKid.prefix != bKid.prefix {
+					return false
+				}
+				continue
+			}
+		case *fringeNode[V]:
+			if _, ok := bKid.(*fringeNode[W]); ok {
+				continue
+			}
+		}
+
+		// different kinds, the path compression differs, compare the prefixes
+		aPfxs := kidPrefixes[V](aKid, path, depth, is4, addr)
+		bPfxs := kidPrefixes[W](bKid, path, depth, is4, addr)
+
+		if len(aPfxs) != len(bPfxs) {
+			return false
+		}
+
+		for j := range aPfxs {
+			if aPfxs[j] != bPfxs[j] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// kidPrefixes returns the sorted prefixes of the child at addr.
+func kidPrefixes[V any](kid any, path stridePath, depth int, is4 bool, addr uint8) (pfxs []netip.Prefix) {
+	switch kid := kid.(type) {
+	case *node[V]:
+		path[depth] = addr
+		_ = kid.allRecSorted(path, depth+1, is4, func(pfx netip.Prefix, _ V) bool {
+			pfxs = append(pfxs, pfx)
+			return true
+		})
+	case *leafNode[V]:
+		pfxs = append(pfxs, kid.prefix)
+	case *fringeNode[V]:
+		pfxs = append(pfxs, cidrForFringe(path[:], depth, is4, addr))
+	default:
+		panic("logic error, wrong node type")
+	}
+
+	return pfxs
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestSamePrefixes(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 10_000)
+
+	a := new(Table[int])
+	b := new(Table[string])
+	for _, item := range pfxs {
+		a.Insert(item.pfx, item.val)
+	}
+
+	// same prefixes, other insert order and value type
+	prng.Shuffle(len(pfxs), func(i, j int) { pfxs[i], pfxs[j] = pfxs[j], pfxs[i] })
+	for _, item := range pfxs {
+		b.Insert(item.pfx, strconv.Itoa(item.val))
+	}
+
+	if !SamePrefixes(a, b) {
+		t.Fatalf("SamePrefixes, want true")
+	}
+
+	lite := new(Lite)
+	for _, item := range pfxs {
+		lite.Insert(item.pfx)
+	}
+
+	if !SamePrefixes(a, &lite.Table) {
+		t.Fatalf("SamePrefixes with Lite, want true")
+	}
+
+	// one prefix less
+	b.Delete(pfxs[0].pfx)
+	if SamePrefixes(a, b) {
+		t.Fatalf("SamePrefixes after Delete, want false")
+	}
+
+	// same size, but one prefix differs
+	c := a.Clone()
+	c.Delete(pfxs[0].pfx)
+	for _, item := range randomPrefixes(prng, 100) {
+		if _, ok := c.Get(item.pfx); !ok {
+			c.Insert(item.pfx, 0)
+			break
+		}
+	}
+
+	if c.Size() != a.Size() || SamePrefixes(a, c) {
+		t.Fatalf("SamePrefixes with differing prefix, want false")
+	}
+
+	if !SamePrefixes(new(Table[int]), (*Table[string])(nil)) {
+		t.Errorf("SamePrefixes, empty and nil, want true")
+	}
+}
+
+func TestSamePrefixesPathCompression(t *testing.T) {
+	t.Parallel()
+
+	// leaf at the root node
+	a := new(Table[int])
+	a.Insert(mpp("10.1.0.0/16"), 1)
+
+	// same prefix, but not path compressed, in a node at depth 1
+	b := new(Table[string])
+	n := new(node[string])
+	n.insertAtDepth(mpp("10.1.0.0/16"), "", 1)
+	b.root4.children.InsertAt(10, n)
+	b.size4 = 1
+
+	if !SamePrefixes(a, b) {
+		t.Errorf("SamePrefixes, leaf vs. node, want true")
+	}
+
+	a.Insert(mpp("10.2.0.0/16"), 2)
+	b.Insert(mpp("10.3.0.0/16"), "")
+	if SamePrefixes(a, b) {
+		t.Errorf("SamePrefixes, different subtries, want false")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import "net/netip"
+
+// lookupFallback holds the per-family miss values for Lookup, see SetFallback.
+// It's never modified in place, derived tables share it.
+type lookupFallback[V any] struct {
+	v4, v6  V
+	set     bool
+	matches bool
+}
+
+// SetFallback configures the values returned by [Table.Lookup] on a miss,
+// v4 for IPv4 and v6 for IPv6 addresses, to avoid the miss branch at every
+// call site. The fallback is stored in the table, not inserted as default
+// route, it doesn't show up in iteration, Size, Overlaps, etc.
+//
+// On a miss Lookup returns the fallback with ok=false, use
+// [Table.WithFallbackMatches] to report it with ok=true.
+// Lookup, LookupBytes, Lookup4, Lookup16, LookupInto, LookupEx,
+// LookupProfiled and LookupFunc use the fallback. All other methods,
+// e.g. LookupPrefix, Contains and NearestSupernet, ignore it.
+// Invalid addresses return the zero value, as before.
+//
+// Derived tables, e.g. by Clone, OnlyV4 and the ...Persist methods,
+// inherit the fallback.
+func (t *Table[V]) SetFallback(v4, v6 V) {
+	fb := &lookupFallback[V]{v4: v4, v6: v6, set: true}
+	if t.fallback != nil {
+		fb.matches = t.fallback.matches
+	}
+	t.fallback = fb
+	t.gen++
+}
+
+// WithFallbackMatches sets the ok result of Lookup for the fallback values,
+// see [Table.SetFallback]. If matches is true, a miss returns the fallback
+// with ok=true, as if a default route had matched. The default is false.
+func (t *Table[V]) WithFallbackMatches(matches bool) {
+	fb := &lookupFallback[V]{}
+	if t.fallback != nil {
+		*fb = *t.fallback
+	}
+	fb.matches = matches
+	t.fallback = fb
+	t.gen++
+}
+
+// ClearFallback removes the fallback values, a Lookup miss
+// returns the zero value and false again.
+func (t *Table[V]) ClearFallback() {
+	t.fallback = nil
+	t.gen++
+}
+
+// lookupMiss returns the result of a Lookup miss for ip,
+// the zero value for an invalid ip.
+func (t *Table[V]) lookupMiss(ip netip.Addr) (val V, ok bool) {
+	if !ip.IsValid() {
+		return
+	}
+	return t.missFallback(ip.Is4())
+}
+
+// missFallback returns the result of a Lookup miss.
+func (t *Table[V]) missFallback(is4 bool) (val V, ok bool) {
+	fb := t.fallback
+	if fb == nil || !fb.set {
+		return
+	}
+
+	if is4 {
+		return fb.v4, fb.matches
+	}
+	return fb.v6, fb.matches
+}
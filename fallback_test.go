@@ -0,0 +1,148 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Insert(mpp("2001:db8::/32"), 2)
+
+	// the order doesn't matter
+	tbl.WithFallbackMatches(false)
+	tbl.SetFallback(4, 6)
+
+	tests := []struct {
+		ip  netip.Addr
+		val int
+		ok  bool
+	}{
+		{mpa("10.1.1.1"), 1, true},
+		{mpa("2001:db8::1"), 2, true},
+		{mpa("11.1.1.1"), 4, false},
+		{mpa("2001:db9::1"), 6, false},
+		{netip.Addr{}, 0, false},
+	}
+
+	check := func(tbl *Table[int], matches bool) {
+		t.Helper()
+		for _, tt := range tests {
+			want := tt.ok || (matches && tt.ip.IsValid())
+
+			if val, ok := tbl.Lookup(tt.ip); val != tt.val || ok != want {
+				t.Errorf("Lookup(%s) = (%d, %v), want (%d, %v)", tt.ip, val, ok, tt.val, want)
+			}
+
+			// the other Lookup variants have the same semantics
+			dst := -1
+			if ok := tbl.LookupInto(tt.ip, &dst); ok != want || (ok && dst != tt.val) {
+				t.Errorf("LookupInto(%s) = (%d, %v), want (%d, %v)", tt.ip, dst, ok, tt.val, want)
+			}
+			if val, _, _, ok := tbl.LookupEx(tt.ip); val != tt.val || ok != want {
+				t.Errorf("LookupEx(%s) = (%d, %v), want (%d, %v)", tt.ip, val, ok, tt.val, want)
+			}
+			if val, ok, _ := tbl.LookupProfiled(tt.ip); val != tt.val || ok != want {
+				t.Errorf("LookupProfiled(%s) = (%d, %v), want (%d, %v)", tt.ip, val, ok, tt.val, want)
+			}
+			if val, ok := tbl.LookupFunc(tt.ip, func(int) bool { return true }); val != tt.val || ok != want {
+				t.Errorf("LookupFunc(%s) = (%d, %v), want (%d, %v)", tt.ip, val, ok, tt.val, want)
+			}
+
+			// the fallback is no route
+			if _, _, _, ok := tbl.NearestSupernet(tt.ip); ok != tt.ok {
+				t.Errorf("NearestSupernet(%s), ok: %v, want %v", tt.ip, ok, tt.ok)
+			}
+
+			if !tt.ip.IsValid() {
+				continue
+			}
+
+			if val, ok := tbl.LookupBytes(tt.ip.AsSlice()); val != tt.val || ok != want {
+				t.Errorf("LookupBytes(%s) = (%d, %v), want (%d, %v)", tt.ip, val, ok, tt.val, want)
+			}
+
+			var val int
+			var ok bool
+			if tt.ip.Is4() {
+				val, ok = tbl.Lookup4(tt.ip.As4())
+			} else {
+				val, ok = tbl.Lookup16(tt.ip.As16())
+			}
+			if val != tt.val || ok != want {
+				t.Errorf("Lookup4/16(%s) = (%d, %v), want (%d, %v)", tt.ip, val, ok, tt.val, want)
+			}
+		}
+	}
+
+	check(tbl, false)
+
+	// derived tables inherit the fallback
+	check(tbl.Clone(), false)
+	check(tbl.InsertPersist(mpp("192.168.0.0/16"), 3), false)
+
+	_, _, both := tbl.Partition(tbl.Clone())
+	check(both, false)
+
+	tbl.WithFallbackMatches(true)
+	check(tbl, true)
+
+	// the fallback is no route
+	if tbl.Size() != 2 {
+		t.Errorf("Size() = %d, want 2", tbl.Size())
+	}
+	if tbl.OverlapsPrefix(mpp("11.0.0.0/8")) || tbl.Contains(mpa("11.1.1.1")) {
+		t.Errorf("fallback must not be a route")
+	}
+	if _, ok := tbl.Get(mpp("0.0.0.0/0")); ok {
+		t.Errorf("fallback must not be a default route")
+	}
+
+	tbl.ClearFallback()
+	if val, ok := tbl.Lookup(mpa("11.1.1.1")); val != 0 || ok {
+		t.Errorf("ClearFallback, Lookup(11.1.1.1) = (%d, %v), want (0, false)", val, ok)
+	}
+}
+
+func TestFallbackNoLeak(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Insert(mpp("2001:db8::/32"), 2)
+	tbl.SetFallback(4, 6)
+	tbl.WithFallbackMatches(true)
+
+	// host routes take the fast path of the prefix lookups
+	for _, pfx := range []netip.Prefix{mpp("11.1.1.1/32"), mpp("2001:db9::1/128")} {
+		if val, ok := tbl.LookupPrefix(pfx); val != 0 || ok {
+			t.Errorf("LookupPrefix(%s) = (%d, %v), want (0, false)", pfx, val, ok)
+		}
+		if lpm, val, ok := tbl.LookupPrefixLPM(pfx); lpm.IsValid() || val != 0 || ok {
+			t.Errorf("LookupPrefixLPM(%s) = (%s, %d, %v), want (invalid, 0, false)", pfx, lpm, val, ok)
+		}
+		if tbl.OverlapsPrefixMaxBits(pfx, pfx.Bits()) {
+			t.Errorf("OverlapsPrefixMaxBits(%s, %d) = true, want false", pfx, pfx.Bits())
+		}
+
+		var gaps []netip.Prefix
+		tbl.Gaps(pfx)(func(gap netip.Prefix) bool {
+			gaps = append(gaps, gap)
+			return true
+		})
+		if len(gaps) != 1 || gaps[0] != pfx {
+			t.Errorf("Gaps(%s) = %v, want [%s]", pfx, gaps, pfx)
+		}
+	}
+
+	// the routes are still found
+	if val, ok := tbl.LookupPrefix(mpp("10.1.1.1/32")); val != 1 || !ok {
+		t.Errorf("LookupPrefix(10.1.1.1/32) = (%d, %v), want (1, true)", val, ok)
+	}
+}
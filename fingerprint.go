@@ -0,0 +1,65 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/netip"
+)
+
+// Fingerprint returns a 64-bit hash of all prefix–value pairs in the table,
+// e.g. as cache key to detect a changed FIB without a remote Equal.
+// The values are hashed by hashVal, see [HashValue] for a default.
+//
+// The per-entry hashes are mixed and summed up, the fingerprint is
+// independent of the insertion order and of the trie layout and stable
+// across runs, as long as hashVal is. Equal tables have equal fingerprints,
+// different tables collide with a probability of about 2^-64.
+func (t *Table[V]) Fingerprint(hashVal func(V) uint64) (sum uint64) {
+	if t == nil {
+		return 0
+	}
+
+	t.All()(func(pfx netip.Prefix, val V) bool {
+		sum += mix64(hashPrefix(pfx) ^ mix64(hashVal(val)))
+		return true
+	})
+
+	return sum
+}
+
+// HashValue is a default hashVal for [Table.Fingerprint], the FNV-1a hash
+// of the Go-syntax representation (%#v) of val.
+//
+// A top-level pointer to a struct, array, slice or map is printed, and
+// hashed, by the contents it points to, e.g. &T{A:1}. All other pointers,
+// e.g. *int or a pointer field in a struct, are printed by address, their
+// fingerprints are only stable within one process.
+func HashValue[V comparable](val V) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%#v", val)
+	return h.Sum64()
+}
+
+// hashPrefix, FNV-1a hash of the address bytes and the prefix length.
+func hashPrefix(pfx netip.Prefix) uint64 {
+	h := fnv.New64a()
+
+	b, _ := pfx.Addr().MarshalBinary()
+	_, _ = h.Write(b)
+	_, _ = h.Write([]byte{byte(pfx.Bits())})
+
+	return h.Sum64()
+}
+
+// mix64, the splitmix64 finalizer, spreads the bits before summing.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
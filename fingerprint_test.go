@@ -0,0 +1,90 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 5_000)
+
+	a := new(Table[int])
+	for _, item := range pfxs {
+		a.Insert(item.pfx, item.val)
+	}
+
+	// same entries, other insertion order
+	b := new(Table[int])
+	for _, i := range prng.Perm(len(pfxs)) {
+		b.Insert(pfxs[i].pfx, pfxs[i].val)
+	}
+
+	fpA := a.Fingerprint(HashValue[int])
+	if fpB := b.Fingerprint(HashValue[int]); fpA != fpB {
+		t.Errorf("Fingerprint, insertion order changes fingerprint: %x != %x", fpA, fpB)
+	}
+
+	// changed value
+	b.Insert(pfxs[0].pfx, pfxs[0].val+1)
+	if fpB := b.Fingerprint(HashValue[int]); fpA == fpB {
+		t.Errorf("Fingerprint, changed value, same fingerprint: %x", fpA)
+	}
+
+	// deleted prefix
+	b.Insert(pfxs[0].pfx, pfxs[0].val)
+	b.Delete(pfxs[1].pfx)
+	if fpB := b.Fingerprint(HashValue[int]); fpA == fpB {
+		t.Errorf("Fingerprint, deleted prefix, same fingerprint: %x", fpA)
+	}
+
+	// moved value, same values but other prefixes
+	c := new(Table[int])
+	c.Insert(mpp("10.0.0.0/8"), 1)
+	c.Insert(mpp("11.0.0.0/8"), 2)
+	d := new(Table[int])
+	d.Insert(mpp("10.0.0.0/8"), 2)
+	d.Insert(mpp("11.0.0.0/8"), 1)
+	if c.Fingerprint(HashValue[int]) == d.Fingerprint(HashValue[int]) {
+		t.Errorf("Fingerprint, swapped values, same fingerprint")
+	}
+
+	// stable across runs, the golden value must never change
+	e := new(Table[string])
+	e.Insert(mpp("10.0.0.0/8"), "a")
+	e.Insert(mpp("2001:db8::/32"), "b")
+	if got, want := e.Fingerprint(HashValue[string]), uint64(0x47b643d5f61d2fe3); got != want {
+		t.Errorf("Fingerprint, golden value: %#x, want %#x", got, want)
+	}
+
+	var nilTbl *Table[int]
+	if fp := nilTbl.Fingerprint(HashValue[int]); fp != 0 {
+		t.Errorf("Fingerprint, nil table: %x, want 0", fp)
+	}
+}
+
+func TestHashValuePointer(t *testing.T) {
+	t.Parallel()
+
+	type route struct {
+		NextHop string
+		Metric  int
+	}
+
+	// a top-level pointer to a struct is hashed by contents
+	a, b := &route{"a", 1}, &route{"a", 1}
+	if HashValue(a) != HashValue(b) {
+		t.Errorf("HashValue, pointers to equal structs, hashes differ")
+	}
+
+	// other pointers are hashed by address
+	x, y := new(int), new(int)
+	if HashValue(x) == HashValue(y) {
+		t.Errorf("HashValue, distinct *int, hashes equal")
+	}
+}
@@ -136,6 +136,13 @@ func BenchmarkFullMatch4(b *testing.B) {
 		}
 	})
 
+	b.Run("ContainsGeneric", func(b *testing.B) {
+		b.ResetTimer()
+		for j := 0; j < b.N; j++ {
+			boolSink = rt.contains(matchIP4)
+		}
+	})
+
 	b.Run("Lookup", func(b *testing.B) {
 		b.ResetTimer()
 		for j := 0; j < b.N; j++ {
@@ -214,6 +221,13 @@ func BenchmarkFullMiss4(b *testing.B) {
 		}
 	})
 
+	b.Run("ContainsGeneric", func(b *testing.B) {
+		b.ResetTimer()
+		for j := 0; j < b.N; j++ {
+			boolSink = rt.contains(missIP4)
+		}
+	})
+
 	b.Run("Lookup", func(b *testing.B) {
 		b.ResetTimer()
 		for j := 0; j < b.N; j++ {
@@ -378,6 +392,102 @@ func BenchmarkFullTableClone(b *testing.B) {
 	})
 }
 
+func BenchmarkFullTableAllOfLength(b *testing.B) {
+	rt := new(Table[int])
+	for i, route := range routes {
+		rt.Insert(route.CIDR, i)
+	}
+
+	b.Run("FilterAll", func(b *testing.B) {
+		for j := 0; j < b.N; j++ {
+			rt.All()(func(pfx netip.Prefix, _ int) bool {
+				if pfx.Bits() == 16 {
+					intSink++
+				}
+				return true
+			})
+		}
+	})
+
+	b.Run("AllOfLength", func(b *testing.B) {
+		for j := 0; j < b.N; j++ {
+			rt.AllOfLength(16)(func(netip.Prefix, int) bool {
+				intSink++
+				return true
+			})
+		}
+	})
+}
+
+func BenchmarkFullTableUnionAbsorb(b *testing.B) {
+	even := new(Table[int])
+	odd := new(Table[int])
+
+	for i, route := range routes {
+		if i%2 == 0 {
+			even.Insert(route.CIDR, i)
+		} else {
+			odd.Insert(route.CIDR, i)
+		}
+	}
+
+	b.Run("Union", func(b *testing.B) {
+		for j := 0; j < b.N; j++ {
+			b.StopTimer()
+			rt := even.CloneShallow()
+			b.StartTimer()
+
+			rt.Union(odd)
+		}
+	})
+
+	b.Run("Absorb", func(b *testing.B) {
+		for j := 0; j < b.N; j++ {
+			b.StopTimer()
+			rt := even.CloneShallow()
+			o := odd.CloneShallow()
+			b.StartTimer()
+
+			rt.Absorb(o)
+		}
+	})
+}
+
+func BenchmarkFullTableUnionFunc(b *testing.B) {
+	// overlapping halves, a quarter of the routes are duplicates
+	lower := new(Table[int])
+	upper := new(Table[int])
+
+	for i, route := range routes {
+		if i < len(routes)*5/8 {
+			lower.Insert(route.CIDR, i)
+		}
+		if i >= len(routes)*3/8 {
+			upper.Insert(route.CIDR, i)
+		}
+	}
+
+	b.Run("Union", func(b *testing.B) {
+		for j := 0; j < b.N; j++ {
+			b.StopTimer()
+			rt := lower.CloneShallow()
+			b.StartTimer()
+
+			rt.Union(upper)
+		}
+	})
+
+	b.Run("UnionFunc", func(b *testing.B) {
+		for j := 0; j < b.N; j++ {
+			b.StopTimer()
+			rt := lower.CloneShallow()
+			b.StartTimer()
+
+			rt.UnionFunc(upper, func(_ netip.Prefix, a, b int) int { return a + b })
+		}
+	})
+}
+
 func BenchmarkFullTableMemory4(b *testing.B) {
 	var startMem, endMem runtime.MemStats
 
@@ -0,0 +1,191 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"net/netip"
+
+	"github.com/metacubex/bart/internal/art"
+)
+
+// Gaps returns an iterator over the CIDR blocks within the given prefix
+// that are NOT covered by any route in the table.
+//
+// It's the complement of [Table.Subnets]: the covered subnets are walked in
+// CIDR sort order and the holes between them are yielded as minimal set of
+// CIDRs, in ascending address order.
+//
+// If within is fully covered, nothing is yielded. If no route overlaps
+// within, within itself is yielded.
+//
+// Example:
+//
+//	for gap := range table.Gaps(netip.MustParsePrefix("10.0.0.0/8")) {
+//	    fmt.Println("unallocated:", gap)
+//	}
+func (t *Table[V]) Gaps(within netip.Prefix) func(yield func(netip.Prefix) bool) {
+	return func(yield func(netip.Prefix) bool) {
+		if !within.IsValid() {
+			return
+		}
+
+		// canonicalize the prefix
+		within = within.Masked()
+
+		// within is covered by itself or any supernet, no gaps
+		if _, _, ok := t.lookupPrefixLPM(within, false); ok {
+			return
+		}
+
+		// next is the first addr not yet covered by any subnet
+		next := within.Addr()
+		last := lastAddr(within)
+
+		ok := true
+		t.Subnets(within)(func(pfx netip.Prefix, _ V) bool {
+			first := pfx.Addr()
+
+			// pfx is nested in an already visited subnet, skip it
+			if first.Less(next) {
+				return true
+			}
+
+			// the hole before this subnet
+			if next.Less(first) {
+				if ok = rangeToPrefixes(next, first.Prev(), yield); !ok {
+					return false
+				}
+			}
+
+			// Next() is invalid at the end of the address space, stop
+			next = lastAddr(pfx).Next()
+			return next.IsValid()
+		})
+
+		// the hole after the last subnet
+		if ok && next.IsValid() && !last.Less(next) {
+			_ = rangeToPrefixes(next, last, yield)
+		}
+	}
+}
+
+// Exclude punches a hole for pfx into the table: every stored route
+// covering pfx, including pfx itself, is deleted and a route strictly
+// shorter than pfx is replaced by the minimal set of CIDRs covering
+// the route except pfx, with the same value.
+//
+//	stored 10.0.0.0/8, Exclude(10.0.1.0/24) =>
+//	10.0.0.0/24, 10.0.2.0/23, 10.0.4.0/22, ..., 10.128.0.0/9
+//
+// Routes more specific than pfx and existing routes equal to a generated
+// CIDR are left untouched; for nested covering routes the value of the
+// most specific one is used, the LPM result outside pfx doesn't change.
+// The value is cloned for each generated CIDR if V implements the [Cloner] interface.
+func (t *Table[V]) Exclude(pfx netip.Prefix) {
+	if !pfx.IsValid() {
+		return
+	}
+
+	// canonicalize the prefix
+	pfx = pfx.Masked()
+
+	cloneFn := cloneFnFactory[V]()
+	if cloneFn == nil {
+		cloneFn = copyVal[V]
+	}
+
+	type item struct {
+		pfx netip.Prefix
+		val V
+	}
+
+	// collect first, the table is modified below, in LPM order
+	var covering []item
+	t.Supernets(pfx)(func(super netip.Prefix, val V) bool {
+		covering = append(covering, item{super, val})
+		return true
+	})
+
+	for _, c := range covering {
+		t.Delete(c.pfx)
+
+		if c.pfx == pfx {
+			continue
+		}
+
+		// more specific covering routes are already inserted, don't overwrite
+		insert := func(piece netip.Prefix) bool {
+			t.InsertIfAbsent(piece, cloneFn(c.val))
+			return true
+		}
+
+		// the address ranges before and after pfx within c.pfx
+		if first := c.pfx.Addr(); first != pfx.Addr() {
+			rangeToPrefixes(first, pfx.Addr().Prev(), insert)
+		}
+		if last := lastAddr(pfx); last != lastAddr(c.pfx) {
+			rangeToPrefixes(last.Next(), lastAddr(c.pfx), insert)
+		}
+	}
+}
+
+// rangeToPrefixes, helper function,
+// yields the minimal set of CIDRs covering the address range [first, last].
+//
+// first and last must be of the same IP version and first <= last.
+func rangeToPrefixes(first, last netip.Addr, yield func(netip.Prefix) bool) bool {
+	for {
+		// find the biggest block, aligned at first and not exceeding last
+		bits := first.BitLen()
+		for bits > 0 {
+			pfx := netip.PrefixFrom(first, bits-1)
+			if pfx.Masked().Addr() != first || last.Less(lastAddr(pfx)) {
+				break
+			}
+			bits--
+		}
+
+		pfx := netip.PrefixFrom(first, bits)
+		if !yield(pfx) {
+			return false
+		}
+
+		end := lastAddr(pfx)
+		if end == last {
+			return true
+		}
+		first = end.Next()
+	}
+}
+
+// lastAddr, helper function,
+// returns the last address (all host bits set) of pfx.
+func lastAddr(pfx netip.Prefix) netip.Addr {
+	ip := pfx.Addr()
+	bits := pfx.Bits()
+
+	if ip.Is4() {
+		a4 := ip.As4()
+		setHostBits(a4[:], bits)
+		return netip.AddrFrom4(a4)
+	}
+
+	a16 := ip.As16()
+	setHostBits(a16[:], bits)
+	return netip.AddrFrom16(a16)
+}
+
+// setHostBits, sets all bits after the first bits in octets.
+func setHostBits(octets []byte, bits int) {
+	for i := range octets {
+		if bits >= strideLen {
+			bits -= strideLen
+			continue
+		}
+
+		// NetMask(0) is 0, the whole octet is host part
+		octets[i] |= ^art.NetMask(uint8(bits))
+		bits = 0
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGaps(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		pfxs   []string
+		within string
+		want   []string
+	}{
+		{
+			name:   "empty table",
+			pfxs:   nil,
+			within: "10.0.0.0/8",
+			want:   []string{"10.0.0.0/8"},
+		},
+		{
+			name:   "covered by itself",
+			pfxs:   []string{"10.0.0.0/8"},
+			within: "10.0.0.0/8",
+			want:   nil,
+		},
+		{
+			name:   "covered by supernet",
+			pfxs:   []string{"0.0.0.0/0"},
+			within: "10.0.0.0/8",
+			want:   nil,
+		},
+		{
+			name:   "fully covered by subnets",
+			pfxs:   []string{"10.0.0.0/9", "10.128.0.0/9", "10.1.0.0/16"},
+			within: "10.0.0.0/8",
+			want:   nil,
+		},
+		{
+			name:   "one hole",
+			pfxs:   []string{"10.0.0.0/9", "10.192.0.0/10"},
+			within: "10.0.0.0/8",
+			want:   []string{"10.128.0.0/10"},
+		},
+		{
+			name:   "holes before, between and after",
+			pfxs:   []string{"192.168.1.0/24", "192.168.1.128/25", "192.168.3.0/24"},
+			within: "192.168.0.0/22",
+			want:   []string{"192.168.0.0/24", "192.168.2.0/24"},
+		},
+		{
+			name:   "unaligned hole",
+			pfxs:   []string{"10.0.0.1/32", "10.0.0.6/32"},
+			within: "10.0.0.0/29",
+			want:   []string{"10.0.0.0/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.7/32"},
+		},
+		{
+			name:   "end of address space",
+			pfxs:   []string{"255.255.255.255/32"},
+			within: "255.255.255.252/30",
+			want:   []string{"255.255.255.252/31", "255.255.255.254/32"},
+		},
+		{
+			name:   "v6",
+			pfxs:   []string{"2001:db8::/33", "2001:db8:c000::/34"},
+			within: "2001:db8::/32",
+			want:   []string{"2001:db8:8000::/34"},
+		},
+	}
+
+	for _, tt := range tests {
+		tbl := new(Table[int])
+		for _, s := range tt.pfxs {
+			tbl.Insert(mpp(s), 0)
+		}
+
+		var got []netip.Prefix
+		tbl.Gaps(mpp(tt.within))(func(pfx netip.Prefix) bool {
+			got = append(got, pfx)
+			return true
+		})
+
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: Gaps(%s) = %v, want %v", tt.name, tt.within, got, tt.want)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != mpp(tt.want[i]) {
+				t.Errorf("%s: Gaps(%s) = %v, want %v", tt.name, tt.within, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestGapsCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for _, item := range randomPrefixes4(prng, 2_000) {
+		tbl.Insert(item.pfx, item.val)
+	}
+
+	within := mpp("0.0.0.0/0")
+
+	// the table doesn't contain the default route, so the gaps
+	// must be disjoint from all routes ...
+	var gaps []netip.Prefix
+	tbl.Gaps(within)(func(gap netip.Prefix) bool {
+		if tbl.OverlapsPrefix(gap) {
+			t.Fatalf("Gaps: %s overlaps routing table", gap)
+		}
+		gaps = append(gaps, gap)
+		return true
+	})
+
+	// ... and every address is either covered by a route or by a gap
+	for i := 0; i < 10_000; i++ {
+		ip := randomIP4(prng)
+
+		inGap := false
+		for _, gap := range gaps {
+			if gap.Contains(ip) {
+				inGap = true
+				break
+			}
+		}
+
+		if inGap == tbl.Contains(ip) {
+			t.Fatalf("Gaps: %s, inGap: %v, Contains: %v", ip, inGap, tbl.Contains(ip))
+		}
+	}
+}
+
+func TestExclude(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Insert(mpp("10.0.0.0/16"), 2)
+	tbl.Insert(mpp("10.0.1.128/25"), 3)
+	tbl.Insert(mpp("10.2.0.0/16"), 4)
+
+	tbl.Exclude(netip.MustParsePrefix("10.0.1.7/24"))
+
+	var got []string
+	tbl.AllSorted()(func(pfx netip.Prefix, val int) bool {
+		got = append(got, fmt.Sprintf("%s -> %d", pfx, val))
+		return true
+	})
+
+	expect := []string{
+		"10.0.0.0/24 -> 2",
+		"10.0.1.128/25 -> 3",
+		"10.0.2.0/23 -> 2",
+		"10.0.4.0/22 -> 2",
+		"10.0.8.0/21 -> 2",
+		"10.0.16.0/20 -> 2",
+		"10.0.32.0/19 -> 2",
+		"10.0.64.0/18 -> 2",
+		"10.0.128.0/17 -> 2",
+		"10.1.0.0/16 -> 1",
+		"10.2.0.0/15 -> 1",
+		"10.2.0.0/16 -> 4",
+		"10.4.0.0/14 -> 1",
+		"10.8.0.0/13 -> 1",
+		"10.16.0.0/12 -> 1",
+		"10.32.0.0/11 -> 1",
+		"10.64.0.0/10 -> 1",
+		"10.128.0.0/9 -> 1",
+	}
+
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Exclude, got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expect, "\n"))
+	}
+
+	// no covering route, no-op
+	size := tbl.Size()
+	tbl.Exclude(mpp("192.168.0.0/16"))
+	tbl.Exclude(netip.Prefix{})
+	if tbl.Size() != size {
+		t.Errorf("Exclude, uncovered prefix, size changed: %d, want %d", tbl.Size(), size)
+	}
+}
+
+func TestExcludeCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	orig := new(Table[int])
+	for _, item := range randomPrefixes4(prng, 1_000) {
+		orig.Insert(item.pfx, item.val)
+	}
+
+	for i := 0; i < 100; i++ {
+		pfx := netip.PrefixFrom(randomIP4(prng), 8+prng.Intn(25)).Masked()
+
+		tbl := orig.Clone()
+		tbl.Exclude(pfx)
+
+		if n := tbl.SupernetCount(pfx); n != 0 {
+			t.Fatalf("Exclude(%s), %d covering routes left", pfx, n)
+		}
+
+		// the more specific routes within pfx
+		inner := new(Table[int])
+		orig.Subnets(pfx)(func(sub netip.Prefix, val int) bool {
+			if sub != pfx {
+				inner.Insert(sub, val)
+			}
+			return true
+		})
+
+		for j := 0; j < 1_000; j++ {
+			ip := randomIP4(prng)
+			if j%2 == 0 {
+				// force addresses within pfx, random host bits
+				first, last, rnd := pfx.Addr().As4(), lastAddr(pfx).As4(), ip.As4()
+				for k := range rnd {
+					rnd[k] = first[k] | rnd[k]&(last[k]^first[k])
+				}
+				ip = netip.AddrFrom4(rnd)
+			}
+
+			ref := orig
+			if pfx.Contains(ip) {
+				ref = inner
+			}
+
+			gotVal, gotOK := tbl.Lookup(ip)
+			wantVal, wantOK := ref.Lookup(ip)
+			if gotVal != wantVal || gotOK != wantOK {
+				t.Fatalf("Exclude(%s), Lookup(%s) = (%d, %v), want (%d, %v)", pfx, ip, gotVal, gotOK, wantVal, wantOK)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import "sync"
+
+// NewInterned returns an empty table that deduplicates equal values on insert.
+//
+// Every value stored by Insert, Update, Union and their variants is replaced
+// by the first inserted value equal to it, lookups return this interned value.
+// The constraint comparable is needed for the internal map of values.
+//
+// Each prefix still stores its own copy of V, but the data referenced by V
+// is shared, e.g. the bytes of strings or the data boxed in interfaces.
+// This saves memory if many prefixes hold equal values backed by separate
+// allocations, e.g. next-hops parsed per route from a config file.
+//
+// The intern map is shared with all tables derived by Clone and the
+// ...Persist methods, it's safe for concurrent use. Values aren't removed
+// from the intern map on delete.
+func NewInterned[V comparable]() *Table[V] {
+	var mu sync.Mutex
+	seen := make(map[V]V)
+
+	intern := func(val V) V {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if canonical, ok := seen[val]; ok {
+			return canonical
+		}
+		seen[val] = val
+		return val
+	}
+
+	return &Table[V]{intern: intern}
+}
+
+// internVal returns the interned val, or val itself for a regular table.
+func (t *Table[V]) internVal(val V) V {
+	if t.intern == nil {
+		return val
+	}
+	return t.intern(val)
+}
+
+// internCb wraps the Update callback to intern the new value.
+func (t *Table[V]) internCb(cb func(V, bool) V) func(V, bool) V {
+	if t.intern == nil {
+		return cb
+	}
+	return func(val V, ok bool) V {
+		return t.intern(cb(val, ok))
+	}
+}
+
+// internCloneFn wraps the cloneFn for values from other tables to intern them.
+func (t *Table[V]) internCloneFn(cloneFn cloneFunc[V]) cloneFunc[V] {
+	if t.intern == nil {
+		return cloneFn
+	}
+	return func(val V) V {
+		return t.intern(cloneFn(val))
+	}
+}
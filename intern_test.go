@@ -0,0 +1,107 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+	"unsafe"
+)
+
+func TestInterned(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	// fresh allocation per value, equal strings have their own bytes
+	nextHop := func(i int) string { return fmt.Sprintf("next-hop-%d", i%3) }
+
+	pfxs := randomPrefixes(prng, 1_000)
+
+	tbl := NewInterned[string]()
+	other := new(Table[string])
+
+	for i, item := range pfxs[:250] {
+		tbl.Insert(item.pfx, nextHop(i))
+	}
+	for i, item := range pfxs[250:500] {
+		tbl.Update(item.pfx, func(string, bool) string { return nextHop(i) })
+	}
+	for i, item := range pfxs[500:750] {
+		tbl = tbl.InsertPersist(item.pfx, nextHop(i))
+	}
+	for i, item := range pfxs[750:] {
+		other.Insert(item.pfx, nextHop(i))
+	}
+	tbl.Union(other)
+
+	// all equal values must share the same bytes
+	data := make(map[string]*byte)
+	tbl.All()(func(pfx netip.Prefix, val string) bool {
+		if p, ok := data[val]; ok && p != unsafe.StringData(val) {
+			t.Fatalf("%s: value %q not interned", pfx, val)
+		}
+		data[val] = unsafe.StringData(val)
+		return true
+	})
+
+	if len(data) != 3 {
+		t.Errorf("want 3 distinct values, got %d", len(data))
+	}
+
+	// lookups return the interned value
+	val, _ := tbl.Get(pfxs[0].pfx)
+	if unsafe.StringData(val) != data[val] {
+		t.Errorf("Get, value %q not interned", val)
+	}
+
+	// a regular table doesn't intern
+	plain := new(Table[string])
+	plain.Insert(mpp("10.0.0.0/8"), nextHop(0))
+	plain.Insert(mpp("11.0.0.0/8"), nextHop(0))
+
+	a, _ := plain.Get(mpp("10.0.0.0/8"))
+	b, _ := plain.Get(mpp("11.0.0.0/8"))
+	if unsafe.StringData(a) == unsafe.StringData(b) {
+		t.Errorf("regular table, unexpected shared value")
+	}
+}
+
+func TestInternedReadBinary(t *testing.T) {
+	t.Parallel()
+
+	src := new(Table[string])
+	src.Insert(mpp("10.0.0.0/8"), "next-hop")
+	src.Insert(mpp("2001:db8::/32"), "next-hop")
+
+	buf := new(bytes.Buffer)
+	enc := func(val string) []byte { return []byte(val) }
+	if _, err := src.WriteBinary(buf, enc); err != nil {
+		t.Fatalf("WriteBinary: %v", err)
+	}
+
+	tbl := NewInterned[string]()
+
+	var notified int
+	tbl.OnChange(func(ChangeOp, netip.Prefix, string) { notified++ })
+
+	// the decoder allocates a fresh string per value
+	dec := func(buf []byte) (string, error) { return string(buf), nil }
+	if _, err := tbl.ReadBinary(buf, dec); err != nil {
+		t.Fatalf("ReadBinary: %v", err)
+	}
+
+	// bulk loads are not reported, not even for the interning
+	if notified != 0 {
+		t.Errorf("ReadBinary, %d notifications, want 0", notified)
+	}
+
+	a, _ := tbl.Get(mpp("10.0.0.0/8"))
+	b, _ := tbl.Get(mpp("2001:db8::/32"))
+	if unsafe.StringData(a) != unsafe.StringData(b) {
+		t.Errorf("ReadBinary, value %q not interned", a)
+	}
+}
@@ -21,6 +21,7 @@ package bitset
 // can inline (*BitSet256).AsSlice with cost 42
 // can inline (*BitSet256).Bits with cost 47
 // can inline (*BitSet256).Clear with cost 12
+// can inline (*BitSet256).Count with cost 33
 // can inline (*BitSet256).FirstSet with cost 79
 // can inline (*BitSet256).Intersects with cost 48
 // can inline (*BitSet256).Intersection with cost 53
@@ -28,7 +29,6 @@ package bitset
 // can inline (*BitSet256).IsEmpty with cost 22
 // can inline (*BitSet256).LastSet with cost 37
 // can inline (*BitSet256).NextSet with cost 65
-// can inline (*BitSet256).popcnt with cost 33
 // can inline (*BitSet256).Rank with cost 57
 // can inline (*BitSet256).Set with cost 12
 // can inline (*BitSet256).Test with cost 15
@@ -307,8 +307,8 @@ func (b *BitSet256) Union(c *BitSet256) (bs BitSet256) {
 	return
 }
 
-// popcount is the number of set bits.
-func (b *BitSet256) popcount() (cnt int) {
+// Count returns the number of set bits.
+func (b *BitSet256) Count() (cnt int) {
 	cnt += bits.OnesCount64(b[0])
 	cnt += bits.OnesCount64(b[1])
 	cnt += bits.OnesCount64(b[2])
@@ -27,7 +27,7 @@ func TestZeroValue(t *testing.T) {
 	b.Clear(100)
 
 	b = BitSet256{}
-	b.popcount()
+	b.Count()
 
 	b = BitSet256{}
 	b.Rank(100)
@@ -510,7 +510,7 @@ func TestCount(t *testing.T) {
 	checkLast := true
 
 	for i := uint8(0); i < tot; i++ {
-		sz := uint8(b.popcount())
+		sz := uint8(b.Count())
 		if sz != i {
 			t.Logf("%v", b)
 			t.Errorf("Count reported as %d, but it should be %d", sz, i)
@@ -521,7 +521,7 @@ func TestCount(t *testing.T) {
 	}
 
 	if checkLast {
-		sz := uint8(b.popcount())
+		sz := uint8(b.Count())
 		if sz != tot {
 			t.Errorf("After all bits set, size reported as %d, but it should be %d", sz, tot)
 		}
@@ -534,7 +534,7 @@ func TestCount2(t *testing.T) {
 	var b BitSet256
 	tot := uint8(64*3 + 11)
 	for i := uint8(0); i < tot; i += 3 {
-		sz := uint8(b.popcount())
+		sz := uint8(b.Count())
 		if sz != i/3 {
 			t.Errorf("Count reported as %d, but it should be %d", sz, i)
 			break
@@ -564,11 +564,11 @@ func TestUnion(t *testing.T) {
 	d := b
 	d = d.Union(&a)
 
-	if c.popcount() != 200 {
-		t.Errorf("Union should have 200 bits set, but had %d", c.popcount())
+	if c.Count() != 200 {
+		t.Errorf("Union should have 200 bits set, but had %d", c.Count())
 	}
-	if d.popcount() != 200 {
-		t.Errorf("Union should have 200 bits set, but had %d", d.popcount())
+	if d.Count() != 200 {
+		t.Errorf("Union should have 200 bits set, but had %d", d.Count())
 	}
 }
 
@@ -590,11 +590,11 @@ func TestInplaceIntersection(t *testing.T) {
 
 	d := b
 	d = d.Intersection(&a)
-	if c.popcount() != 50 {
-		t.Errorf("Intersection should have 50 bits set, but had %d", c.popcount())
+	if c.Count() != 50 {
+		t.Errorf("Intersection should have 50 bits set, but had %d", c.Count())
 	}
-	if d.popcount() != 50 {
-		t.Errorf("Intersection should have 50 bits set, but had %d", d.popcount())
+	if d.Count() != 50 {
+		t.Errorf("Intersection should have 50 bits set, but had %d", d.Count())
 	}
 }
 
@@ -788,7 +788,7 @@ func BenchmarkPopcount(b *testing.B) {
 
 	b.ResetTimer()
 	for j := 0; j < b.N; j++ {
-		intSink = aa.popcount()
+		intSink = aa.Count()
 	}
 }
 
@@ -23,11 +23,30 @@ type Lite struct {
 	Table[struct{}]
 }
 
+// Set is an alias for Lite, for code that uses a table of prefixes
+// as a plain set and prefers the set vocabulary Add, Has and Remove.
+//
+// Lite embeds a full Table[struct{}], so a Set has the complete Table
+// feature set (iteration, overlaps, subnets, ...) and the same memory
+// footprint per prefix as a Lite; the empty struct payload itself
+// occupies no space in the trie. There is no separate, slimmer set
+// implementation in this module.
+type Set = Lite
+
+// Add is an adapter for the underlying table, same as Insert.
+func (l *Lite) Add(pfx netip.Prefix) {
+	l.Table.Insert(pfx, struct{}{})
+}
+
+// Remove is an adapter for the underlying table, same as Delete.
+func (l *Lite) Remove(pfx netip.Prefix) {
+	l.Table.Delete(pfx)
+}
+
 // Exists returns true if the prefix exists in the table.
-// It's an adapter to [Table.Get].
+// It's an adapter to [Table.Has].
 func (l *Lite) Exists(pfx netip.Prefix) bool {
-	_, ok := l.Get(pfx)
-	return ok
+	return l.Has(pfx)
 }
 
 // Contains is a wrapper for the underlying table.
@@ -40,6 +59,11 @@ func (l *Lite) Insert(pfx netip.Prefix) {
 	l.Table.Insert(pfx, struct{}{})
 }
 
+// InsertAddr is an adapter for the underlying table.
+func (l *Lite) InsertAddr(ip netip.Addr) {
+	l.Table.InsertAddr(ip, struct{}{})
+}
+
 // InsertPersist is an adapter for the underlying table.
 func (l *Lite) InsertPersist(pfx netip.Prefix) *Lite {
 	tbl := l.Table.InsertPersist(pfx, struct{}{})
@@ -67,8 +91,8 @@ func (l *Lite) Clone() *Lite {
 }
 
 // Union is an adapter for the underlying table.
-func (l *Lite) Union(o *Lite) {
-	l.Table.Union(&o.Table)
+func (l *Lite) Union(o *Lite) (duplicates int) {
+	return l.Table.Union(&o.Table)
 }
 
 // UnionPersist is an adapter for the underlying table.
@@ -830,3 +830,60 @@ func checkLiteString(t *testing.T, tbl *Lite, tt stringTest) {
 		t.Errorf("MarshalText got:\n%swant:\n%s", gotBytes, tt.want)
 	}
 }
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	s := new(Set)
+	s.Add(mpp("10.0.0.0/8"))
+	s.Add(mpp("2001:db8::/32"))
+	s.Add(mpp("10.0.0.0/8"))
+
+	if s.Size() != 2 {
+		t.Errorf("Set.Add, Size() = %d, want 2", s.Size())
+	}
+
+	if !s.Has(mpp("10.0.0.0/8")) {
+		t.Errorf("Set.Has(10.0.0.0/8) = false, want true")
+	}
+
+	if !s.Contains(mpa("10.1.2.3")) {
+		t.Errorf("Set.Contains(10.1.2.3) = false, want true")
+	}
+
+	s.Remove(mpp("10.0.0.0/8"))
+	s.Remove(mpp("11.0.0.0/8"))
+
+	if s.Has(mpp("10.0.0.0/8")) {
+		t.Errorf("Set.Remove, Has(10.0.0.0/8) = true, want false")
+	}
+
+	if s.Size() != 1 {
+		t.Errorf("Set.Remove, Size() = %d, want 1", s.Size())
+	}
+}
+
+func TestLiteSize(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	lt := new(Lite)
+	for _, item := range randomPrefixes(prng, 5_000) {
+		lt.Insert(item.pfx)
+	}
+
+	// the sizes are promoted from the embedded Table, they must
+	// match the prefixes, leaves and fringes in the tries
+	s4 := lt.root4.nodeStatsRec()
+	s6 := lt.root6.nodeStatsRec()
+
+	if got, want := lt.Size4(), s4.pfxs+s4.leaves+s4.fringes; got != want {
+		t.Errorf("Lite.Size4() = %d, want %d", got, want)
+	}
+	if got, want := lt.Size6(), s6.pfxs+s6.leaves+s6.fringes; got != want {
+		t.Errorf("Lite.Size6() = %d, want %d", got, want)
+	}
+	if got, want := lt.Size(), lt.Size4()+lt.Size6(); got != want {
+		t.Errorf("Lite.Size() = %d, want %d", got, want)
+	}
+}
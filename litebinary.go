@@ -0,0 +1,317 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+
+	"github.com/metacubex/bart/internal/bitset"
+)
+
+// The compact binary trie format for Lite tables, written by [Lite.WriteTo]
+// and read back by [Lite.ReadFrom]. It's the pre-order serialization of
+// [Table.WriteBinary] without the value stream, all integers are little endian:
+//
+//	lite:   magic "BRTL" | version uint8 | size4 uint64 | size6 uint64 | node(root4) | node(root6)
+//
+//	node:   bitset(prefixes) | bitset(children) | child ... (one per bit set in children, ascending)
+//
+//	bitset: count uint8 | idx [count]uint8 (ascending), for count <= 24
+//	        0xff | [4]uint64, else
+//
+//	child:  kind uint8 | payload
+//	        kind 0, node:   node
+//	        kind 1, fringe: -
+//	        kind 2+bits, leaf: the address octets after the child octet
+//	                           up to the last octet covered by bits
+//
+// The octets of a leaf address up to the child octet are given by the
+// path in the trie. For a large denylist this is a fraction of the
+// size of the Table[struct{}] format.
+const (
+	liteBinaryMagic   = "BRTL"
+	liteBinaryVersion = 1
+)
+
+// child kinds in the compact Lite format, leaves are liteKindLeaf+bits
+const (
+	liteKindNode byte = iota
+	liteKindFringe
+	liteKindLeaf
+)
+
+// bitsets with more bits set are written raw
+const (
+	liteSparseMax = 24
+	liteRawBitset = 0xff
+)
+
+// WriteTo serializes the Lite table in the compact binary trie format to w.
+// It implements the [io.WriterTo] interface.
+//
+// It returns the number of bytes written and the first write error, if any.
+//
+// Without values, the Lite format is much smaller than the format of
+// [Table.WriteBinary], e.g. to distribute large denylists.
+func (l *Lite) WriteTo(w io.Writer) (int64, error) {
+	bw := &binaryWriter{w: w}
+
+	bw.write([]byte(liteBinaryMagic))
+	bw.write([]byte{liteBinaryVersion})
+	bw.uint64(uint64(l.size4))
+	bw.uint64(uint64(l.size6))
+
+	l.root4.writeLiteRec(bw, stridePath{}, 0)
+	l.root6.writeLiteRec(bw, stridePath{}, 0)
+
+	return bw.n, bw.err
+}
+
+// writeLiteRec, write the node and all descendants in pre-order.
+func (n *node[V]) writeLiteRec(bw *binaryWriter, path stridePath, depth int) {
+	bw.sparseBitset(&n.prefixes.BitSet256)
+	bw.sparseBitset(&n.children.BitSet256)
+
+	for i, addr := range n.children.AsSlice(&[256]uint8{}) {
+		switch kid := n.children.Items[i].(type) {
+		case *node[V]:
+			bw.kind(liteKindNode)
+			path[depth] = addr
+			kid.writeLiteRec(bw, path, depth+1)
+
+		case *leafNode[V]:
+			// the octets up to the child octet are known from the path
+			bits := kid.prefix.Bits()
+			octets := kid.prefix.Addr().AsSlice()[depth+1 : (bits+7)>>3]
+
+			bw.kind(liteKindLeaf + byte(bits))
+			bw.write(octets)
+
+		case *fringeNode[V]:
+			bw.kind(liteKindFringe)
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+}
+
+// ReadFrom replaces the content of the Lite table with the table read from r,
+// serialized by [Lite.WriteTo]. It implements the [io.ReaderFrom] interface.
+//
+// It returns the number of bytes read and an error, if any.
+// The receiver is only modified if the whole table could be read.
+func (l *Lite) ReadFrom(r io.Reader) (int64, error) {
+	br := &binaryReader{r: r}
+
+	magic := make([]byte, len(liteBinaryMagic)+1)
+	if br.read(magic); br.err != nil {
+		return br.n, br.err
+	}
+
+	if string(magic[:len(liteBinaryMagic)]) != liteBinaryMagic {
+		return br.n, fmt.Errorf("%w: wrong magic %q", ErrBinaryFormat, magic[:len(liteBinaryMagic)])
+	}
+
+	if version := magic[len(liteBinaryMagic)]; version != liteBinaryVersion {
+		return br.n, fmt.Errorf("%w: unsupported version %d", ErrBinaryFormat, version)
+	}
+
+	size4 := br.uint64()
+	size6 := br.uint64()
+
+	var root4, root6 node[struct{}]
+
+	count4 := root4.readLiteRec(br, stridePath{}, 0, true)
+	count6 := root6.readLiteRec(br, stridePath{}, 0, false)
+
+	if br.err != nil {
+		return br.n, br.err
+	}
+
+	if uint64(count4) != size4 || uint64(count6) != size6 {
+		return br.n, fmt.Errorf("%w: size mismatch, got (%d, %d), want (%d, %d)",
+			ErrBinaryFormat, count4, count6, size4, size6)
+	}
+
+	t := &l.Table
+	t.gen++
+	t.root4 = root4
+	t.root6 = root6
+	t.shared.Store(false)
+	t.sources = nil
+	t.size4 = count4
+	t.size6 = count6
+	t.missFilterRebuild()
+
+	return br.n, nil
+}
+
+// readLiteRec, read the node and all descendants in pre-order,
+// returns the number of prefixes read.
+func (n *node[V]) readLiteRec(br *binaryReader, path stridePath, depth int, is4 bool) (count int) {
+	// only IPv6 nodes may be 16 levels deep
+	if (is4 && depth >= 4) || depth >= maxTreeDepth {
+		br.fail(fmt.Errorf("%w: node too deep at depth %d", ErrBinaryFormat, depth))
+		return
+	}
+
+	pfxBits := br.sparseBitset()
+	if br.err != nil {
+		return
+	}
+
+	// idx 0 is no valid baseIndex
+	if pfxBits.Test(0) {
+		br.fail(fmt.Errorf("%w: invalid prefix index 0", ErrBinaryFormat))
+		return
+	}
+
+	var zero V
+	n.prefixes.BitSet256 = pfxBits
+	n.prefixes.Items = make([]V, pfxBits.Count())
+	count += len(n.prefixes.Items)
+
+	kidBits := br.sparseBitset()
+	addrs := kidBits.AsSlice(&[256]uint8{})
+
+	n.children.BitSet256 = kidBits
+	n.children.Items = make([]any, 0, len(addrs))
+
+	for _, addr := range addrs {
+		kind := br.scratch[:1]
+		if br.read(kind); br.err != nil {
+			return
+		}
+
+		switch {
+		case kind[0] == liteKindNode:
+			kid := new(node[V])
+			path[depth] = addr
+			count += kid.readLiteRec(br, path, depth+1, is4)
+			n.children.Items = append(n.children.Items, kid)
+
+			// subtries must not be empty
+			if br.err == nil && kid.isEmpty() {
+				br.fail(fmt.Errorf("%w: empty node at depth %d", ErrBinaryFormat, depth+1))
+			}
+
+		case kind[0] == liteKindFringe:
+			n.children.Items = append(n.children.Items, newFringeNode(zero))
+			count++
+
+		default:
+			pfx := br.litePrefix(path, depth, addr, is4, int(kind[0]-liteKindLeaf))
+			n.children.Items = append(n.children.Items, newLeafNode(pfx, zero))
+			count++
+		}
+
+		if br.err != nil {
+			return
+		}
+	}
+
+	return count
+}
+
+func (bw *binaryWriter) sparseBitset(bs *bitset.BitSet256) {
+	if bs.Count() > liteSparseMax {
+		bw.kind(liteRawBitset)
+		bw.bitset(bs)
+		return
+	}
+
+	buf := [1 + liteSparseMax]byte{}
+	idxs := bs.AsSlice(&[256]uint8{})
+
+	buf[0] = byte(len(idxs))
+	copy(buf[1:], idxs)
+	bw.write(buf[:1+len(idxs)])
+}
+
+func (br *binaryReader) sparseBitset() (bs bitset.BitSet256) {
+	buf := br.scratch[:1]
+	if br.read(buf); br.err != nil {
+		return
+	}
+
+	count := int(buf[0])
+	if count == liteRawBitset {
+		return br.bitset()
+	}
+
+	if count > liteSparseMax {
+		br.fail(fmt.Errorf("%w: invalid bitset count %d", ErrBinaryFormat, count))
+		return
+	}
+
+	idxs := br.scratch[:count]
+	if br.read(idxs); br.err != nil {
+		return
+	}
+
+	for i, idx := range idxs {
+		if i > 0 && idx <= idxs[i-1] {
+			br.fail(fmt.Errorf("%w: bitset indices not ascending", ErrBinaryFormat))
+			return
+		}
+		bs.Set(idx)
+	}
+
+	return bs
+}
+
+// litePrefix, read the octets of a leaf after the child octet at depth
+// and build the prefix with the path.
+func (br *binaryReader) litePrefix(path stridePath, depth int, addr uint8, is4 bool, bits int) netip.Prefix {
+	maxBits := 128
+	if is4 {
+		maxBits = 32
+	}
+
+	// leaves have more bits than the fringe at this depth
+	if bits <= (depth+1)<<3 || bits > maxBits {
+		br.fail(fmt.Errorf("%w: invalid leaf prefix length %d at depth %d", ErrBinaryFormat, bits, depth))
+		return netip.Prefix{}
+	}
+
+	path[depth] = addr
+	octets := path[depth+1 : (bits+7)>>3]
+	if br.read(octets); br.err != nil {
+		return netip.Prefix{}
+	}
+
+	for i := (bits + 7) >> 3; i < len(path); i++ {
+		path[i] = 0
+	}
+
+	var ip netip.Addr
+	if is4 {
+		ip = netip.AddrFrom4([4]byte(path[:4]))
+	} else {
+		ip = netip.AddrFrom16(path)
+	}
+
+	pfx := netip.PrefixFrom(ip, bits)
+	if pfx != pfx.Masked() {
+		br.fail(fmt.Errorf("%w: invalid leaf prefix %s", ErrBinaryFormat, pfx))
+	}
+
+	return pfx
+}
+
+// Equal reports whether both Lite tables hold the same set of prefixes,
+// see [SamePrefixes].
+func (l *Lite) Equal(o *Lite) bool {
+	var a, b *Table[struct{}]
+	if l != nil {
+		a = &l.Table
+	}
+	if o != nil {
+		b = &o.Table
+	}
+	return SamePrefixes(a, b)
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"container/heap"
+	"net/netip"
+)
+
+// MergeSorted returns an iterator over all prefix–value pairs of the given
+// tables in canonical CIDR sort order, a k-way merge of the AllSorted
+// sequences of the tables, e.g. to display several RIBs as one view.
+//
+// A prefix stored in several tables is yielded once for each table,
+// in the order of the tables in the argument list. Nil tables are skipped.
+//
+// Without iter.Pull in the supported Go versions, the sorted sequence
+// of each table is buffered before merging with a heap over the tables,
+// the memory is proportional to the sum of the table sizes.
+func MergeSorted[V any](tables ...*Table[V]) func(yield func(netip.Prefix, V) bool) {
+	return func(yield func(netip.Prefix, V) bool) {
+		h := make(mergeHeap[V], 0, len(tables))
+
+		for i, t := range tables {
+			if t == nil || t.Size() == 0 {
+				continue
+			}
+
+			items := make([]mergeItem[V], 0, t.Size())
+			t.AllSorted()(func(pfx netip.Prefix, val V) bool {
+				items = append(items, mergeItem[V]{pfx, val})
+				return true
+			})
+
+			h = append(h, &mergeCursor[V]{items: items, table: i})
+		}
+
+		heap.Init(&h)
+
+		for len(h) > 0 {
+			c := h[0]
+			item := c.items[c.pos]
+
+			if !yield(item.pfx, item.val) {
+				return
+			}
+
+			// advance the cursor, drop it if exhausted
+			if c.pos++; c.pos < len(c.items) {
+				heap.Fix(&h, 0)
+			} else {
+				heap.Pop(&h)
+			}
+		}
+	}
+}
+
+// mergeItem is a buffered prefix–value pair.
+type mergeItem[V any] struct {
+	pfx netip.Prefix
+	val V
+}
+
+// mergeCursor is the position in the sorted items of one table.
+type mergeCursor[V any] struct {
+	items []mergeItem[V]
+	pos   int
+	table int // the tie-break for equal prefixes
+}
+
+// mergeHeap implements heap.Interface, the smallest current prefix on top.
+type mergeHeap[V any] []*mergeCursor[V]
+
+func (h mergeHeap[V]) Len() int { return len(h) }
+
+func (h mergeHeap[V]) Less(i, j int) bool {
+	a, b := h[i].items[h[i].pos].pfx, h[j].items[h[j].pos].pfx
+	if a == b {
+		return h[i].table < h[j].table
+	}
+	return lessPrefix(a, b)
+}
+
+func (h mergeHeap[V]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap[V]) Push(x any) { *h = append(*h, x.(*mergeCursor[V])) }
+
+func (h *mergeHeap[V]) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
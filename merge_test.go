@@ -0,0 +1,87 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"math/rand"
+	"net/netip"
+	"sort"
+	"testing"
+)
+
+func TestMergeSorted(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 3_000)
+
+	// overlapping slices, the middle third is in all tables
+	tables := []*Table[int]{new(Table[int]), nil, new(Table[int]), new(Table[int])}
+	for i, item := range pfxs {
+		if i < 2_000 {
+			tables[0].Insert(item.pfx, 0)
+		}
+		if i >= 1_000 {
+			tables[2].Insert(item.pfx, 2)
+		}
+		if i >= 1_000 && i < 2_000 {
+			tables[3].Insert(item.pfx, 3)
+		}
+	}
+
+	type entry struct {
+		pfx netip.Prefix
+		val int
+	}
+
+	var got []entry
+	MergeSorted(tables...)(func(pfx netip.Prefix, val int) bool {
+		got = append(got, entry{pfx, val})
+		return true
+	})
+
+	// gold: concatenate and stable sort, ties in table order
+	var want []entry
+	for _, tbl := range tables {
+		if tbl == nil {
+			continue
+		}
+		tbl.All()(func(pfx netip.Prefix, val int) bool {
+			want = append(want, entry{pfx, val})
+			return true
+		})
+	}
+	sort.SliceStable(want, func(i, j int) bool {
+		if want[i].pfx == want[j].pfx {
+			return want[i].val < want[j].val
+		}
+		return lessPrefix(want[i].pfx, want[j].pfx)
+	})
+
+	if len(got) != len(want) {
+		t.Fatalf("MergeSorted, got %d entries, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("MergeSorted, entry %d: %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	// early exit
+	n := 0
+	MergeSorted(tables...)(func(netip.Prefix, int) bool {
+		n++
+		return n < 10
+	})
+	if n != 10 {
+		t.Errorf("MergeSorted, early exit after %d entries, want 10", n)
+	}
+
+	// no tables
+	MergeSorted[int]()(func(netip.Prefix, int) bool {
+		t.Fatalf("MergeSorted(), yielded an entry")
+		return false
+	})
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import "net/netip"
+
+// WithMeta is a payload with an additional uint64 metadata slot per prefix,
+// e.g. the route age as epoch, for a Table[WithMeta[V]].
+//
+// There is no native metadata slot in the trie nodes, it would cost
+// 8 bytes per prefix for all tables, even without metadata. With WithMeta
+// only the tables that need metadata pay for it: 8 bytes per stored value,
+// plus the padding of V to the alignment of uint64, e.g. 16 instead of
+// 8 bytes for a V of type int, 16 instead of 1 byte for a bool.
+//
+// Use [InsertWithMeta] and [GetMeta], all other methods of the
+// table work as usual on the combined payload.
+type WithMeta[V any] struct {
+	Val  V
+	Meta uint64
+}
+
+// InsertWithMeta inserts pfx with val and the metadata meta into t,
+// see [Table.Insert].
+func InsertWithMeta[V any](t *Table[WithMeta[V]], pfx netip.Prefix, val V, meta uint64) {
+	t.Insert(pfx, WithMeta[V]{Val: val, Meta: meta})
+}
+
+// GetMeta returns the metadata for the exact pfx and true,
+// or false if pfx is not set in t, see [Table.Get].
+func GetMeta[V any](t *Table[WithMeta[V]], pfx netip.Prefix) (meta uint64, ok bool) {
+	wm, ok := t.Get(pfx)
+	return wm.Meta, ok
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"testing"
+)
+
+func TestWithMeta(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[WithMeta[string]])
+	InsertWithMeta(tbl, mpp("10.0.0.0/8"), "a", 1000)
+	InsertWithMeta(tbl, mpp("2001:db8::/32"), "b", 2000)
+
+	if meta, ok := GetMeta(tbl, mpp("10.0.0.0/8")); !ok || meta != 1000 {
+		t.Errorf("GetMeta(10.0.0.0/8) = (%d, %v), want (1000, true)", meta, ok)
+	}
+
+	if meta, ok := GetMeta(tbl, mpp("10.0.0.0/9")); ok || meta != 0 {
+		t.Errorf("GetMeta(10.0.0.0/9) = (%d, %v), want (0, false)", meta, ok)
+	}
+
+	// overwrite with a new epoch
+	InsertWithMeta(tbl, mpp("2001:db8::/32"), "c", 3000)
+	if meta, ok := GetMeta(tbl, mpp("2001:db8::/32")); !ok || meta != 3000 {
+		t.Errorf("GetMeta(2001:db8::/32) = (%d, %v), want (3000, true)", meta, ok)
+	}
+
+	// the regular methods work on the combined payload
+	if wm, ok := tbl.Lookup(mpa("10.1.2.3")); !ok || wm.Val != "a" || wm.Meta != 1000 {
+		t.Errorf("Lookup(10.1.2.3) = (%v, %v), want ({a 1000}, true)", wm, ok)
+	}
+}
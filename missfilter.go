@@ -0,0 +1,143 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import "net/netip"
+
+// missFilter is an optional negative cache in front of [Table.Contains].
+//
+// For each IP version it keeps a bitmap of all /16 blocks (the first two octets)
+// covered by any prefix in the table. An address in an unmarked /16 block
+// can't match any route, Contains rejects it without a trie descent.
+//
+// The filter is conservative: bits are only set on insert, never cleared
+// on delete. A stale bit just costs a regular trie descent, but a missing
+// bit would be a false negative. [Table.Recompress] rebuilds the filter.
+type missFilter struct {
+	v4 [1 << 16 / 64]uint64
+	v6 [1 << 16 / 64]uint64
+}
+
+// NewTableMissFilter returns an empty table with a negative cache for misses.
+//
+// Use it for workloads dominated by misses, e.g. scrubbing centers.
+// [Table.Contains] rejects addresses outside of all covered /16 blocks
+// without descending the trie. It never rejects a covered address.
+//
+// The filter costs 16KB per table and table version, the ...Persist
+// methods copy it on write.
+func NewTableMissFilter[V any]() *Table[V] {
+	return &Table[V]{filter: new(missFilter)}
+}
+
+// bitmap, get the bitmap for the ip version.
+func (f *missFilter) bitmap(is4 bool) *[1 << 16 / 64]uint64 {
+	if is4 {
+		return &f.v4
+	}
+	return &f.v6
+}
+
+// add marks all /16 blocks covered by the canonicalized pfx.
+func (f *missFilter) add(pfx netip.Prefix) {
+	if f == nil {
+		return
+	}
+
+	ip := pfx.Addr()
+	bm := f.bitmap(ip.Is4())
+
+	octets := ip.AsSlice()
+	first := uint(octets[0])<<8 | uint(octets[1])
+
+	// a prefix shorter than /16 covers a range of blocks
+	last := first
+	if bits := pfx.Bits(); bits < 16 {
+		last = first + 1<<(16-bits) - 1
+	}
+
+	for key := first; key <= last; key++ {
+		bm[key>>6] |= 1 << (key & 63)
+	}
+}
+
+// addHost marks the /16 block of the host route ip.
+func (f *missFilter) addHost(ip netip.Addr) {
+	if f == nil {
+		return
+	}
+
+	bm := f.bitmap(ip.Is4())
+
+	octets := ip.AsSlice()
+	key := uint(octets[0])<<8 | uint(octets[1])
+
+	bm[key>>6] |= 1 << (key & 63)
+}
+
+// test reports whether the /16 block of ip may be covered by any route.
+func (f *missFilter) test(ip netip.Addr) bool {
+	var key uint
+	var bm *[1 << 16 / 64]uint64
+
+	if ip.Is4() {
+		a4 := ip.As4()
+		key = uint(a4[0])<<8 | uint(a4[1])
+		bm = &f.v4
+	} else {
+		a16 := ip.As16()
+		key = uint(a16[0])<<8 | uint(a16[1])
+		bm = &f.v6
+	}
+
+	return bm[key>>6]&(1<<(key&63)) != 0
+}
+
+// clone returns a copy of the filter, nil stays nil.
+func (f *missFilter) clone() *missFilter {
+	if f == nil {
+		return nil
+	}
+
+	c := *f
+	return &c
+}
+
+// union marks all blocks from table o.
+func (f *missFilter) union(o *missFilter) {
+	for i := range f.v4 {
+		f.v4[i] |= o.v4[i]
+		f.v6[i] |= o.v6[i]
+	}
+}
+
+// missFilterUnion merges the covered blocks from table o into the filter of t.
+func (t *Table[V]) missFilterUnion(o *Table[V]) {
+	if t.filter == nil {
+		return
+	}
+
+	if o.filter != nil {
+		t.filter.union(o.filter)
+		return
+	}
+
+	o.All()(func(pfx netip.Prefix, _ V) bool {
+		t.filter.add(pfx)
+		return true
+	})
+}
+
+// missFilterRebuild recomputes the filter from the current table content.
+func (t *Table[V]) missFilterRebuild() {
+	if t.filter == nil {
+		return
+	}
+
+	*t.filter = missFilter{}
+	t.All()(func(pfx netip.Prefix, _ V) bool {
+		t.filter.add(pfx)
+		return true
+	})
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+func TestMissFilterCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 1_000)
+
+	fast := NewTableMissFilter[int]()
+	gold := new(Table[int])
+
+	check := func(tbl *Table[int], what string) {
+		t.Helper()
+		for i := 0; i < 10_000; i++ {
+			ip := randomAddr(prng)
+			if got, want := tbl.Contains(ip), gold.Contains(ip); got != want {
+				t.Fatalf("%s: Contains(%s) = %v, want %v", what, ip, got, want)
+			}
+		}
+	}
+
+	for _, pfx := range pfxs[:500] {
+		fast.Insert(pfx.pfx, pfx.val)
+		gold.Insert(pfx.pfx, pfx.val)
+	}
+	check(fast, "Insert")
+
+	for _, pfx := range pfxs[:250] {
+		fast.Delete(pfx.pfx)
+		gold.Delete(pfx.pfx)
+	}
+	check(fast, "Delete")
+
+	fast.Recompress()
+	check(fast, "Recompress")
+
+	// persistent versions must copy-on-write the filter
+	pt := fast
+	for _, pfx := range pfxs[500:750] {
+		pt = pt.InsertPersist(pfx.pfx, pfx.val)
+		gold.Insert(pfx.pfx, pfx.val)
+	}
+	check(pt, "InsertPersist")
+
+	for _, pfx := range pfxs[750:800] {
+		pt, _ = pt.UpdatePersist(pfx.pfx, func(int, bool) int { return pfx.val })
+		gold.Insert(pfx.pfx, pfx.val)
+	}
+	check(pt.Clone(), "UpdatePersist, Clone")
+
+	other := new(Table[int])
+	for _, pfx := range pfxs[800:] {
+		other.Insert(pfx.pfx, pfx.val)
+		gold.Insert(pfx.pfx, pfx.val)
+	}
+	check(pt.UnionPersist(other), "UnionPersist")
+
+	pt.Union(other)
+	check(pt, "Union")
+}
+
+func TestMissFilterRejects(t *testing.T) {
+	t.Parallel()
+
+	tbl := NewTableMissFilter[int]()
+	tbl.Insert(mpp("10.1.0.0/24"), 1)
+	tbl.Insert(mpp("172.16.0.0/12"), 2)
+	tbl.Insert(mpp("2001:db8::/32"), 3)
+
+	// covered /16 blocks
+	for _, ip := range []netip.Addr{mpa("10.1.0.1"), mpa("10.1.1.1"), mpa("172.31.255.255"), mpa("2001:db8::1")} {
+		if !tbl.filter.test(ip) {
+			t.Errorf("filter.test(%s), expected hit", ip)
+		}
+	}
+
+	// uncovered /16 blocks
+	for _, ip := range []netip.Addr{mpa("10.2.0.1"), mpa("172.32.0.0"), mpa("2002::1")} {
+		if tbl.filter.test(ip) {
+			t.Errorf("filter.test(%s), expected miss", ip)
+		}
+		if tbl.Contains(ip) {
+			t.Errorf("Contains(%s), want false", ip)
+		}
+	}
+
+	// the filter is conservative, delete doesn't clear the blocks ...
+	tbl.Delete(mpp("10.1.0.0/24"))
+	if !tbl.filter.test(mpa("10.1.0.1")) {
+		t.Errorf("filter.test(10.1.0.1) after Delete, want true")
+	}
+
+	// ... but Recompress rebuilds the filter
+	tbl.Recompress()
+	if tbl.filter.test(mpa("10.1.0.1")) {
+		t.Errorf("filter.test(10.1.0.1) after Recompress, want false")
+	}
+}
+
+func BenchmarkMissFilter(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+
+	rt := new(Table[int])
+	ft := NewTableMissFilter[int]()
+
+	for i, pfx := range randomRealWorldPrefixes(prng, 10_000) {
+		rt.Insert(pfx, i)
+		ft.Insert(pfx, i)
+	}
+
+	var probe netip.Addr
+	for {
+		probe = randomAddr(prng)
+		if !ft.filter.test(probe) {
+			break
+		}
+	}
+
+	b.Run("Contains", func(b *testing.B) {
+		for j := 0; j < b.N; j++ {
+			boolSink = rt.Contains(probe)
+		}
+	})
+
+	b.Run("ContainsMissFilter", func(b *testing.B) {
+		for j := 0; j < b.N; j++ {
+			boolSink = ft.Contains(probe)
+		}
+	})
+}
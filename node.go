@@ -4,8 +4,10 @@
 package bart
 
 import (
+	"fmt"
 	"net/netip"
 	"sort"
+	"unsafe"
 
 	"github.com/metacubex/bart/internal/art"
 	"github.com/metacubex/bart/internal/lpm"
@@ -120,12 +122,26 @@ func isFringe(depth, bits int) bool {
 // The function walks the prefix address from the given depth and inserts the value either directly into
 // the node´s prefix table or as a compressed leaf or fringe node. If a conflicting leaf or fringe exists,
 // it is pushed down via a new intermediate node. Existing entries with the same prefix are overwritten.
+//
+// The pfx must be valid and canonical, this is checked by the public callers.
+// A pfx that doesn't fit at the start depth panics with a diagnostic message.
 func (n *node[V]) insertAtDepth(pfx netip.Prefix, val V, depth int) (exists bool) {
+	return n.mergeAtDepth(pfx, val, depth, nil)
+}
+
+// mergeAtDepth is insertAtDepth, but an existing value for pfx is combined
+// with val by merge, if not nil, see unionRec.
+func (n *node[V]) mergeAtDepth(pfx netip.Prefix, val V, depth int, merge mergeFunc[V]) (exists bool) {
 	ip := pfx.Addr() // the pfx must be in canonical form
 	bits := pfx.Bits()
 	octets := ip.AsSlice()
 	maxDepth, lastBits := maxDepthAndLastBits(bits)
 
+	if depth > maxDepth || depth >= len(octets) {
+		panic(malformedPrefix("insertAtDepth", pfx, depth))
+	}
+	startDepth := depth
+
 	// find the proper trie node to insert prefix
 	// start with prefix octet at depth
 	for ; depth < len(octets); depth++ {
@@ -133,7 +149,17 @@ func (n *node[V]) insertAtDepth(pfx netip.Prefix, val V, depth int) (exists bool
 
 		// last masked octet: insert/override prefix/val into node
 		if depth == maxDepth {
-			return n.prefixes.InsertAt(art.PfxToIdx(octet, lastBits), val)
+			idx := art.PfxToIdx(octet, lastBits)
+			if merge == nil {
+				return n.prefixes.InsertAt(idx, val)
+			}
+			_, exists = n.prefixes.UpdateAt(idx, func(old V, ok bool) V {
+				if ok {
+					return merge(pfx, old, val)
+				}
+				return val
+			})
+			return exists
 		}
 
 		// reached end of trie path ...
@@ -158,6 +184,9 @@ func (n *node[V]) insertAtDepth(pfx netip.Prefix, val V, depth int) (exists bool
 			// reached a path compressed prefix
 			// override value in slot if prefixes are equal
 			if kid.prefix == pfx {
+				if merge != nil {
+					val = merge(pfx, kid.value, val)
+				}
 				kid.value = val
 				// exists
 				return true
@@ -177,6 +206,9 @@ func (n *node[V]) insertAtDepth(pfx netip.Prefix, val V, depth int) (exists bool
 			// reached a path compressed fringe
 			// override value in slot if pfx is a fringe
 			if isFringe(depth, bits) {
+				if merge != nil {
+					val = merge(pfx, kid.value, val)
+				}
 				kid.value = val
 				// exists
 				return true
@@ -197,9 +229,82 @@ func (n *node[V]) insertAtDepth(pfx netip.Prefix, val V, depth int) (exists bool
 		}
 	}
 
+	panic(malformedPrefix("insertAtDepth", pfx, startDepth))
+}
+
+// insertHost inserts ip as host route (/32 or /128) with val.
+//
+// A host route never ends in the prefixes of a node, it's always stored
+// path-compressed as fringe in the last octet or as leaf above.
+// The leaf prefix is only built if a new leaf is created.
+// The ip must be valid and without zone.
+func (n *node[V]) insertHost(ip netip.Addr, val V) (exists bool) {
+	octets := ip.AsSlice()
+	lastDepth := len(octets) - 1
+
+	for depth, octet := range octets {
+		// reached end of trie path ...
+		if !n.children.Test(octet) {
+			// insert host route path compressed as fringe or leaf
+			if depth == lastDepth {
+				return n.children.InsertAt(octet, newFringeNode(val))
+			}
+			return n.children.InsertAt(octet, newLeafNode(netip.PrefixFrom(ip, ip.BitLen()), val))
+		}
+
+		// ... or decend down the trie
+		kid := n.children.MustGet(octet)
+
+		// kid is node or leaf or fringe at octet
+		switch kid := kid.(type) {
+		case *node[V]:
+			n = kid
+			continue // descend down to next trie level
+
+		case *leafNode[V]:
+			// override value in slot if it's the same host route
+			if kid.prefix.Bits() == ip.BitLen() && kid.prefix.Addr() == ip {
+				kid.value = val
+				return true
+			}
+
+			// push the leaf down, descend down
+			newNode := new(node[V])
+			newNode.insertAtDepth(kid.prefix, kid.value, depth+1)
+
+			n.children.InsertAt(octet, newNode)
+			n = newNode
+
+		case *fringeNode[V]:
+			// override value in slot if the fringe is the host route
+			if depth == lastDepth {
+				kid.value = val
+				return true
+			}
+
+			// push the fringe down, it becomes a default route (idx=1)
+			newNode := new(node[V])
+			newNode.prefixes.InsertAt(1, kid.value)
+
+			n.children.InsertAt(octet, newNode)
+			n = newNode
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
 	panic("unreachable")
 }
 
+// malformedPrefix returns the diagnostic panic message for a prefix
+// that runs off the end of the trie, instead of a bare "unreachable".
+// Valid and canonical prefixes always fit, it's a logic error of the caller.
+func malformedPrefix(op string, pfx netip.Prefix, depth int) string {
+	return fmt.Sprintf("bart: logic error, %s: malformed or non-canonical prefix %s, bits %d, start depth %d",
+		op, pfx, pfx.Bits(), depth)
+}
+
 // purgeAndCompress traverses the deletion path upward and removes empty or compressible nodes
 // in the trie.
 //
@@ -278,6 +383,68 @@ func (n *node[V]) purgeAndCompress(stack []*node[V], octets []uint8, is4 bool) {
 	}
 }
 
+// recompressRec walks the whole subtrie bottom-up and applies the same purge
+// and compress rules as purgeAndCompress to every child node, not only along
+// a single delete path.
+//
+// The descendants are compressed first, so a node may become compressible
+// after its own children have been lifted up.
+// It returns the number of reclaimed nodes.
+func (n *node[V]) recompressRec(path stridePath, depth int, is4 bool) (reclaimed int) {
+	// the children are modified in the loop, iterate over a copy of the addrs
+	for _, addr := range n.children.AsSlice(&[256]uint8{}) {
+		kid, ok := n.children.MustGet(addr).(*node[V])
+		if !ok {
+			continue
+		}
+
+		path[depth] = addr
+		reclaimed += kid.recompressRec(path, depth+1, is4)
+
+		pfxCount := kid.prefixes.Len()
+		childCount := kid.children.Len()
+
+		switch {
+		case kid.isEmpty():
+			// just delete this empty node
+			n.children.DeleteAt(addr)
+			reclaimed++
+
+		case pfxCount == 0 && childCount == 1:
+			switch grandKid := kid.children.Items[0].(type) {
+			case *node[V]:
+				// intermediate path node, not compressible
+				continue
+			case *leafNode[V]:
+				// just one leaf, delete the kid and reinsert the leaf here
+				n.children.DeleteAt(addr)
+				n.insertAtDepth(grandKid.prefix, grandKid.value, depth)
+			case *fringeNode[V]:
+				// just one fringe, delete the kid and reinsert the fringe as leaf here
+				n.children.DeleteAt(addr)
+
+				lastOctet, _ := kid.children.FirstSet()
+				fringePfx := cidrForFringe(path[:], depth+1, is4, lastOctet)
+
+				n.insertAtDepth(fringePfx, grandKid.value, depth)
+			}
+			reclaimed++
+
+		case pfxCount == 1 && childCount == 0:
+			// just one prefix, delete the kid and reinsert the idx as leaf here
+			n.children.DeleteAt(addr)
+
+			idx, _ := kid.prefixes.FirstSet()
+			pfx := cidrFromPath(path, depth+1, is4, idx)
+
+			n.insertAtDepth(pfx, kid.prefixes.Items[0], depth)
+			reclaimed++
+		}
+	}
+
+	return reclaimed
+}
+
 // lpmGet performs a longest-prefix match (LPM) lookup for the given index (idx)
 // within the 8-bit stride-based prefix table at this trie depth.
 //
@@ -324,8 +491,9 @@ func (n *node[V]) lpmTest(idx uint) bool {
 // representing path-compressed prefixes. IP prefix reconstruction is performed on-the-fly
 // from the current path and depth.
 //
-// The traversal order is not defined. This implementation favors simplicity
-// and runtime efficiency over consistency of iteration sequence.
+// The traversal order is not the CIDR sort order, prefixes are visited by index
+// and children by address as given by the bitsets. Hence the sequence is
+// deterministic for an unmodified trie, no maps are involved.
 func (n *node[V]) allRec(path stridePath, depth int, is4 bool, yield func(netip.Prefix, V) bool) bool {
 	for _, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
 		cidr := cidrFromPath(path, depth, is4, idx)
@@ -369,6 +537,401 @@ func (n *node[V]) allRec(path stridePath, depth int, is4 bool, yield func(netip.
 	return true
 }
 
+// allOfLengthRec is like allRec, but only for prefixes with exactly bits
+// mask length. The descent is pruned, the prefixes of the node at depth
+// bits/8 are the last candidates, leaves and fringes are filtered on the way.
+func (n *node[V]) allOfLengthRec(path stridePath, depth int, is4 bool, bits int, yield func(netip.Prefix, V) bool) bool {
+	// the prefixes of this node have bits in [depth*8, depth*8+7]
+	if bits>>3 == depth {
+		for _, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
+			if _, pfxLen := art.IdxToPfx(idx); int(pfxLen) != bits&7 {
+				continue
+			}
+
+			if !yield(cidrFromPath(path, depth, is4, idx), n.prefixes.MustGet(idx)) {
+				return false
+			}
+		}
+	}
+
+	// leaves and fringes in the child slots have bits >= (depth+1)*8
+	if bits < (depth+1)<<3 {
+		return true
+	}
+
+	for i, addr := range n.children.AsSlice(&[256]uint8{}) {
+		switch kid := n.children.Items[i].(type) {
+		case *node[V]:
+			path[depth] = addr
+			if !kid.allOfLengthRec(path, depth+1, is4, bits, yield) {
+				return false
+			}
+		case *leafNode[V]:
+			if kid.prefix.Bits() == bits && !yield(kid.prefix, kid.value) {
+				return false
+			}
+		case *fringeNode[V]:
+			if bits == (depth+1)<<3 && !yield(cidrForFringe(path[:], depth, is4, addr), kid.value) {
+				return false
+			}
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	return true
+}
+
+// first returns the first prefix of the subtrie in CIDR sort order,
+// the lowest address and the shortest prefix for equal addresses.
+// Just one path is descended, the node prefixes of each level
+// compete with the lowest child.
+func (n *node[V]) first(path stridePath, depth int, is4 bool) (pfx netip.Prefix, val V, ok bool) {
+	for {
+		// the node prefix with the lowest address, ascending idx
+		// yields the shorter prefixes first for equal addresses
+		var topIdx, topOctet uint8
+		pfxOK := false
+		for _, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
+			if octet, _ := art.IdxToPfx(idx); !pfxOK || octet < topOctet {
+				topIdx, topOctet, pfxOK = idx, octet, true
+			}
+		}
+
+		// a prefix beats all entries in a child with the same octet, they are longer
+		addr, kidOK := n.children.FirstSet()
+		if pfxOK && (!kidOK || topOctet <= addr) {
+			return cidrFromPath(path, depth, is4, topIdx), n.prefixes.MustGet(topIdx), true
+		}
+
+		if !kidOK {
+			return
+		}
+
+		switch kid := n.children.MustGet(addr).(type) {
+		case *node[V]:
+			path[depth] = addr
+			depth++
+			n = kid
+		case *leafNode[V]:
+			return kid.prefix, kid.value, true
+		case *fringeNode[V]:
+			return cidrForFringe(path[:], depth, is4, addr), kid.value, true
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+}
+
+// last returns the last prefix of the subtrie in CIDR sort order,
+// the highest address and the longest prefix for equal addresses,
+// see first.
+func (n *node[V]) last(path stridePath, depth int, is4 bool) (pfx netip.Prefix, val V, ok bool) {
+	for {
+		// the node prefix with the highest address, ascending idx
+		// yields the longer prefixes last for equal addresses
+		var topIdx, topOctet uint8
+		pfxOK := false
+		for _, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
+			if octet, _ := art.IdxToPfx(idx); !pfxOK || octet >= topOctet {
+				topIdx, topOctet, pfxOK = idx, octet, true
+			}
+		}
+
+		// all entries in a child with the same octet are longer than the prefix
+		addr, kidOK := n.children.LastSet()
+		if pfxOK && (!kidOK || topOctet > addr) {
+			return cidrFromPath(path, depth, is4, topIdx), n.prefixes.MustGet(topIdx), true
+		}
+
+		if !kidOK {
+			return
+		}
+
+		switch kid := n.children.MustGet(addr).(type) {
+		case *node[V]:
+			path[depth] = addr
+			depth++
+			n = kid
+		case *leafNode[V]:
+			return kid.prefix, kid.value, true
+		case *fringeNode[V]:
+			return cidrForFringe(path[:], depth, is4, addr), kid.value, true
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+}
+
+// setAllRec recursively overwrites all values in this node and all
+// descendants with val, the trie structure is untouched.
+func (n *node[V]) setAllRec(val V) {
+	for i := range n.prefixes.Items {
+		n.prefixes.Items[i] = val
+	}
+
+	for _, kid := range n.children.Items {
+		switch kid := kid.(type) {
+		case *node[V]:
+			kid.setAllRec(val)
+		case *leafNode[V]:
+			kid.value = val
+		case *fringeNode[V]:
+			kid.value = val
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+}
+
+// updateWhereRec recursively traverses the trie starting at the current node
+// and replaces in place every stored value where match returns true with newVal(old).
+//
+// It returns the number of replaced values.
+func (n *node[V]) updateWhereRec(path stridePath, depth int, is4 bool, match func(netip.Prefix, V) bool, newVal func(V) V) (count int) {
+	for i, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
+		cidr := cidrFromPath(path, depth, is4, idx)
+
+		if match(cidr, n.prefixes.Items[i]) {
+			n.prefixes.Items[i] = newVal(n.prefixes.Items[i])
+			count++
+		}
+	}
+
+	// for all children (nodes and leaves) in this node do ...
+	for i, addr := range n.children.AsSlice(&[256]uint8{}) {
+		switch kid := n.children.Items[i].(type) {
+		case *node[V]:
+			// rec-descent with this node
+			path[depth] = addr
+			count += kid.updateWhereRec(path, depth+1, is4, match, newVal)
+		case *leafNode[V]:
+			if match(kid.prefix, kid.value) {
+				kid.value = newVal(kid.value)
+				count++
+			}
+		case *fringeNode[V]:
+			fringePfx := cidrForFringe(path[:], depth, is4, addr)
+			if match(fringePfx, kid.value) {
+				kid.value = newVal(kid.value)
+				count++
+			}
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	return count
+}
+
+// subnetsMaxBits reports whether any prefix covered by pfxIdx,
+// in this node or below, has a prefix length <= maxBits.
+func (n *node[V]) subnetsMaxBits(depth int, pfxIdx uint8, maxBits int) bool {
+	pfxFirstAddr, pfxLastAddr := art.IdxToRange(pfxIdx)
+
+	for _, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
+		thisFirstAddr, thisLastAddr := art.IdxToRange(idx)
+
+		if thisFirstAddr >= pfxFirstAddr && thisLastAddr <= pfxLastAddr &&
+			int(art.PfxBits(depth, idx)) <= maxBits {
+			return true
+		}
+	}
+
+	for i, addr := range n.children.AsSlice(&[256]uint8{}) {
+		if addr >= pfxFirstAddr && addr <= pfxLastAddr &&
+			kidMaxBits[V](n.children.Items[i], depth, maxBits) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maxBitsRec reports whether any prefix in this node or below
+// has a prefix length <= maxBits.
+func (n *node[V]) maxBitsRec(depth int, maxBits int) bool {
+	// all prefixes in and below this node are at least depth*8 long
+	if depth<<3 > maxBits {
+		return false
+	}
+
+	// the lowest index is the shortest prefix in this node
+	if idx, ok := n.prefixes.FirstSet(); ok && int(art.PfxBits(depth, idx)) <= maxBits {
+		return true
+	}
+
+	for _, kid := range n.children.Items {
+		if kidMaxBits[V](kid, depth, maxBits) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// kidMaxBits, see maxBitsRec, kid is a child of a node at depth.
+func kidMaxBits[V any](kid any, depth int, maxBits int) bool {
+	switch kid := kid.(type) {
+	case *node[V]:
+		return kid.maxBitsRec(depth+1, maxBits)
+	case *leafNode[V]:
+		return kid.prefix.Bits() <= maxBits
+	case *fringeNode[V]:
+		return (depth+1)<<3 <= maxBits
+	default:
+		panic("logic error, wrong node type")
+	}
+}
+
+// hasPrefixLenRec reports whether any prefix in this node or below
+// has exactly the prefix length bits.
+func (n *node[V]) hasPrefixLenRec(depth int, bits int) bool {
+	// all prefixes in and below this node are at least depth*8 long
+	if depth<<3 > bits {
+		return false
+	}
+
+	// the prefixes in this stride have bits in [depth*8, depth*8+7]
+	if bits < (depth+1)<<3 {
+		for _, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
+			if int(art.PfxBits(depth, idx)) == bits {
+				return true
+			}
+		}
+	}
+
+	for _, kid := range n.children.Items {
+		switch kid := kid.(type) {
+		case *node[V]:
+			if kid.hasPrefixLenRec(depth+1, bits) {
+				return true
+			}
+		case *leafNode[V]:
+			if kid.prefix.Bits() == bits {
+				return true
+			}
+		case *fringeNode[V]:
+			if (depth+1)<<3 == bits {
+				return true
+			}
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	return false
+}
+
+// estimatedBytesRec returns the estimated heap memory referenced by n,
+// the items slices and all descendants, but not the node struct itself.
+func (n *node[V]) estimatedBytesRec() int {
+	var zero V
+	var kid any
+
+	bytes := cap(n.prefixes.Items)*int(unsafe.Sizeof(zero)) +
+		cap(n.children.Items)*int(unsafe.Sizeof(kid))
+
+	for _, kid := range n.children.Items {
+		switch kid := kid.(type) {
+		case *node[V]:
+			bytes += int(unsafe.Sizeof(*kid)) + kid.estimatedBytesRec()
+		case *leafNode[V]:
+			bytes += int(unsafe.Sizeof(*kid))
+		case *fringeNode[V]:
+			bytes += int(unsafe.Sizeof(*kid))
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	return bytes
+}
+
+// prefixLenRec counts all prefixes in the subtrie per prefix length into hist.
+func (n *node[V]) prefixLenRec(depth int, hist []int) {
+	for _, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
+		hist[art.PfxBits(depth, idx)]++
+	}
+
+	for _, kid := range n.children.Items {
+		switch kid := kid.(type) {
+		case *node[V]:
+			kid.prefixLenRec(depth+1, hist)
+		case *leafNode[V]:
+			hist[kid.prefix.Bits()]++
+		case *fringeNode[V]:
+			// fringe, bits are always /8, /16, /24, ...
+			hist[(depth+1)<<3]++
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+}
+
+// allRecWithDepth is like allRec, but the depth of the node holding the
+// prefix, or the leaf or fringe, is passed to the yield function.
+func (n *node[V]) allRecWithDepth(path stridePath, depth int, is4 bool, yield func(netip.Prefix, V, int) bool) bool {
+	for _, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
+		if !yield(cidrFromPath(path, depth, is4, idx), n.prefixes.MustGet(idx), depth) {
+			return false
+		}
+	}
+
+	for i, addr := range n.children.AsSlice(&[256]uint8{}) {
+		switch kid := n.children.Items[i].(type) {
+		case *node[V]:
+			path[depth] = addr
+			if !kid.allRecWithDepth(path, depth+1, is4, yield) {
+				return false
+			}
+		case *leafNode[V]:
+			if !yield(kid.prefix, kid.value, depth) {
+				return false
+			}
+		case *fringeNode[V]:
+			if !yield(cidrForFringe(path[:], depth, is4, addr), kid.value, depth) {
+				return false
+			}
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	return true
+}
+
+// countWhereRec is like allRec, but counts the prefixes and values
+// where pred returns true, without an intermediate yield closure.
+func (n *node[V]) countWhereRec(path stridePath, depth int, is4 bool, pred func(netip.Prefix, V) bool) (count int) {
+	for _, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
+		if pred(cidrFromPath(path, depth, is4, idx), n.prefixes.MustGet(idx)) {
+			count++
+		}
+	}
+
+	for i, addr := range n.children.AsSlice(&[256]uint8{}) {
+		switch kid := n.children.Items[i].(type) {
+		case *node[V]:
+			path[depth] = addr
+			count += kid.countWhereRec(path, depth+1, is4, pred)
+		case *leafNode[V]:
+			if pred(kid.prefix, kid.value) {
+				count++
+			}
+		case *fringeNode[V]:
+			if pred(cidrForFringe(path[:], depth, is4, addr), kid.value) {
+				count++
+			}
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	return count
+}
+
 // allRecSorted recursively traverses the trie in prefix-sorted order and applies
 // the given yield function to each stored prefix and value.
 //
@@ -477,6 +1040,170 @@ func (n *node[V]) allRecSorted(path stridePath, depth int, is4 bool, yield func(
 	return true
 }
 
+// allRecSortedDesc is like allRecSorted, but in reverse (descending)
+// canonical CIDR sort order.
+//
+// The per-node emission order of allRecSorted is just reversed, indices
+// and children are walked from the end, no slice is collected and reversed.
+func (n *node[V]) allRecSortedDesc(path stridePath, depth int, is4 bool, yield func(netip.Prefix, V) bool) bool {
+	// get slice of all child octets, sorted by addr
+	allChildAddrs := n.children.AsSlice(&[256]uint8{})
+
+	// get slice of all indexes, sorted by idx
+	allIndices := n.prefixes.AsSlice(&[256]uint8{})
+
+	// sort indices in reverse CIDR sort order
+	sort.Slice(allIndices, func(i, j int) bool {
+		return lessIndexRank(allIndices[j], allIndices[i])
+	})
+
+	// yield the child at position j (rec-descent)
+	yieldChild := func(j int) bool {
+		addr := allChildAddrs[j]
+
+		switch kid := n.children.Items[j].(type) {
+		case *node[V]:
+			path[depth] = addr
+			return kid.allRecSortedDesc(path, depth+1, is4, yield)
+		case *leafNode[V]:
+			return yield(kid.prefix, kid.value)
+		case *fringeNode[V]:
+			return yield(cidrForFringe(path[:], depth, is4, addr), kid.value)
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	childCursor := len(allChildAddrs) - 1
+
+	// yield indices and childs in reverse CIDR sort order
+	for _, pfxIdx := range allIndices {
+		pfxOctet, _ := art.IdxToPfx(pfxIdx)
+
+		// yield all childs after idx
+		for ; childCursor >= 0; childCursor-- {
+			if allChildAddrs[childCursor] < pfxOctet {
+				break
+			}
+
+			if !yieldChild(childCursor) {
+				return false
+			}
+		}
+
+		// yield the prefix for this idx
+		cidr := cidrFromPath(path, depth, is4, pfxIdx)
+		if !yield(cidr, n.prefixes.MustGet(pfxIdx)) {
+			return false
+		}
+	}
+
+	// yield the rest of leaves and nodes (rec-descent)
+	for ; childCursor >= 0; childCursor-- {
+		if !yieldChild(childCursor) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// allRecSortedFrom is like allRecSorted, but it skips all entries
+// before start in canonical CIDR sort order.
+//
+// Subtries ending before start are skipped without descent, subtries
+// starting at or after start are handed over to allRecSorted.
+// Only the subtrie containing start is walked with allRecSortedFrom.
+func (n *node[V]) allRecSortedFrom(path stridePath, depth int, is4 bool, start netip.Prefix, yield func(netip.Prefix, V) bool) bool {
+	// get slice of all child octets, sorted by addr
+	allChildAddrs := n.children.AsSlice(&[256]uint8{})
+
+	// get slice of all indexes, sorted by idx
+	allIndices := n.prefixes.AsSlice(&[256]uint8{})
+
+	// sort indices in CIDR sort order
+	sort.Slice(allIndices, func(i, j int) bool {
+		return lessIndexRank(allIndices[i], allIndices[j])
+	})
+
+	// yield the child at position j if not before start
+	yieldChild := func(j int) bool {
+		addr := allChildAddrs[j]
+
+		switch kid := n.children.Items[j].(type) {
+		case *node[V]:
+			// the whole subtrie is covered by the child's stride prefix
+			subPfx := cidrForFringe(path[:], depth, is4, addr)
+
+			// subtrie ends before start
+			if lastAddr(subPfx).Less(start.Addr()) {
+				return true
+			}
+
+			path[depth] = addr
+
+			// all entries in subtrie are more specific than subPfx
+			if !lessPrefix(subPfx, start) {
+				return kid.allRecSorted(path, depth+1, is4, yield)
+			}
+			return kid.allRecSortedFrom(path, depth+1, is4, start, yield)
+
+		case *leafNode[V]:
+			if lessPrefix(kid.prefix, start) {
+				return true
+			}
+			return yield(kid.prefix, kid.value)
+
+		case *fringeNode[V]:
+			fringePfx := cidrForFringe(path[:], depth, is4, addr)
+			if lessPrefix(fringePfx, start) {
+				return true
+			}
+			return yield(fringePfx, kid.value)
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	childCursor := 0
+
+	// yield indices and childs in CIDR sort order
+	for _, pfxIdx := range allIndices {
+		pfxOctet, _ := art.IdxToPfx(pfxIdx)
+
+		// yield all childs before idx
+		for ; childCursor < len(allChildAddrs); childCursor++ {
+			if allChildAddrs[childCursor] >= pfxOctet {
+				break
+			}
+
+			if !yieldChild(childCursor) {
+				return false
+			}
+		}
+
+		// yield the prefix for this idx
+		cidr := cidrFromPath(path, depth, is4, pfxIdx)
+		if lessPrefix(cidr, start) {
+			continue
+		}
+
+		if !yield(cidr, n.prefixes.MustGet(pfxIdx)) {
+			return false
+		}
+	}
+
+	// yield the rest of leaves and nodes (rec-descent)
+	for ; childCursor < len(allChildAddrs); childCursor++ {
+		if !yieldChild(childCursor) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // eachLookupPrefix performs a hierarchical lookup of all matching prefixes
 // in the current node’s 8-bit stride-based prefix table.
 //
@@ -558,7 +1285,7 @@ func (n *node[V]) eachSubnet(octets []byte, depth int, is4 bool, pfxIdx uint8, y
 		}
 	}
 
-	// 3. yield covered indices, pathcomp prefixes and childs in CIDR sort order
+	// 3. yield covered indices, leaves, fringes and childs in CIDR sort order
 
 	addrCursor := 0
 
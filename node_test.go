@@ -12,6 +12,8 @@ package bart
 import (
 	"fmt"
 	"math/rand"
+	"net/netip"
+	"strings"
 	"testing"
 
 	"github.com/metacubex/bart/internal/art"
@@ -59,6 +61,35 @@ func TestPrefixInsert(t *testing.T) {
 	}
 }
 
+func TestInsertAtDepthMalformed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pfx   netip.Prefix
+		depth int
+	}{
+		{netip.Prefix{}, 0},
+		{mpp("10.0.0.0/8"), 2},
+		{mpp("10.0.0.1/32"), 4},
+		{mpp("2001:db8::/32"), 5},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				r := recover()
+				msg, ok := r.(string)
+				if !ok || !strings.Contains(msg, "malformed") || !strings.Contains(msg, tt.pfx.String()) {
+					t.Errorf("insertAtDepth(%s, depth %d), expected diagnostic panic, got: %v", tt.pfx, tt.depth, r)
+				}
+			}()
+
+			n := new(node[int])
+			n.insertAtDepth(tt.pfx, 1, tt.depth)
+		}()
+	}
+}
+
 func TestPrefixDelete(t *testing.T) {
 	t.Parallel()
 	// Compare route deletion to our reference table.
@@ -0,0 +1,63 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import "net/netip"
+
+// ChangeOp is the kind of change reported to the [Table.OnChange] callback.
+type ChangeOp int
+
+const (
+	ChangeInsert ChangeOp = iota // new prefix inserted
+	ChangeUpdate                 // value of an existing prefix overwritten
+	ChangeDelete                 // prefix deleted, with the deleted value
+)
+
+// String implements Stringer for ChangeOp.
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangeInsert:
+		return "insert"
+	case ChangeUpdate:
+		return "update"
+	case ChangeDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// OnChange registers cb as observer for all in-place modifications of the
+// table, e.g. to keep an external index or cache in sync.
+// A nil cb removes the observer, the zero value Table has none.
+//
+// The callback runs synchronously in the goroutine of the mutation,
+// after the change is committed to the table, with the canonical prefix
+// and the new value, or the deleted value for [ChangeDelete].
+// The callback must not modify the table.
+//
+// Insert, Update, Delete and all their variants are reported, including
+// the per-prefix changes of Union, UnionFunc, MergeStrict, MergeLenient and UpdateWhere.
+// With an observer, Union falls back to per-prefix updates.
+//
+// Tables derived by Clone and the ...Persist methods don't inherit
+// the observer, they are new tables. Bulk loads by ReadBinary are not reported.
+func (t *Table[V]) OnChange(cb func(op ChangeOp, pfx netip.Prefix, val V)) {
+	t.onChange = cb
+}
+
+// notify the observer, if any.
+func (t *Table[V]) notify(op ChangeOp, pfx netip.Prefix, val V) {
+	if t.onChange != nil {
+		t.onChange(op, pfx, val)
+	}
+}
+
+// notifyHost is notify for the host route ip, the prefix is
+// only built if there is an observer.
+func (t *Table[V]) notifyHost(op ChangeOp, ip netip.Addr, val V) {
+	if t.onChange != nil {
+		t.onChange(op, netip.PrefixFrom(ip, ip.BitLen()), val)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestOnChangeEvents(t *testing.T) {
+	t.Parallel()
+
+	var events []string
+	tbl := new(Table[int])
+	tbl.OnChange(func(op ChangeOp, pfx netip.Prefix, val int) {
+		events = append(events, fmt.Sprintf("%s %s %d", op, pfx, val))
+	})
+
+	expect := func(what string, want ...string) {
+		t.Helper()
+		if !reflect.DeepEqual(events, want) {
+			t.Errorf("%s, events: %q, want %q", what, events, want)
+		}
+		events = nil
+	}
+
+	tbl.Insert(netip.MustParsePrefix("10.1.2.3/8"), 1)
+	expect("Insert", "insert 10.0.0.0/8 1")
+
+	tbl.Insert(mpp("10.0.0.0/8"), 2)
+	expect("Insert overwrite", "update 10.0.0.0/8 2")
+
+	tbl.InsertAddr(mpa("::1"), 3)
+	expect("InsertAddr", "insert ::1/128 3")
+
+	tbl.Update(mpp("10.0.0.0/8"), func(val int, _ bool) int { return val + 10 })
+	expect("Update", "update 10.0.0.0/8 12")
+
+	tbl.Update(mpp("192.168.0.0/16"), func(int, bool) int { return 4 })
+	expect("Update new", "insert 192.168.0.0/16 4")
+
+	tbl.Delete(mpp("172.16.0.0/12"))
+	expect("Delete missing")
+
+	tbl.Delete(mpp("::1/128"))
+	expect("Delete", "delete ::1/128 3")
+
+	tbl.UpdateWhere(
+		func(pfx netip.Prefix, _ int) bool { return pfx.Bits() == 16 },
+		func(val int) int { return val * 2 })
+	expect("UpdateWhere", "update 192.168.0.0/16 8")
+
+	other := new(Table[int])
+	other.Insert(mpp("10.0.0.0/8"), 5)
+	other.Insert(mpp("2001:db8::/32"), 6)
+
+	if dups := tbl.Union(other); dups != 1 {
+		t.Errorf("Union with observer, duplicates: %d, want 1", dups)
+	}
+	expect("Union", "update 10.0.0.0/8 5", "insert 2001:db8::/32 6")
+
+	tbl.DeleteMany([]netip.Prefix{mpp("10.0.0.0/8"), mpp("11.0.0.0/8")})
+	expect("DeleteMany", "delete 10.0.0.0/8 5")
+
+	// no observer
+	tbl.OnChange(nil)
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	expect("OnChange(nil)")
+
+	// derived tables don't inherit the observer
+	tbl.OnChange(func(op ChangeOp, pfx netip.Prefix, val int) {
+		events = append(events, fmt.Sprintf("%s %s %d", op, pfx, val))
+	})
+	tbl.Clone().Insert(mpp("11.0.0.0/8"), 1)
+	tbl.InsertPersist(mpp("12.0.0.0/8"), 1)
+	expect("Clone, InsertPersist")
+}
+
+func TestOnChangeSync(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	// external index, kept in sync by the observer
+	index := make(map[netip.Prefix]int)
+
+	tbl := new(Table[int])
+	tbl.OnChange(func(op ChangeOp, pfx netip.Prefix, val int) {
+		switch op {
+		case ChangeInsert, ChangeUpdate:
+			index[pfx] = val
+		case ChangeDelete:
+			delete(index, pfx)
+		}
+	})
+
+	pfxs := randomPrefixes(prng, 1_000)
+
+	other := new(Table[int])
+	for _, pfx := range pfxs[:10] {
+		other.Insert(pfx.pfx, pfx.val)
+	}
+
+	for i := 0; i < 10_000; i++ {
+		pfx := pfxs[prng.Intn(len(pfxs))]
+		switch prng.Intn(5) {
+		case 0, 1:
+			tbl.Insert(pfx.pfx, i)
+		case 2:
+			tbl.Delete(pfx.pfx)
+		case 3:
+			tbl.Update(pfx.pfx, func(val int, _ bool) int { return val + i })
+		case 4:
+			tbl.UnionFunc(other, func(_ netip.Prefix, a, b int) int { return a + b })
+		}
+	}
+
+	if len(index) != tbl.Size() {
+		t.Fatalf("OnChange, index size: %d, table size: %d", len(index), tbl.Size())
+	}
+
+	tbl.All()(func(pfx netip.Prefix, val int) bool {
+		if got, ok := index[pfx]; !ok || got != val {
+			t.Fatalf("OnChange, index[%s] = (%d, %v), want (%d, true)", pfx, got, ok, val)
+		}
+		return true
+	})
+}
@@ -375,3 +375,51 @@ func (n *node[V]) overlapsIdx(idx uint8) bool {
 	allotedHostRoutes := allot.IdxToFringeRoutes(idx)
 	return allotedHostRoutes.Intersects(&n.children.BitSet256)
 }
+
+// OverlapsMatrix returns the symmetric matrix of [Table.Overlaps] for all
+// pairs of tables, m[i][j] reports whether tables[i] and tables[j] overlap.
+// A nil or empty table overlaps nothing, not even itself.
+//
+// The tables are compared pairwise. A pre-pass computes the first octets
+// covered by each table from the root nodes, pairs with disjoint first
+// octets are skipped without descent.
+func OverlapsMatrix[V any](tables []*Table[V]) [][]bool {
+	// covered first octets per table and IP version
+	coarse := make([][2]bitset.BitSet256, len(tables))
+	for i, t := range tables {
+		if t != nil {
+			coarse[i] = [2]bitset.BitSet256{t.root4.coveredOctets(), t.root6.coveredOctets()}
+		}
+	}
+
+	m := make([][]bool, len(tables))
+	for i := range m {
+		m[i] = make([]bool, len(tables))
+	}
+
+	for i := range tables {
+		for j := i; j < len(tables); j++ {
+			if tables[i] == nil || tables[j] == nil {
+				continue
+			}
+
+			a, b := tables[i], tables[j]
+			ok := coarse[i][0].Intersects(&coarse[j][0]) && a.Overlaps4(b) ||
+				coarse[i][1].Intersects(&coarse[j][1]) && a.Overlaps6(b)
+
+			m[i][j], m[j][i] = ok, ok
+		}
+	}
+
+	return m
+}
+
+// coveredOctets returns the octets covered by any prefix or child
+// in this node, for the root node the first octets of all routes.
+func (n *node[V]) coveredOctets() (octets bitset.BitSet256) {
+	octets = n.children.BitSet256
+	for _, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
+		octets = octets.Union(allot.IdxToFringeRoutes(idx))
+	}
+	return octets
+}
@@ -98,6 +98,43 @@ func TestOverlapsCompare(t *testing.T) {
 	}
 }
 
+func TestOverlapsFamily(t *testing.T) {
+	t.Parallel()
+
+	t1, t2 := new(Table[int]), new(Table[int])
+
+	// only v6 overlaps
+	t1.Insert(mpp("10.0.0.0/8"), 1)
+	t1.Insert(mpp("2001:db8::/32"), 1)
+
+	t2.Insert(mpp("192.168.0.0/16"), 2)
+	t2.Insert(mpp("2001:db8:1::/48"), 2)
+
+	if t1.Overlaps4(t2) {
+		t.Errorf("Overlaps4 = true, want false")
+	}
+	if !t1.Overlaps6(t2) {
+		t.Errorf("Overlaps6 = false, want true")
+	}
+	if !t1.Overlaps(t2) {
+		t.Errorf("Overlaps = false, want true")
+	}
+
+	// now also v4 overlaps, but v6 doesn't anymore
+	t2.Insert(mpp("10.1.0.0/16"), 2)
+	t2.Delete(mpp("2001:db8:1::/48"))
+
+	if !t1.Overlaps4(t2) {
+		t.Errorf("Overlaps4 = false, want true")
+	}
+	if t1.Overlaps6(t2) {
+		t.Errorf("Overlaps6 = true, want false")
+	}
+	if !t1.Overlaps(t2) {
+		t.Errorf("Overlaps = false, want true")
+	}
+}
+
 func TestOverlapsPrefixCompare(t *testing.T) {
 	t.Parallel()
 	prng := rand.New(rand.NewSource(42))
@@ -120,6 +157,159 @@ func TestOverlapsPrefixCompare(t *testing.T) {
 	}
 }
 
+func TestOverlapsAnyCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	fast := new(Table[int])
+	for i, pfx := range randomRealWorldPrefixes(prng, 200) {
+		fast.Insert(pfx, i)
+	}
+
+	if fast.OverlapsAny(nil) {
+		t.Errorf("OverlapsAny(nil), want false")
+	}
+
+	if new(Table[int]).OverlapsAny([]netip.Prefix{mpp("::/0")}) {
+		t.Errorf("empty table, OverlapsAny, want false")
+	}
+
+	seen := map[bool]int{}
+	for i := 0; i < 10_000; i++ {
+		batch := randomRealWorldPrefixes(prng, 1+prng.Intn(5))
+
+		// add some subnets of the batch members
+		for _, pfx := range batch[:1] {
+			if sub, err := pfx.Addr().Prefix(pfx.Addr().BitLen()); err == nil {
+				batch = append(batch, sub)
+			}
+		}
+
+		want := false
+		for _, pfx := range batch {
+			if fast.OverlapsPrefix(pfx) {
+				want = true
+				break
+			}
+		}
+
+		if got := fast.OverlapsAny(batch); got != want {
+			t.Fatalf("OverlapsAny(%v) = %v, want %v", batch, got, want)
+		}
+		seen[want]++
+	}
+
+	if seen[true] == 0 || seen[false] == 0 {
+		t.Fatalf("OverlapsAny, no coverage of both results: %v", seen)
+	}
+}
+
+func TestOverlapsPrefixExceptCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomRealWorldPrefixes(prng, 200)
+
+	fast := new(Table[int])
+	for i, pfx := range pfxs {
+		fast.Insert(pfx, i)
+	}
+
+	seen := map[bool]int{}
+	for i := 0; i < 10_000; i++ {
+		// mostly stored routes, sometimes random ones
+		pfx := pfxs[prng.Intn(len(pfxs))]
+		if prng.Intn(2) == 0 {
+			pfx = randomRealWorldPrefixes(prng, 1)[0]
+		}
+
+		except := pfxs[prng.Intn(len(pfxs))]
+		switch prng.Intn(3) {
+		case 0:
+			except = pfx
+		case 1:
+			except = randomRealWorldPrefixes(prng, 1)[0]
+		}
+
+		want := false
+		fast.All()(func(p netip.Prefix, _ int) bool {
+			if p != except && p.Overlaps(pfx) {
+				want = true
+				return false
+			}
+			return true
+		})
+
+		if got := fast.OverlapsPrefixExcept(pfx, except); got != want {
+			t.Fatalf("OverlapsPrefixExcept(%s, %s) = %v, want %v", pfx, except, got, want)
+		}
+		seen[want]++
+	}
+
+	if seen[true] == 0 || seen[false] == 0 {
+		t.Errorf("OverlapsPrefixExcept, test cases not balanced: %v", seen)
+	}
+}
+
+func TestOverlapsPrefixMaxBitsCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomRealWorldPrefixes(prng, 1_000)
+
+	fast := new(Table[int])
+	for i, pfx := range pfxs {
+		fast.Insert(pfx, i)
+	}
+
+	// invalid maxBits
+	for _, tc := range []struct {
+		pfx     netip.Prefix
+		maxBits int
+	}{
+		{mpp("0.0.0.0/0"), -1},
+		{mpp("0.0.0.0/0"), 33},
+		{mpp("::/0"), 129},
+		{netip.Prefix{}, 0},
+	} {
+		if fast.OverlapsPrefixMaxBits(tc.pfx, tc.maxBits) {
+			t.Errorf("OverlapsPrefixMaxBits(%s, %d), want false", tc.pfx, tc.maxBits)
+		}
+	}
+
+	seen := map[bool]int{}
+	for i := 0; i < 10_000; i++ {
+		pfx := randomRealWorldPrefixes(prng, 1)[0]
+		if prng.Intn(2) == 0 {
+			pfx = pfxs[prng.Intn(len(pfxs))]
+		}
+
+		// shorten the prefix, more covered routes
+		bits := prng.Intn(pfx.Bits() + 1)
+		pfx = netip.PrefixFrom(pfx.Addr(), bits).Masked()
+
+		maxBits := prng.Intn(pfx.Addr().BitLen() + 1)
+
+		want := false
+		fast.All()(func(p netip.Prefix, _ int) bool {
+			if p.Bits() <= maxBits && p.Overlaps(pfx) {
+				want = true
+				return false
+			}
+			return true
+		})
+
+		if got := fast.OverlapsPrefixMaxBits(pfx, maxBits); got != want {
+			t.Fatalf("OverlapsPrefixMaxBits(%s, %d) = %v, want %v", pfx, maxBits, got, want)
+		}
+		seen[want]++
+	}
+
+	if seen[true] == 0 || seen[false] == 0 {
+		t.Errorf("OverlapsPrefixMaxBits, test cases not balanced: %v", seen)
+	}
+}
+
 func TestOverlapsChildren(t *testing.T) {
 	t.Parallel()
 	pfxs1 := []netip.Prefix{
@@ -191,3 +381,108 @@ func TestOverlapsChildren(t *testing.T) {
 		t.Fatal("tables unexpectedly do overlap")
 	}
 }
+
+func TestOverlapsMatrix(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	// mostly disjoint small tables, some nil and empty ones
+	tables := make([]*Table[int], 30)
+	for i := range tables {
+		switch i % 10 {
+		case 0:
+			continue // nil
+		case 1:
+			tables[i] = new(Table[int])
+			continue // empty
+		}
+
+		tbl := new(Table[int])
+		for _, item := range randomPrefixes(prng, 1+prng.Intn(5)) {
+			tbl.Insert(item.pfx, item.val)
+		}
+		tables[i] = tbl
+	}
+
+	m := OverlapsMatrix(tables)
+
+	for i := range tables {
+		for j := range tables {
+			want := false
+			if tables[i] != nil && tables[j] != nil {
+				want = tables[i].Overlaps(tables[j])
+			}
+			if m[i][j] != want {
+				t.Errorf("OverlapsMatrix[%d][%d] = %v, want %v", i, j, m[i][j], want)
+			}
+		}
+	}
+
+	if m := OverlapsMatrix[int](nil); len(m) != 0 {
+		t.Errorf("OverlapsMatrix(nil), len: %d, want 0", len(m))
+	}
+}
+
+func TestOverlapsDetail(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	for j := 0; j < 10_000; j++ {
+		tbl := new(Table[int])
+		for _, item := range randomPrefixes(prng, 6) {
+			tbl.Insert(item.pfx, item.val)
+		}
+
+		other := new(Table[int])
+		for _, item := range randomPrefixes(prng, 6) {
+			other.Insert(item.pfx, item.val)
+		}
+
+		a, b, ok := tbl.OverlapsDetail(other)
+
+		if want := tbl.Overlaps(other); ok != want {
+			t.Fatalf("OverlapsDetail(...), ok: %v, want %v", ok, want)
+		}
+		if !ok {
+			continue
+		}
+
+		if _, found := tbl.Get(a); !found {
+			t.Fatalf("OverlapsDetail(...), a: %s not in receiver", a)
+		}
+		if _, found := other.Get(b); !found {
+			t.Fatalf("OverlapsDetail(...), b: %s not in other", b)
+		}
+		if !a.Overlaps(b) {
+			t.Fatalf("OverlapsDetail(...), %s and %s don't overlap", a, b)
+		}
+	}
+
+	// fixed examples
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.1.0.0/16"), 1)
+	tbl.Insert(mpp("192.168.0.0/16"), 2)
+
+	tests := []struct {
+		other []string
+		a, b  netip.Prefix
+		ok    bool
+	}{
+		{[]string{"172.16.0.0/12"}, netip.Prefix{}, netip.Prefix{}, false},
+		{[]string{"10.0.0.0/8", "10.1.0.0/24"}, mpp("10.1.0.0/16"), mpp("10.0.0.0/8"), true},
+		{[]string{"192.168.3.0/24", "192.168.1.0/24"}, mpp("192.168.0.0/16"), mpp("192.168.1.0/24"), true},
+	}
+
+	for _, tt := range tests {
+		other := new(Table[int])
+		for _, s := range tt.other {
+			other.Insert(mpp(s), 0)
+		}
+
+		a, b, ok := tbl.OverlapsDetail(other)
+		if a != tt.a || b != tt.b || ok != tt.ok {
+			t.Errorf("OverlapsDetail(%v) = (%s, %s, %v), want (%s, %s, %v)",
+				tt.other, a, b, ok, tt.a, tt.b, tt.ok)
+		}
+	}
+}
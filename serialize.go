@@ -160,6 +160,51 @@ func (n *node[V]) fprintRec(w io.Writer, parent trieItem[V], pad string) error {
 	return nil
 }
 
+// FprintGrouped writes the CIDRs grouped by the string key of their payload V to w.
+//
+// The groups are sorted by key, every group is headed by its key and
+// the CIDRs within a group are in canonical CIDR sort order.
+//
+//	10.0.0.1:
+//	   10.0.0.0/8
+//	   192.168.0.0/16
+//	fe80::1:
+//	   ::/0
+//	   2001:db8::/32
+func (t *Table[V]) FprintGrouped(w io.Writer, key func(V) string) error {
+	if t == nil || w == nil {
+		return nil
+	}
+
+	// bucket the prefixes by key, AllSorted keeps each bucket CIDR-sorted
+	groups := make(map[string][]netip.Prefix)
+	t.AllSorted()(func(pfx netip.Prefix, val V) bool {
+		k := key(val)
+		groups[k] = append(groups[k], pfx)
+		return true
+	})
+
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "%s:\n", k); err != nil {
+			return err
+		}
+
+		for _, pfx := range groups[k] {
+			if _, err := fmt.Fprintf(w, "   %s\n", pfx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 // MarshalText implements the [encoding.TextMarshaler] interface,
 // just a wrapper for [Table.Fprint].
 func (t *Table[V]) MarshalText() ([]byte, error) {
@@ -6,6 +6,7 @@ package bart
 import (
 	"encoding/json"
 	"net/netip"
+	"strings"
 	"testing"
 )
 
@@ -192,6 +193,38 @@ func checkString(t *testing.T, tbl *Table[any], tt stringTest) {
 	}
 }
 
+func TestFprintGrouped(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[string])
+	tbl.Insert(mpp("192.168.0.0/16"), "10.0.0.1")
+	tbl.Insert(mpp("2001:db8::/32"), "fe80::1")
+	tbl.Insert(mpp("10.0.0.0/8"), "10.0.0.1")
+	tbl.Insert(mpp("::/0"), "fe80::1")
+	tbl.Insert(mpp("172.16.0.0/12"), "10.0.0.2")
+	tbl.Insert(mpp("10.0.0.0/24"), "10.0.0.2")
+
+	want := `10.0.0.1:
+   10.0.0.0/8
+   192.168.0.0/16
+10.0.0.2:
+   10.0.0.0/24
+   172.16.0.0/12
+fe80::1:
+   ::/0
+   2001:db8::/32
+`
+
+	w := new(strings.Builder)
+	if err := tbl.FprintGrouped(w, func(v string) string { return v }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := w.String(); got != want {
+		t.Errorf("FprintGrouped got:\n%swant:\n%s", got, want)
+	}
+}
+
 func TestJSONTableIsNil(t *testing.T) {
 	t.Parallel()
 	tt := jsonTest{
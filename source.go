@@ -0,0 +1,58 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import "net/netip"
+
+// UnionTagged is like [Table.Union], but additionally records tag as the
+// source of all prefixes from o, e.g. the id of a route import,
+// to debug conflicting imports later with [Table.Source].
+//
+// The tags are kept per prefix in a side table of the receiver, allocated
+// on first use, V is not wrapped. The tags aren't inherited by tables
+// derived by Clone and the ...Persist methods.
+func (t *Table[V]) UnionTagged(o *Table[V], tag int) (duplicates int) {
+	duplicates = t.Union(o)
+
+	if o.Size() == 0 {
+		return duplicates
+	}
+
+	if t.sources == nil {
+		t.sources = make(map[netip.Prefix]int, o.Size())
+	}
+
+	o.All()(func(pfx netip.Prefix, _ V) bool {
+		t.sources[pfx] = tag
+		return true
+	})
+
+	return duplicates
+}
+
+// Source returns the tag of the last [Table.UnionTagged] call that
+// contributed pfx to the table, or false if pfx isn't in the table
+// or wasn't contributed by UnionTagged.
+//
+// A delete forgets the tag, a later Insert or Update of the same prefix
+// by other methods doesn't change it.
+func (t *Table[V]) Source(pfx netip.Prefix) (tag int, ok bool) {
+	if t.sources == nil || !pfx.IsValid() {
+		return 0, false
+	}
+
+	pfx = pfx.Masked()
+	if tag, ok = t.sources[pfx]; !ok || !t.Has(pfx) {
+		return 0, false
+	}
+
+	return tag, true
+}
+
+// forgetSource removes the tag of the deleted canonical pfx, if any.
+func (t *Table[V]) forgetSource(pfx netip.Prefix) {
+	if t.sources != nil {
+		delete(t.sources, pfx)
+	}
+}
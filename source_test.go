@@ -0,0 +1,70 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestUnionTagged(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+
+	if _, ok := tbl.Source(mpp("10.0.0.0/8")); ok {
+		t.Errorf("Source, untagged table: true, want false")
+	}
+
+	a := new(Table[int])
+	a.Insert(mpp("10.0.0.0/8"), 2)
+	a.Insert(mpp("192.168.0.0/16"), 2)
+
+	b := new(Table[int])
+	b.Insert(mpp("192.168.0.0/16"), 3)
+	b.Insert(mpp("2001:db8::/32"), 3)
+
+	if dups := tbl.UnionTagged(a, 100); dups != 1 {
+		t.Errorf("UnionTagged(a), duplicates: %d, want 1", dups)
+	}
+	if dups := tbl.UnionTagged(b, 200); dups != 1 {
+		t.Errorf("UnionTagged(b), duplicates: %d, want 1", dups)
+	}
+
+	tests := []struct {
+		pfx netip.Prefix
+		tag int
+		ok  bool
+	}{
+		{mpp("10.0.0.0/8"), 100, true},
+		{netip.MustParsePrefix("10.1.2.3/8"), 100, true}, // canonicalized
+		{mpp("192.168.0.0/16"), 200, true},               // last contributor
+		{mpp("2001:db8::/32"), 200, true},
+		{mpp("172.16.0.0/12"), 0, false},
+		{netip.Prefix{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		if tag, ok := tbl.Source(tt.pfx); tag != tt.tag || ok != tt.ok {
+			t.Errorf("Source(%s) = (%d, %v), want (%d, %v)", tt.pfx, tag, ok, tt.tag, tt.ok)
+		}
+	}
+
+	// a delete forgets the tag, also for a later re-insert
+	tbl.Delete(mpp("10.0.0.0/8"))
+	tbl.DeleteMany([]netip.Prefix{mpp("2001:db8::/32")})
+	tbl.Insert(mpp("10.0.0.0/8"), 4)
+
+	for _, pfx := range []netip.Prefix{mpp("10.0.0.0/8"), mpp("2001:db8::/32")} {
+		if _, ok := tbl.Source(pfx); ok {
+			t.Errorf("Source(%s) after delete: true, want false", pfx)
+		}
+	}
+
+	// derived tables don't inherit the tags
+	if _, ok := tbl.Clone().Source(mpp("192.168.0.0/16")); ok {
+		t.Errorf("Source, cloned table: true, want false")
+	}
+}
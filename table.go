@@ -24,8 +24,16 @@
 package bart
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
 	"net/netip"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"unsafe"
 
 	"github.com/metacubex/bart/internal/art"
 	"github.com/metacubex/bart/internal/lpm"
@@ -52,6 +60,28 @@ type Table[V any] struct {
 	// the number of prefixes in the routing table
 	size4 int
 	size6 int
+
+	// optional negative cache for Contains, see NewTableMissFilter
+	filter *missFilter
+
+	// optional value interning, see NewInterned
+	intern func(V) V
+
+	// optional values for Lookup misses, see SetFallback
+	fallback *lookupFallback[V]
+
+	// optional observer for modifications, see OnChange
+	onChange func(op ChangeOp, pfx netip.Prefix, val V)
+
+	// optional source tags per prefix, see UnionTagged
+	sources map[netip.Prefix]int
+
+	// the nodes may be shared with a lazy clone, see Clone
+	shared atomic.Bool
+
+	// modification counter, bumped by unshare and the fallback setters,
+	// see CachedTable
+	gen uint64
 }
 
 // rootNodeByVersion, root node getter for ip version.
@@ -105,6 +135,32 @@ func maxDepthAndLastBits(bits int) (maxDepth int, lastBits uint8) {
 	return bits >> 3, uint8(bits & 7)
 }
 
+// Canonical returns pfx in canonical form, with the host bits masked off
+// and IPv4-mapped IPv6 prefixes unmapped: ::ffff:a.b.c.d/n becomes
+// a.b.c.d/(n-96). The prefixes returned by Get, All, etc. are masked,
+// normalize with Canonical to compare them with your input.
+//
+// Only prefixes within ::ffff:0.0.0.0/96 are unmapped, a shorter
+// prefix like ::ffff:0.0.0.0/95 also covers non-mapped IPv6 addresses
+// and stays an IPv6 prefix.
+//
+// Canonical is a pre-processing step, the table itself doesn't apply it:
+// Insert, Get, Delete, InsertCanonical and GetCanonical only mask the host
+// bits, ::ffff:10.0.0.0/104 is stored in the IPv6 trie and a Lookup of
+// 10.0.0.1 doesn't match it. Normalize your input with Canonical to store
+// mapped prefixes as IPv4.
+//
+// An invalid pfx is returned as the zero Prefix, the table ignores it.
+func Canonical(pfx netip.Prefix) netip.Prefix {
+	pfx = pfx.Masked()
+
+	if ip := pfx.Addr(); ip.Is4In6() && pfx.Bits() >= 96 {
+		return netip.PrefixFrom(ip.Unmap(), pfx.Bits()-96)
+	}
+
+	return pfx
+}
+
 // Insert adds a pfx to the tree, with given val.
 // If pfx is already present in the tree, its value is set to val.
 func (t *Table[V]) Insert(pfx netip.Prefix, val V) {
@@ -114,16 +170,182 @@ func (t *Table[V]) Insert(pfx netip.Prefix, val V) {
 
 	// canonicalize prefix
 	pfx = pfx.Masked()
+	t.filter.add(pfx)
+	t.unshare()
 
 	is4 := pfx.Addr().Is4()
 	n := t.rootNodeByVersion(is4)
 
+	val = t.internVal(val)
 	if exists := n.insertAtDepth(pfx, val, 0); exists {
+		t.notify(ChangeUpdate, pfx, val)
+		return
+	}
+
+	// true insert, update size
+	t.sizeUpdate(is4, 1)
+	t.notify(ChangeInsert, pfx, val)
+}
+
+// InsertIfAbsent adds pfx with val only if pfx is not already present,
+// an existing value is left untouched. It reports whether pfx was inserted,
+// e.g. for idempotent bulk loads that must not overwrite tuned values.
+func (t *Table[V]) InsertIfAbsent(pfx netip.Prefix, val V) (inserted bool) {
+	if !pfx.IsValid() {
+		return false
+	}
+
+	// single descent, keep the existing value
+	newVal := t.update(pfx, func(oldVal V, ok bool) V {
+		if ok {
+			return oldVal
+		}
+		inserted = true
+		return val
+	})
+
+	if inserted {
+		t.notify(ChangeInsert, pfx.Masked(), newVal)
+	}
+
+	return inserted
+}
+
+// InsertCanonical is the strict variant of [Table.Insert], pfx is only
+// inserted if it's already in canonical form, with all host bits unset.
+//
+// An error is returned for an invalid prefix or if pfx != pfx.Masked(),
+// e.g. to catch data-entry bugs like 10.0.0.5/24 instead of 10.0.0.0/24.
+// In both cases the table is left unmodified.
+func (t *Table[V]) InsertCanonical(pfx netip.Prefix, val V) error {
+	if !pfx.IsValid() {
+		return fmt.Errorf("bart: invalid prefix %s", pfx)
+	}
+
+	if masked := pfx.Masked(); pfx != masked {
+		return fmt.Errorf("bart: prefix %s has host bits set, canonical form is %s", pfx, masked)
+	}
+
+	t.Insert(pfx, val)
+	return nil
+}
+
+// ErrOutOfScope is returned by [Table.InsertScoped] if the prefix
+// isn't within the allowed prefix.
+var ErrOutOfScope = errors.New("bart: prefix out of scope")
+
+// InsertScoped is like [Table.Insert], but pfx is only inserted if it's
+// within allowed, e.g. to prevent a tenant from installing routes outside
+// its allocation. pfx may be equal to allowed.
+//
+// An error wrapping [ErrOutOfScope] is returned if pfx isn't within allowed,
+// including mismatched IP versions, and an error for invalid prefixes.
+// In both cases the table is left unmodified.
+func (t *Table[V]) InsertScoped(pfx netip.Prefix, val V, allowed netip.Prefix) error {
+	if !pfx.IsValid() || !allowed.IsValid() {
+		return fmt.Errorf("bart: invalid prefix, pfx %s, allowed %s", pfx, allowed)
+	}
+
+	// canonicalize the prefixes
+	pfx = pfx.Masked()
+	allowed = allowed.Masked()
+
+	if pfx.Addr().Is4() != allowed.Addr().Is4() {
+		return fmt.Errorf("%w: %s, IP version mismatch with %s", ErrOutOfScope, pfx, allowed)
+	}
+
+	if pfx.Bits() < allowed.Bits() || !allowed.Contains(pfx.Addr()) {
+		return fmt.Errorf("%w: %s not within %s", ErrOutOfScope, pfx, allowed)
+	}
+
+	t.Insert(pfx, val)
+	return nil
+}
+
+// InsertAddr adds ip as host route (/32 or /128) to the tree, with given val.
+// It's a shorthand for Insert(netip.PrefixFrom(ip, ip.BitLen()), val),
+// the zone is stripped and an IPv4-mapped address is inserted as IPv6 host route.
+//
+// A host route is always stored path-compressed as fringe or leaf,
+// no netip.Prefix is built unless a new leaf is created.
+func (t *Table[V]) InsertAddr(ip netip.Addr, val V) {
+	if !ip.IsValid() {
+		return
+	}
+
+	// host route without zone, like PrefixFrom
+	ip = ip.WithZone("")
+	t.filter.addHost(ip)
+	t.unshare()
+
+	is4 := ip.Is4()
+	n := t.rootNodeByVersion(is4)
+
+	val = t.internVal(val)
+	if exists := n.insertHost(ip, val); exists {
+		t.notifyHost(ChangeUpdate, ip, val)
 		return
 	}
 
 	// true insert, update size
 	t.sizeUpdate(is4, 1)
+	t.notifyHost(ChangeInsert, ip, val)
+}
+
+// InsertMapped is like [Table.Insert], but the address family of pfx
+// is given explicitly for IPv4-mapped IPv6 prefixes (4-in-6).
+//
+// If asV4 is true, a 4-in-6 prefix is unmapped and inserted into the IPv4 trie,
+//
+//	::ffff:10.0.0.0/104 => 10.0.0.0/8
+//
+// otherwise a native IPv4 prefix is mapped and inserted into the IPv6 trie,
+//
+//	10.0.0.0/8 => ::ffff:10.0.0.0/104
+//
+// Insert decides the family by pfx.Addr().Is4() only. Lookups must use
+// the same representation as the insert, mapped or native.
+// A 4-in-6 prefix shorter than /96 exceeds the mapped range, it is
+// always inserted into the IPv6 trie.
+func (t *Table[V]) InsertMapped(pfx netip.Prefix, val V, asV4 bool) {
+	if !pfx.IsValid() {
+		return
+	}
+
+	ip := pfx.Addr()
+	bits := pfx.Bits()
+
+	switch {
+	case asV4 && ip.Is4In6() && bits >= 96:
+		pfx = netip.PrefixFrom(ip.Unmap(), bits-96)
+	case !asV4 && ip.Is4():
+		pfx = netip.PrefixFrom(netip.AddrFrom16(ip.As16()), bits+96)
+	}
+
+	t.Insert(pfx, val)
+}
+
+// InsertCols inserts the prefixes pfxs pairwise with the values vals,
+// e.g. from two columns of a CSV import. The prefixes are canonicalized.
+//
+// An error is returned if the slices differ in length or for the
+// first invalid prefix, in both cases the table is left unmodified.
+func (t *Table[V]) InsertCols(pfxs []netip.Prefix, vals []V) error {
+	if len(pfxs) != len(vals) {
+		return fmt.Errorf("bart: length mismatch, %d prefixes, %d values", len(pfxs), len(vals))
+	}
+
+	for i, pfx := range pfxs {
+		if !pfx.IsValid() {
+			return fmt.Errorf("bart: invalid prefix at index %d", i)
+		}
+	}
+
+	for i, pfx := range pfxs {
+		t.Insert(pfx, vals[i])
+	}
+
+	return nil
 }
 
 // Update or set the value at pfx with a callback function.
@@ -131,6 +353,28 @@ func (t *Table[V]) Insert(pfx netip.Prefix, val V) {
 //
 // If the pfx does not already exist, it is set with the new value.
 func (t *Table[V]) Update(pfx netip.Prefix, cb func(val V, ok bool) V) (newVal V) {
+	if t.onChange == nil || !pfx.IsValid() {
+		return t.update(pfx, cb)
+	}
+
+	// record the kind of change for the observer
+	var exists bool
+	newVal = t.update(pfx, func(val V, ok bool) V {
+		exists = ok
+		return cb(val, ok)
+	})
+
+	op := ChangeInsert
+	if exists {
+		op = ChangeUpdate
+	}
+	t.notify(op, pfx.Masked(), newVal)
+
+	return newVal
+}
+
+// update, see Update.
+func (t *Table[V]) update(pfx netip.Prefix, cb func(val V, ok bool) V) (newVal V) {
 	var zero V
 
 	if !pfx.IsValid() {
@@ -139,6 +383,9 @@ func (t *Table[V]) Update(pfx netip.Prefix, cb func(val V, ok bool) V) (newVal V
 
 	// canonicalize prefix
 	pfx = pfx.Masked()
+	t.filter.add(pfx)
+	t.unshare()
+	cb = t.internCb(cb)
 
 	// values derived from pfx
 	ip := pfx.Addr()
@@ -219,24 +466,95 @@ func (t *Table[V]) Update(pfx netip.Prefix, cb func(val V, ok bool) V) (newVal V
 		}
 	}
 
-	panic("unreachable")
+	panic(malformedPrefix("Update", pfx, 0))
 }
 
 // Delete removes pfx from the tree, pfx does not have to be present.
+//
+// The table drops all references to the deleted value, pointers in V
+// are collectable right away, unless the value is still shared with
+// tables derived by the ...Persist methods.
 func (t *Table[V]) Delete(pfx netip.Prefix) {
-	_, _ = t.getAndDelete(pfx)
+	_, _ = t.GetAndDelete(pfx)
 }
 
 // GetAndDelete deletes the prefix and returns the associated payload for prefix and true,
 // or the zero value and false if prefix is not set in the routing table.
 func (t *Table[V]) GetAndDelete(pfx netip.Prefix) (val V, ok bool) {
-	return t.getAndDelete(pfx)
+	if val, ok = t.getAndDelete(pfx, true); ok {
+		t.forgetSource(pfx.Masked())
+		t.notify(ChangeDelete, pfx.Masked(), val)
+	}
+	return val, ok
+}
+
+// Item is a prefix with its value, for the batch operations of the table.
+type Item[V any] struct {
+	CIDR  netip.Prefix
+	Value V
+}
+
+// InsertManyProgress inserts all items in order, like calling Insert for
+// each item, and reports the progress for huge loads, e.g. for a progress
+// bar while loading a full table.
+//
+// cb is called with the number of inserted items after every
+// every inserts and once at the end with len(items), if not already
+// reported. If every < 1, only the final call is made.
+// If cb is nil, InsertManyProgress is just the bulk insert loop.
+func (t *Table[V]) InsertManyProgress(items []Item[V], every int, cb func(done int)) {
+	if cb == nil {
+		for _, item := range items {
+			t.Insert(item.CIDR, item.Value)
+		}
+		return
+	}
+
+	reported := -1
+	for i, item := range items {
+		t.Insert(item.CIDR, item.Value)
+
+		if every > 0 && (i+1)%every == 0 {
+			reported = i + 1
+			cb(reported)
+		}
+	}
+
+	if reported != len(items) {
+		cb(len(items))
+	}
+}
+
+// DeleteMany removes all pfxs from the tree and returns the number of
+// prefixes that existed.
+//
+// The prefixes are deleted without purging and compressing the trie
+// along each delete path, this is done once at the end with [Table.Recompress].
+// For large batches, e.g. withdrawing thousands of routes,
+// this is faster than calling Delete for each prefix.
+func (t *Table[V]) DeleteMany(pfxs []netip.Prefix) (count int) {
+	for _, pfx := range pfxs {
+		if val, exists := t.getAndDelete(pfx, false); exists {
+			t.forgetSource(pfx.Masked())
+			t.notify(ChangeDelete, pfx.Masked(), val)
+			count++
+		}
+	}
+
+	if count != 0 {
+		t.Recompress()
+	}
+
+	return count
 }
 
-func (t *Table[V]) getAndDelete(pfx netip.Prefix) (val V, exists bool) {
+// getAndDelete, if compress is false, the trie isn't purged
+// and compressed along the delete path, see DeleteMany.
+func (t *Table[V]) getAndDelete(pfx netip.Prefix, compress bool) (val V, exists bool) {
 	if !pfx.IsValid() {
 		return
 	}
+	t.unshare()
 
 	// canonicalize prefix
 	pfx = pfx.Masked()
@@ -269,7 +587,9 @@ func (t *Table[V]) getAndDelete(pfx netip.Prefix) (val V, exists bool) {
 			}
 
 			t.sizeUpdate(is4, -1)
-			n.purgeAndCompress(stack[:depth], octets, is4)
+			if compress {
+				n.purgeAndCompress(stack[:depth], octets, is4)
+			}
 			return val, true
 		}
 
@@ -294,7 +614,9 @@ func (t *Table[V]) getAndDelete(pfx netip.Prefix) (val V, exists bool) {
 			n.children.DeleteAt(octet)
 
 			t.sizeUpdate(is4, -1)
-			n.purgeAndCompress(stack[:depth], octets, is4)
+			if compress {
+				n.purgeAndCompress(stack[:depth], octets, is4)
+			}
 
 			return kid.value, true
 
@@ -308,7 +630,9 @@ func (t *Table[V]) getAndDelete(pfx netip.Prefix) (val V, exists bool) {
 			n.children.DeleteAt(octet)
 
 			t.sizeUpdate(is4, -1)
-			n.purgeAndCompress(stack[:depth], octets, is4)
+			if compress {
+				n.purgeAndCompress(stack[:depth], octets, is4)
+			}
 
 			return kid.value, true
 
@@ -320,6 +644,23 @@ func (t *Table[V]) getAndDelete(pfx netip.Prefix) (val V, exists bool) {
 	return
 }
 
+// Recompress walks the whole trie bottom-up and purges or compresses
+// all nodes that hold only a single prefix, leaf or fringe.
+// It returns the number of reclaimed nodes.
+//
+// Delete already compresses the trie along the delete path,
+// Recompress is useful after bulk operations that modify the
+// trie structure beyond single delete paths.
+//
+// For a table from [NewTableMissFilter] the miss filter is rebuilt.
+func (t *Table[V]) Recompress() (reclaimed int) {
+	t.unshare()
+	reclaimed += t.root4.recompressRec(stridePath{}, 0, true)
+	reclaimed += t.root6.recompressRec(stridePath{}, 0, false)
+	t.missFilterRebuild()
+	return reclaimed
+}
+
 // Get returns the associated payload for prefix and true, or false if
 // prefix is not set in the routing table.
 func (t *Table[V]) Get(pfx netip.Prefix) (val V, ok bool) {
@@ -380,26 +721,62 @@ func (t *Table[V]) Get(pfx netip.Prefix) (val V, ok bool) {
 	panic("unreachable")
 }
 
-// Contains does a route lookup for IP and
-// returns true if any route matched.
+// GetCanonical is like [Table.Get], but also returns the canonical
+// prefix as stored in the table, the masked pfx. This makes explicit
+// which key was matched for a pfx with host bits set.
 //
-// Contains does not return the value nor the prefix of the matching item,
-// but as a test against a black- or whitelist it's often sufficient
-// and even few nanoseconds faster than [Table.Lookup].
-func (t *Table[V]) Contains(ip netip.Addr) bool {
-	// if ip is invalid, Is4() returns false and AsSlice() returns nil
+// If pfx is not set in the routing table, canonical is the zero
+// value and ok is false.
+func (t *Table[V]) GetCanonical(pfx netip.Prefix) (canonical netip.Prefix, val V, ok bool) {
+	if val, ok = t.Get(pfx); !ok {
+		return
+	}
+	return pfx.Masked(), val, true
+}
+
+// GetPtr returns a pointer to the value stored for the exact prefix,
+// or nil if prefix is not set, to edit the value in place without
+// a Get and Insert round trip.
+//
+// The pointer is only valid until the next modification of the table,
+// any Insert, Update or Delete may move the values. In-place edits
+// bypass the [Table.OnChange] observer and the value interning, and
+// they are visible in tables derived by the ...Persist methods
+// that still share the trie node with this table.
+func (t *Table[V]) GetPtr(pfx netip.Prefix) *V {
+	if !pfx.IsValid() {
+		return nil
+	}
+
+	// the nodes must not be shared with a lazy clone
+	t.unshare()
+
+	// canonicalize the prefix
+	pfx = pfx.Masked()
+
+	// values derived from pfx
+	ip := pfx.Addr()
 	is4 := ip.Is4()
+	bits := pfx.Bits()
+
 	n := t.rootNodeByVersion(is4)
 
-	for _, octet := range ip.AsSlice() {
-		// for contains, any lpm match is good enough, no backtracking needed
-		if n.prefixes.Len() != 0 && n.lpmTest(art.OctetToIdx(octet)) {
-			return true
+	maxDepth, lastBits := maxDepthAndLastBits(bits)
+
+	octets := ip.AsSlice()
+
+	// find the trie node
+	for depth, octet := range octets {
+		if depth == maxDepth {
+			idx := art.PfxToIdx(octet, lastBits)
+			if !n.prefixes.Test(idx) {
+				return nil
+			}
+			return &n.prefixes.Items[n.prefixes.Rank(idx)-1]
 		}
 
-		// stop traversing?
 		if !n.children.Test(octet) {
-			return false
+			return nil
 		}
 		kid := n.children.MustGet(octet)
 
@@ -410,51 +787,56 @@ func (t *Table[V]) Contains(ip netip.Addr) bool {
 			continue // descend down to next trie level
 
 		case *fringeNode[V]:
-			// fringe is the default-route for all possible octets below
-			return true
+			if isFringe(depth, bits) {
+				return &kid.value
+			}
+			return nil
 
 		case *leafNode[V]:
-			return kid.prefix.Contains(ip)
+			if kid.prefix == pfx {
+				return &kid.value
+			}
+			return nil
 
 		default:
 			panic("logic error, wrong node type")
 		}
 	}
 
-	return false
+	panic("unreachable")
 }
 
-// Lookup does a route lookup (longest prefix match) for IP and
-// returns the associated value and true, or false if no route matched.
-func (t *Table[V]) Lookup(ip netip.Addr) (val V, ok bool) {
-	if !ip.IsValid() {
-		return
+// Has reports whether prefix is set in the routing table.
+//
+// Has is the exact-match membership test of [Table.Get], but
+// it doesn't touch the payload. For large V this saves the value copy.
+func (t *Table[V]) Has(pfx netip.Prefix) bool {
+	if !pfx.IsValid() {
+		return false
 	}
 
+	// canonicalize the prefix
+	pfx = pfx.Masked()
+
+	// values derived from pfx
+	ip := pfx.Addr()
 	is4 := ip.Is4()
-	octets := ip.AsSlice()
+	bits := pfx.Bits()
 
 	n := t.rootNodeByVersion(is4)
 
-	// stack of the traversed nodes for fast backtracking, if needed
-	stack := [maxTreeDepth]*node[V]{}
-
-	// run variable, used after for loop
-	var depth int
-	var octet byte
+	maxDepth, lastBits := maxDepthAndLastBits(bits)
 
-LOOP:
-	// find leaf node
-	for depth, octet = range octets {
-		depth = depth & 0xf // BCE, Lookup must be fast
+	octets := ip.AsSlice()
 
-		// push current node on stack for fast backtracking
-		stack[depth] = n
+	// find the trie node
+	for depth, octet := range octets {
+		if depth == maxDepth {
+			return n.prefixes.Test(art.PfxToIdx(octet, lastBits))
+		}
 
-		// go down in tight loop to last octet
 		if !n.children.Test(octet) {
-			// no more nodes below octet
-			break LOOP
+			return false
 		}
 		kid := n.children.MustGet(octet)
 
@@ -465,97 +847,191 @@ LOOP:
 			continue // descend down to next trie level
 
 		case *fringeNode[V]:
-			// fringe is the default-route for all possible nodes below
-			return kid.value, true
+			// reached a path compressed fringe, stop traversing
+			return isFringe(depth, bits)
 
 		case *leafNode[V]:
-			if kid.prefix.Contains(ip) {
-				return kid.value, true
-			}
 			// reached a path compressed prefix, stop traversing
-			break LOOP
+			return kid.prefix == pfx
 
 		default:
 			panic("logic error, wrong node type")
 		}
 	}
 
-	// start backtracking, unwind the stack, bounds check eliminated
-	for ; depth >= 0; depth-- {
-		depth = depth & 0xf // BCE
-
-		n = stack[depth]
+	panic("unreachable")
+}
 
-		// longest prefix match, skip if node has no prefixes
-		if n.prefixes.Len() != 0 {
-			idx := art.OctetToIdx(octets[depth])
-			// lpmGet(idx), manually inlined
-			// --------------------------------------------------------------
-			if topIdx, ok := n.prefixes.IntersectionTop(lpm.BackTrackingBitset(idx)); ok {
-				return n.prefixes.MustGet(topIdx), true
-			}
-			// --------------------------------------------------------------
-		}
+// Contains does a route lookup for IP and
+// returns true if any route matched.
+//
+// Contains does not return the value nor the prefix of the matching item,
+// but as a test against a black- or whitelist it's often sufficient
+// and even few nanoseconds faster than [Table.Lookup].
+func (t *Table[V]) Contains(ip netip.Addr) bool {
+	// negative cache, reject uncovered /16 blocks without descent
+	if t.filter != nil && ip.IsValid() && !t.filter.test(ip) {
+		return false
 	}
 
-	return
-}
+	// fast path for the dominant IPv4 case
+	if ip.Is4() {
+		return t.contains4(ip)
+	}
 
-// LookupPrefix does a route lookup (longest prefix match) for pfx and
-// returns the associated value and true, or false if no route matched.
-func (t *Table[V]) LookupPrefix(pfx netip.Prefix) (val V, ok bool) {
-	_, val, ok = t.lookupPrefixLPM(pfx, false)
-	return val, ok
+	return t.contains(ip)
 }
 
-// LookupPrefixLPM is similar to [Table.LookupPrefix],
-// but it returns the lpm prefix in addition to value,ok.
+// contains4 is the specialized version of contains for IPv4.
 //
-// This method is about 20-30% slower than LookupPrefix and should only
-// be used if the matching lpm entry is also required for other reasons.
+// The four trie levels are unrolled with constant indexes into the
+// [4]byte array of the address, this avoids the AsSlice() overhead,
+// the loop and the bounds checks in the hot path.
 //
-// If LookupPrefixLPM is to be used for IP address lookups,
-// they must be converted to /32 or /128 prefixes.
-func (t *Table[V]) LookupPrefixLPM(pfx netip.Prefix) (lpmPfx netip.Prefix, val V, ok bool) {
-	return t.lookupPrefixLPM(pfx, true)
+// For contains, any lpm match is good enough, no backtracking needed.
+func (t *Table[V]) contains4(ip netip.Addr) bool {
+	a := ip.As4()
+	n := &t.root4
+
+	// depth 0
+	if n.prefixes.Len() != 0 && n.lpmTest(art.OctetToIdx(a[0])) {
+		return true
+	}
+	if !n.children.Test(a[0]) {
+		return false
+	}
+	kid := n.children.MustGet(a[0])
+	if n, _ = kid.(*node[V]); n == nil {
+		return containsLeafOrFringe[V](kid, ip)
+	}
+
+	// depth 1
+	if n.prefixes.Len() != 0 && n.lpmTest(art.OctetToIdx(a[1])) {
+		return true
+	}
+	if !n.children.Test(a[1]) {
+		return false
+	}
+	kid = n.children.MustGet(a[1])
+	if n, _ = kid.(*node[V]); n == nil {
+		return containsLeafOrFringe[V](kid, ip)
+	}
+
+	// depth 2
+	if n.prefixes.Len() != 0 && n.lpmTest(art.OctetToIdx(a[2])) {
+		return true
+	}
+	if !n.children.Test(a[2]) {
+		return false
+	}
+	kid = n.children.MustGet(a[2])
+	if n, _ = kid.(*node[V]); n == nil {
+		return containsLeafOrFringe[V](kid, ip)
+	}
+
+	// depth 3, the last stride, no nodes below
+	if n.prefixes.Len() != 0 && n.lpmTest(art.OctetToIdx(a[3])) {
+		return true
+	}
+	if !n.children.Test(a[3]) {
+		return false
+	}
+	return containsLeafOrFringe[V](n.children.MustGet(a[3]), ip)
 }
 
-func (t *Table[V]) lookupPrefixLPM(pfx netip.Prefix, withLPM bool) (lpmPfx netip.Prefix, val V, ok bool) {
-	if !pfx.IsValid() {
-		return
+// containsLeafOrFringe, the end of the trie walk of contains4.
+func containsLeafOrFringe[V any](kid any, ip netip.Addr) bool {
+	switch kid := kid.(type) {
+	case *fringeNode[V]:
+		// fringe is the default-route for all possible octets below
+		return true
+
+	case *leafNode[V]:
+		return kid.prefix.Contains(ip)
+
+	default:
+		panic("logic error, wrong node type")
 	}
+}
 
-	// canonicalize the prefix
-	pfx = pfx.Masked()
+// contains is the generic version of Contains, for IPv4 and IPv6.
+func (t *Table[V]) contains(ip netip.Addr) bool {
+	// if ip is invalid, Is4() returns false and AsSlice() returns nil
+	is4 := ip.Is4()
+	n := t.rootNodeByVersion(is4)
+
+	for _, octet := range ip.AsSlice() {
+		// for contains, any lpm match is good enough, no backtracking needed
+		if n.prefixes.Len() != 0 && n.lpmTest(art.OctetToIdx(octet)) {
+			return true
+		}
+
+		// stop traversing?
+		if !n.children.Test(octet) {
+			return false
+		}
+		kid := n.children.MustGet(octet)
+
+		// kid is node or leaf or fringe at octet
+		switch kid := kid.(type) {
+		case *node[V]:
+			n = kid
+			continue // descend down to next trie level
+
+		case *fringeNode[V]:
+			// fringe is the default-route for all possible octets below
+			return true
+
+		case *leafNode[V]:
+			return kid.prefix.Contains(ip)
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	return false
+}
+
+// Lookup does a route lookup (longest prefix match) for IP and
+// returns the associated value and true, or false if no route matched.
+func (t *Table[V]) Lookup(ip netip.Addr) (val V, ok bool) {
+	if val, ok = t.lookup(ip); ok || t.fallback == nil {
+		return val, ok
+	}
+	return t.lookupMiss(ip)
+}
+
+// lookup is the implementation of Lookup, without the fallback on a miss,
+// for the internal callers.
+func (t *Table[V]) lookup(ip netip.Addr) (val V, ok bool) {
+	if !ip.IsValid() {
+		return
+	}
 
-	ip := pfx.Addr()
-	bits := pfx.Bits()
 	is4 := ip.Is4()
 	octets := ip.AsSlice()
-	maxDepth, lastBits := maxDepthAndLastBits(bits)
 
 	n := t.rootNodeByVersion(is4)
 
-	// record path to leaf node
+	// stack of the traversed nodes for fast backtracking, if needed
 	stack := [maxTreeDepth]*node[V]{}
 
+	// run variable, used after for loop
 	var depth int
 	var octet byte
 
 LOOP:
-	// find the last node on the octets path in the trie,
+	// find leaf node
 	for depth, octet = range octets {
-		depth = depth & 0xf // BCE
+		depth = depth & 0xf // BCE, Lookup must be fast
 
-		if depth > maxDepth {
-			depth--
-			break
-		}
-		// push current node on stack
+		// push current node on stack for fast backtracking
 		stack[depth] = n
 
-		// go down in tight loop to leaf node
+		// go down in tight loop to last octet
 		if !n.children.Test(octet) {
+			// no more nodes below octet
 			break LOOP
 		}
 		kid := n.children.MustGet(octet)
@@ -564,401 +1040,2114 @@ LOOP:
 		switch kid := kid.(type) {
 		case *node[V]:
 			n = kid
-			continue LOOP // descend down to next trie level
-
-		case *leafNode[V]:
-			// reached a path compressed prefix, stop traversing
-			if kid.prefix.Bits() > bits || !kid.prefix.Contains(ip) {
-				break LOOP
-			}
-			return kid.prefix, kid.value, true
+			continue // descend down to next trie level
 
 		case *fringeNode[V]:
-			// the bits of the fringe are defined by the depth
-			// maybe the LPM isn't needed, saves some cycles
-			fringeBits := (depth + 1) << 3
-			if fringeBits > bits {
-				break LOOP
-			}
+			// fringe is the default-route for all possible nodes below
+			return kid.value, true
 
-			// the LPM isn't needed, saves some cycles
-			if !withLPM {
-				return netip.Prefix{}, kid.value, true
+		case *leafNode[V]:
+			if kid.prefix.Contains(ip) {
+				return kid.value, true
 			}
-
-			// sic, get the LPM prefix back, it costs some cycles!
-			fringePfx := cidrForFringe(octets, depth, is4, octet)
-			return fringePfx, kid.value, true
+			// reached a path compressed prefix, stop traversing
+			break LOOP
 
 		default:
 			panic("logic error, wrong node type")
 		}
 	}
 
-	// start backtracking, unwind the stack
+	// start backtracking, unwind the stack, bounds check eliminated
 	for ; depth >= 0; depth-- {
 		depth = depth & 0xf // BCE
 
 		n = stack[depth]
 
 		// longest prefix match, skip if node has no prefixes
-		if n.prefixes.Len() == 0 {
-			continue
+		if n.prefixes.Len() != 0 {
+			idx := art.OctetToIdx(octets[depth])
+			// lpmGet(idx), manually inlined
+			// --------------------------------------------------------------
+			if topIdx, ok := n.prefixes.IntersectionTop(lpm.BackTrackingBitset(idx)); ok {
+				return n.prefixes.MustGet(topIdx), true
+			}
+			// --------------------------------------------------------------
 		}
+	}
 
-		// only the lastOctet may have a different prefix len
-		// all others are just host routes
-		var idx uint
-		octet = octets[depth]
-		if depth == maxDepth {
-			idx = uint(art.PfxToIdx(octet, lastBits))
-		} else {
-			idx = art.OctetToIdx(octet)
+	return
+}
+
+// lpmWalk is the longest-prefix-match walk shared by the Lookup variants.
+// Lookup itself has its own tight copy, it must be fast.
+//
+// The match is either the prefix with baseIndex idx in node n at depth,
+// or, if kid is not nil, a path-compressed leaf or fringe in a child slot
+// of n. steps counts the nodes descended and the bitset intersections
+// while backtracking, see [Table.LookupProfiled].
+func (t *Table[V]) lpmWalk(ip netip.Addr) (n *node[V], idx uint8, kid any, depth, steps int, ok bool) {
+	if !ip.IsValid() {
+		return
+	}
+
+	is4 := ip.Is4()
+	octets := ip.AsSlice()
+
+	n = t.rootNodeByVersion(is4)
+
+	// stack of the traversed nodes for fast backtracking, if needed
+	stack := [maxTreeDepth]*node[V]{}
+
+	// run variable, used after for loop
+	var octet byte
+
+LOOP:
+	// find leaf node
+	for depth, octet = range octets {
+		depth = depth & 0xf // BCE, Lookup must be fast
+
+		// push current node on stack for fast backtracking
+		stack[depth] = n
+		steps++
+
+		// go down in tight loop to last octet
+		if !n.children.Test(octet) {
+			// no more nodes below octet
+			break LOOP
 		}
+		kid := n.children.MustGet(octet)
 
-		// manually inlined: lpmGet(idx)
-		if topIdx, ok := n.prefixes.IntersectionTop(lpm.BackTrackingBitset(idx)); ok {
-			val = n.prefixes.MustGet(topIdx)
+		// kid is node or leaf or fringe at octet
+		switch k := kid.(type) {
+		case *node[V]:
+			n = k
+			continue // descend down to next trie level
 
-			// called from LookupPrefix
-			if !withLPM {
-				return netip.Prefix{}, val, ok
+		case *fringeNode[V]:
+			// fringe is the default-route for all possible nodes below
+			return n, 0, kid, depth, steps, true
+
+		case *leafNode[V]:
+			if k.prefix.Contains(ip) {
+				return n, 0, kid, depth, steps, true
 			}
+			// reached a path compressed prefix, stop traversing
+			break LOOP
 
-			// called from LookupPrefixLPM
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
 
-			// get the bits from depth and top idx
-			pfxBits := int(art.PfxBits(depth, topIdx))
+	// start backtracking, unwind the stack, bounds check eliminated
+	for ; depth >= 0; depth-- {
+		depth = depth & 0xf // BCE
 
-			// calculate the lpmPfx from incoming ip and new mask
-			lpmPfx, _ = ip.Prefix(pfxBits)
-			return lpmPfx, val, ok
+		n = stack[depth]
+
+		// longest prefix match, skip if node has no prefixes
+		if n.prefixes.Len() != 0 {
+			steps++
+			idx := art.OctetToIdx(octets[depth])
+			// lpmGet(idx), manually inlined
+			// --------------------------------------------------------------
+			if topIdx, ok := n.prefixes.IntersectionTop(lpm.BackTrackingBitset(idx)); ok {
+				return n, topIdx, nil, depth, steps, true
+			}
+			// --------------------------------------------------------------
 		}
 	}
 
-	return
+	return nil, 0, nil, 0, steps, false
 }
 
-// Supernets returns an iterator over all supernet routes that cover the given prefix pfx.
-//
-// The traversal searches both exact-length and shorter (less specific) prefixes that
-// overlap or include pfx. Starting from the most specific position in the trie,
-// it walks upward through parent nodes and yields any matching entries found at each level.
-//
-// The iteration order is reverse-CIDR: from longest prefix match (LPM) towards
-// least-specific routes.
-//
-// The search is protocol-specific (IPv4 or IPv6) and stops immediately if the yield
-// function returns false. If pfx is invalid, the function silently returns.
-//
-// This can be used to enumerate all covering supernet routes in routing-based
-// policy engines, diagnostics tools, or fallback resolution logic.
+// lpmValue returns a pointer to the value of a match of lpmWalk.
+func lpmValue[V any](n *node[V], idx uint8, kid any) *V {
+	switch kid := kid.(type) {
+	case nil:
+		return &n.prefixes.Items[n.prefixes.Rank(idx)-1]
+	case *fringeNode[V]:
+		return &kid.value
+	case *leafNode[V]:
+		return &kid.value
+	default:
+		panic("logic error, wrong node type")
+	}
+}
+
+// LookupProfiled is like [Table.Lookup], but additionally returns the
+// number of steps, the trie nodes descended plus the bitset intersections
+// while backtracking. It's for offline analysis of the lookup cost
+// on real data, e.g. to find the addresses that cause deep walks.
+// Use Lookup in production, it's faster.
+func (t *Table[V]) LookupProfiled(ip netip.Addr) (val V, ok bool, steps int) {
+	n, idx, kid, _, steps, ok := t.lpmWalk(ip)
+	if !ok {
+		val, ok = t.lookupMiss(ip)
+		return val, ok, steps
+	}
+	return *lpmValue(n, idx, kid), true, steps
+}
+
+// LookupEx is like [Table.Lookup], but additionally returns the length of
+// the matched prefix and the depth of the trie node where it's stored,
+// e.g. to choose the caching granularity for the result.
 //
-// Example:
+// A path-compressed leaf or fringe is stored in a child slot of the node
+// at depth, with bits >= (depth+1)*8, see also [Table.AllWithDepth].
 //
-//	for supernet, val := range table.Supernets(netip.MustParsePrefix("192.0.2.128/25")) {
-//	    fmt.Println("Matched covering route:", supernet, "->", val)
-//	}
-func (t *Table[V]) Supernets(pfx netip.Prefix) func(yield func(netip.Prefix, V) bool) {
-	return func(yield func(netip.Prefix, V) bool) {
-		if !pfx.IsValid() {
-			return
-		}
+// On a miss the fallback is returned like a default route match,
+// with bits and depth 0, see [Table.SetFallback].
+func (t *Table[V]) LookupEx(ip netip.Addr) (val V, bits int, depth int, ok bool) {
+	if val, bits, depth, ok = t.lookupEx(ip); ok || t.fallback == nil {
+		return val, bits, depth, ok
+	}
 
-		// canonicalize the prefix
-		pfx = pfx.Masked()
+	val, ok = t.lookupMiss(ip)
+	return val, 0, 0, ok
+}
 
-		ip := pfx.Addr()
-		is4 := ip.Is4()
-		bits := pfx.Bits()
-		octets := ip.AsSlice()
-		maxDepth, lastBits := maxDepthAndLastBits(bits)
+// lookupEx is the implementation of LookupEx, without the fallback on a miss.
+func (t *Table[V]) lookupEx(ip netip.Addr) (val V, bits int, depth int, ok bool) {
+	n, idx, kid, depth, _, ok := t.lpmWalk(ip)
+	if !ok {
+		return
+	}
 
-		n := t.rootNodeByVersion(is4)
+	switch kid := kid.(type) {
+	case nil:
+		_, pfxLen := art.IdxToPfx(idx)
+		bits = depth<<3 + int(pfxLen)
+	case *fringeNode[V]:
+		bits = (depth + 1) << 3
+	case *leafNode[V]:
+		bits = kid.prefix.Bits()
+	}
 
-		// stack of the traversed nodes for reverse ordering of supernets
-		stack := [maxTreeDepth]*node[V]{}
+	return *lpmValue(n, idx, kid), bits, depth, true
+}
 
-		// run variable, used after for loop
-		var depth int
-		var octet byte
+// NearestSupernet is like [Table.Lookup], but additionally returns the
+// matched prefix and whether the lookup fell through to the default route
+// (0.0.0.0/0 or ::/0) of the address family.
+//
+// This distinguishes a match of a specific route from the catch-all
+// behavior of the default route. If no route covers ip, not even a
+// default route, ok is false.
+func (t *Table[V]) NearestSupernet(ip netip.Addr) (pfx netip.Prefix, val V, isDefault bool, ok bool) {
+	// the fallback is no route, see SetFallback
+	val, bits, _, ok := t.lookupEx(ip)
+	if !ok {
+		return
+	}
 
-		// find last node along this octet path
-	LOOP:
-		for depth, octet = range octets {
-			if depth > maxDepth {
-				depth--
-				break
+	// canonicalize the matched prefix, ignore the zone
+	pfx, _ = ip.WithZone("").Prefix(bits)
+
+	return pfx, val, bits == 0, true
+}
+
+// LookupInto is like [Table.Lookup], but the associated value is
+// assigned through dst instead of being returned by value.
+// If no route matched, false is returned and dst is left untouched,
+// unless a fallback is set, see [Table.SetFallback].
+//
+// For large V this avoids the copy of the returned value.
+func (t *Table[V]) LookupInto(ip netip.Addr, dst *V) bool {
+	n, idx, kid, _, _, ok := t.lpmWalk(ip)
+	if !ok {
+		if !ip.IsValid() || t.fallback == nil || !t.fallback.set {
+			return false
+		}
+		*dst, ok = t.lookupMiss(ip)
+		return ok
+	}
+
+	*dst = *lpmValue(n, idx, kid)
+	return true
+}
+
+// LookupBytes is like [Table.Lookup], but the IP is given as raw octets
+// in network byte order, 4 bytes for IPv4 or 16 bytes for IPv6.
+// For any other length false is returned.
+//
+// Use it in packet processing hot loops, where the address is already
+// at hand as bytes, to skip the round-trip through [netip.Addr].
+// A 16 byte IPv4-mapped IPv6 address is looked up in the IPv6 trie,
+// as with [Table.Lookup].
+func (t *Table[V]) LookupBytes(ip []byte) (val V, ok bool) {
+	switch len(ip) {
+	case 4:
+		return t.lookupBytes(ip, true)
+	case 16:
+		return t.lookupBytes(ip, false)
+	default:
+		return
+	}
+}
+
+// Lookup4 is like [Table.Lookup] for an IPv4 address given as array,
+// the IPv4 trie is walked without any IP version detection, e.g. for
+// an IPv4-only packet path.
+func (t *Table[V]) Lookup4(ip [4]byte) (val V, ok bool) {
+	return t.lookupBytes(ip[:], true)
+}
+
+// Lookup16 is like [Table.Lookup] for an IPv6 address given as array,
+// the IPv6 trie is walked without any IP version detection, e.g. for
+// an IPv6-only listener. An IPv4-mapped address is looked up in the
+// IPv6 trie, as with Lookup.
+func (t *Table[V]) Lookup16(ip [16]byte) (val V, ok bool) {
+	return t.lookupBytes(ip[:], false)
+}
+
+// lookupBytes, the common implementation of LookupBytes, Lookup4 and Lookup16,
+// len(ip) must match is4.
+func (t *Table[V]) lookupBytes(ip []byte, is4 bool) (val V, ok bool) {
+	n := t.rootNodeByVersion(is4)
+
+	// stack of the traversed nodes for fast backtracking, if needed
+	stack := [maxTreeDepth]*node[V]{}
+
+	// run variable, used after for loop
+	var depth int
+	var octet byte
+
+LOOP:
+	// find leaf node
+	for depth, octet = range ip {
+		depth = depth & 0xf // BCE, Lookup must be fast
+
+		// push current node on stack for fast backtracking
+		stack[depth] = n
+
+		// go down in tight loop to last octet
+		if !n.children.Test(octet) {
+			// no more nodes below octet
+			break LOOP
+		}
+		kid := n.children.MustGet(octet)
+
+		// kid is node or leaf or fringe at octet
+		switch kid := kid.(type) {
+		case *node[V]:
+			n = kid
+			continue // descend down to next trie level
+
+		case *fringeNode[V]:
+			// fringe is the default-route for all possible nodes below
+			return kid.value, true
+
+		case *leafNode[V]:
+			// only build the addr for the rare leaf compare, no allocation
+			var addr netip.Addr
+			if is4 {
+				addr = netip.AddrFrom4([4]byte(ip))
+			} else {
+				addr = netip.AddrFrom16([16]byte(ip))
 			}
-			// push current node on stack
-			stack[depth] = n
 
-			// descend down the trie
-			if !n.children.Test(octet) {
-				break LOOP
+			if kid.prefix.Contains(addr) {
+				return kid.value, true
 			}
-			kid := n.children.MustGet(octet)
+			// reached a path compressed prefix, stop traversing
+			break LOOP
 
-			// kid is node or leaf or fringe at octet
-			switch kid := kid.(type) {
-			case *node[V]:
-				n = kid
-				continue LOOP // descend down to next trie level
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
 
-			case *leafNode[V]:
-				if kid.prefix.Bits() > pfx.Bits() {
-					break LOOP
-				}
+	// start backtracking, unwind the stack, bounds check eliminated
+	for ; depth >= 0; depth-- {
+		depth = depth & 0xf // BCE
 
-				if kid.prefix.Overlaps(pfx) {
-					if !yield(kid.prefix, kid.value) {
-						// early exit
-						return
-					}
-				}
-				// end of trie along this octets path
-				break LOOP
+		n = stack[depth]
 
-			case *fringeNode[V]:
-				fringePfx := cidrForFringe(octets, depth, is4, octet)
-				if fringePfx.Bits() > pfx.Bits() {
-					break LOOP
-				}
+		// longest prefix match, skip if node has no prefixes
+		if n.prefixes.Len() != 0 {
+			idx := art.OctetToIdx(ip[depth])
+			// lpmGet(idx), manually inlined
+			// --------------------------------------------------------------
+			if topIdx, ok := n.prefixes.IntersectionTop(lpm.BackTrackingBitset(idx)); ok {
+				return n.prefixes.MustGet(topIdx), true
+			}
+			// --------------------------------------------------------------
+		}
+	}
 
-				if fringePfx.Overlaps(pfx) {
-					if !yield(fringePfx, kid.value) {
-						// early exit
-						return
-					}
-				}
-				// end of trie along this octets path
-				break LOOP
+	return t.missFallback(is4)
+}
 
-			default:
-				panic("logic error, wrong node type")
-			}
+// LookupFunc does a route lookup (longest prefix match) for IP, but only
+// matches with a value accepted by the accept func are returned.
+// Rejected matches are skipped and the backtracking continues with the
+// next less specific match, e.g. to prefer routes with a healthy next-hop.
+//
+// The accept func is called in LPM order for each matching route
+// until the first route is accepted. The fallback on a miss, see
+// [Table.SetFallback], isn't passed to accept.
+func (t *Table[V]) LookupFunc(ip netip.Addr, accept func(V) bool) (val V, ok bool) {
+	if !ip.IsValid() {
+		return
+	}
+
+	is4 := ip.Is4()
+	octets := ip.AsSlice()
+
+	n := t.rootNodeByVersion(is4)
+
+	// stack of the traversed nodes for backtracking
+	stack := [maxTreeDepth]*node[V]{}
+
+	// run variable, used after for loop
+	var depth int
+	var octet byte
+
+LOOP:
+	// find leaf node
+	for depth, octet = range octets {
+		depth = depth & 0xf // BCE
+
+		// push current node on stack for backtracking
+		stack[depth] = n
+
+		// go down in tight loop to last octet
+		if !n.children.Test(octet) {
+			// no more nodes below octet
+			break LOOP
 		}
+		kid := n.children.MustGet(octet)
 
-		// start backtracking, unwind the stack
-		for ; depth >= 0; depth-- {
-			n = stack[depth]
+		// kid is node or leaf or fringe at octet
+		switch kid := kid.(type) {
+		case *node[V]:
+			n = kid
+			continue // descend down to next trie level
 
-			// only the lastOctet may have a different prefix len
-			// all others are just host routes
-			var idx uint
-			octet = octets[depth]
-			if depth == maxDepth {
-				idx = uint(art.PfxToIdx(octet, lastBits))
-			} else {
-				idx = art.OctetToIdx(octet)
+		case *fringeNode[V]:
+			// fringe is the default-route for all possible nodes below
+			if accept(kid.value) {
+				return kid.value, true
 			}
+			break LOOP
 
-			// micro benchmarking, skip if there is no match
-			if !n.lpmTest(idx) {
-				continue
+		case *leafNode[V]:
+			if kid.prefix.Contains(ip) && accept(kid.value) {
+				return kid.value, true
 			}
+			// reached a path compressed prefix, stop traversing
+			break LOOP
 
-			// yield all the matching prefixes, not just the lpm
-			if !n.eachLookupPrefix(octets, depth, is4, idx, yield) {
-				// early exit
-				return
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	// start backtracking, unwind the stack
+	for ; depth >= 0; depth-- {
+		depth = depth & 0xf // BCE
+
+		n = stack[depth]
+
+		// skip if node has no prefixes
+		if n.prefixes.Len() == 0 {
+			continue
+		}
+
+		// all matching prefixes in this node, not just the lpm
+		idx := art.OctetToIdx(octets[depth])
+		matches := n.prefixes.Intersection(lpm.BackTrackingBitset(idx))
+
+		// test the matches from longest to shortest
+		for top, ok := matches.LastSet(); ok; top, ok = matches.LastSet() {
+			if val := n.prefixes.MustGet(top); accept(val) {
+				return val, true
 			}
+			matches.Clear(top)
 		}
 	}
+
+	return t.lookupMiss(ip)
 }
 
-// Subnets returns an iterator over all prefix–value pairs in the routing table
-// that are fully contained within the given prefix pfx.
+// LookupAllLPM does a route lookup (longest prefix match) for IP and
+// returns the prefix length of the longest match together with the values
+// of all matching routes at the deepest matching trie node.
 //
-// Entries are returned in CIDR sort order.
+// Distinct prefixes can't tie in length for the same IP, so the matches are
+// consolidated per trie node (8-bit stride): vals[0] is the value of the
+// longest prefix match, as returned by [Table.Lookup], followed by the
+// values of the less specific routes in the same stride, ordered by
+// decreasing prefix length. Matches in shallower trie nodes are not included.
 //
-// Example:
+// If the longest match is a path-compressed leaf or fringe, vals holds just
+// this single value.
+func (t *Table[V]) LookupAllLPM(ip netip.Addr) (bits int, vals []V, ok bool) {
+	t.eachDeepestLookup(ip, func(pfx netip.Prefix, val V) bool {
+		if !ok {
+			bits, ok = pfx.Bits(), true
+		}
+		vals = append(vals, val)
+		return true
+	})
+
+	return bits, vals, ok
+}
+
+// Explain does a route lookup (longest prefix match) for IP and returns
+// the winning prefix together with all candidate prefixes considered
+// during backtracking at the deepest matching trie node.
 //
-//	for sub, val := range table.Subnets(netip.MustParsePrefix("10.0.0.0/8")) {
-//	    fmt.Println("Covered:", sub, "->", val)
-//	}
-func (t *Table[V]) Subnets(pfx netip.Prefix) func(yield func(netip.Prefix, V) bool) {
-	return func(yield func(netip.Prefix, V) bool) {
-		if !pfx.IsValid() {
-			return
+// The candidates are ordered by decreasing prefix length, candidates[0]
+// is always the result, as returned by [Table.LookupPrefixLPM] for the
+// single IP. Matches in shallower trie nodes are not considered at all,
+// they can't win against a match in a deeper node.
+//
+// Explain is intended for debugging, e.g. to show why a route was selected.
+func (t *Table[V]) Explain(ip netip.Addr) (result netip.Prefix, candidates []netip.Prefix, ok bool) {
+	t.eachDeepestLookup(ip, func(pfx netip.Prefix, _ V) bool {
+		if !ok {
+			result, ok = pfx, true
 		}
+		candidates = append(candidates, pfx)
+		return true
+	})
 
-		// canonicalize the prefix
-		pfx = pfx.Masked()
+	return result, candidates, ok
+}
 
-		// values derived from pfx
-		ip := pfx.Addr()
-		is4 := ip.Is4()
-		bits := pfx.Bits()
-		octets := ip.AsSlice()
-		maxDepth, lastBits := maxDepthAndLastBits(bits)
+// eachDeepestLookup yields all prefixes matching ip at the deepest matching
+// trie node in reverse CIDR order. A path-compressed leaf or fringe is
+// yielded as single match.
+func (t *Table[V]) eachDeepestLookup(ip netip.Addr, yield func(netip.Prefix, V) bool) {
+	if !ip.IsValid() {
+		return
+	}
 
-		n := t.rootNodeByVersion(is4)
+	is4 := ip.Is4()
+	octets := ip.AsSlice()
 
-		// find the trie node
-		for depth, octet := range octets {
-			if depth == maxDepth {
-				idx := art.PfxToIdx(octet, lastBits)
-				_ = n.eachSubnet(octets, depth, is4, idx, yield)
-				return
-			}
+	n := t.rootNodeByVersion(is4)
 
-			if !n.children.Test(octet) {
-				return
-			}
-			kid := n.children.MustGet(octet)
+	// stack of the traversed nodes for fast backtracking, if needed
+	stack := [maxTreeDepth]*node[V]{}
+
+	// run variable, used after for loop
+	var depth int
+	var octet byte
+
+LOOP:
+	// find leaf node
+	for depth, octet = range octets {
+		depth = depth & 0xf // BCE
+
+		// push current node on stack for fast backtracking
+		stack[depth] = n
+
+		// go down in tight loop to last octet
+		if !n.children.Test(octet) {
+			// no more nodes below octet
+			break LOOP
+		}
+		kid := n.children.MustGet(octet)
+
+		// kid is node or leaf or fringe at octet
+		switch kid := kid.(type) {
+		case *node[V]:
+			n = kid
+			continue // descend down to next trie level
+
+		case *fringeNode[V]:
+			// fringe is the default-route for all possible nodes below
+			yield(cidrForFringe(octets, depth, is4, octet), kid.value)
+			return
+
+		case *leafNode[V]:
+			if kid.prefix.Contains(ip) {
+				yield(kid.prefix, kid.value)
+				return
+			}
+			// reached a path compressed prefix, stop traversing
+			break LOOP
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	// start backtracking, unwind the stack
+	for ; depth >= 0; depth-- {
+		depth = depth & 0xf // BCE
+
+		n = stack[depth]
+
+		// longest prefix match, skip if node has no prefixes
+		if n.prefixes.Len() == 0 {
+			continue
+		}
+
+		idx := art.OctetToIdx(octets[depth])
+		if !n.lpmTest(idx) {
+			continue
+		}
+
+		// yield all matches in this node, in reverse CIDR order
+		n.eachLookupPrefix(octets, depth, is4, idx, yield)
+		return
+	}
+}
+
+// LookupDiff does a route lookup (longest prefix match) for IP in both
+// tables t and o and returns the matching prefixes and values side by side,
+// e.g. for audit diffs: table A routes this IP via the /16 to X, table B
+// via the /24 to Y.
+//
+// It's a convenience for two calls of [Table.LookupPrefixLPM],
+// a nil table never matches.
+func (t *Table[V]) LookupDiff(o *Table[V], ip netip.Addr) (aPfx, bPfx netip.Prefix, aVal, bVal V, aOK, bOK bool) {
+	if !ip.IsValid() {
+		return
+	}
+
+	probe := netip.PrefixFrom(ip, ip.BitLen())
+
+	if t != nil {
+		aPfx, aVal, aOK = t.LookupPrefixLPM(probe)
+	}
+
+	if o != nil {
+		bPfx, bVal, bOK = o.LookupPrefixLPM(probe)
+	}
+
+	return
+}
+
+// LookupRange does a route lookup (longest prefix match) for IP and
+// returns the first and last address of the matching prefix together
+// with the associated value, e.g. to display the address range of a route.
+func (t *Table[V]) LookupRange(ip netip.Addr) (first, last netip.Addr, val V, ok bool) {
+	if !ip.IsValid() {
+		return
+	}
+
+	lpmPfx, val, ok := t.LookupPrefixLPM(netip.PrefixFrom(ip, ip.BitLen()))
+	if !ok {
+		return
+	}
+
+	return lpmPfx.Addr(), lastAddr(lpmPfx), val, true
+}
+
+// LookupPrefix does a route lookup (longest prefix match) for pfx and
+// returns the associated value and true, or false if no route matched.
+func (t *Table[V]) LookupPrefix(pfx netip.Prefix) (val V, ok bool) {
+	_, val, ok = t.lookupPrefixLPM(pfx, false)
+	return val, ok
+}
+
+// LookupPrefixLPM is similar to [Table.LookupPrefix],
+// but it returns the lpm prefix in addition to value,ok.
+//
+// This method is about 20-30% slower than LookupPrefix and should only
+// be used if the matching lpm entry is also required for other reasons.
+//
+// If LookupPrefixLPM is to be used for IP address lookups,
+// they must be converted to /32 or /128 prefixes.
+func (t *Table[V]) LookupPrefixLPM(pfx netip.Prefix) (lpmPfx netip.Prefix, val V, ok bool) {
+	return t.lookupPrefixLPM(pfx, true)
+}
+
+// LookupIPNet is like [Table.LookupPrefix], but for a legacy
+// *net.IPNet, e.g. from [net.ParseCIDR].
+//
+// A 4-byte mask selects IPv4, the IP may be given in 16-byte
+// IPv4-mapped form. A 16-byte mask selects IPv6.
+// For a nil or invalid IPNet or a non-contiguous mask false is returned.
+func (t *Table[V]) LookupIPNet(ipNet *net.IPNet) (val V, ok bool) {
+	pfx, ok := prefixFromIPNet(ipNet)
+	if !ok {
+		return
+	}
+	return t.LookupPrefix(pfx)
+}
+
+// LookupIPNetLPM is like [Table.LookupPrefixLPM], but for a legacy
+// *net.IPNet, converted as in [Table.LookupIPNet].
+func (t *Table[V]) LookupIPNetLPM(ipNet *net.IPNet) (lpmPfx netip.Prefix, val V, ok bool) {
+	pfx, ok := prefixFromIPNet(ipNet)
+	if !ok {
+		return
+	}
+	return t.LookupPrefixLPM(pfx)
+}
+
+// prefixFromIPNet converts ipNet to a netip.Prefix, false for an
+// invalid IPNet or a non-contiguous mask.
+func prefixFromIPNet(ipNet *net.IPNet) (netip.Prefix, bool) {
+	if ipNet == nil {
+		return netip.Prefix{}, false
+	}
+
+	// bits is 0 for a non-canonical mask
+	ones, bits := ipNet.Mask.Size()
+
+	addr, ok := netip.AddrFromSlice(ipNet.IP)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+
+	switch bits {
+	case 32:
+		// 4-in-6 from net.ParseIP et al.
+		addr = addr.Unmap()
+		if !addr.Is4() {
+			return netip.Prefix{}, false
+		}
+	case 128:
+		addr = netip.AddrFrom16(addr.As16())
+	default:
+		return netip.Prefix{}, false
+	}
+
+	return netip.PrefixFrom(addr, ones), true
+}
+
+func (t *Table[V]) lookupPrefixLPM(pfx netip.Prefix, withLPM bool) (lpmPfx netip.Prefix, val V, ok bool) {
+	if !pfx.IsValid() {
+		return
+	}
+
+	// canonicalize the prefix
+	pfx = pfx.Masked()
+
+	ip := pfx.Addr()
+	bits := pfx.Bits()
+
+	// fast path for host routes, same as Lookup(ip)
+	if bits == ip.BitLen() {
+		if !withLPM {
+			val, ok = t.lookup(ip)
+			return
+		}
+		return t.lookupHostLPM(ip)
+	}
+
+	is4 := ip.Is4()
+	octets := ip.AsSlice()
+	maxDepth, lastBits := maxDepthAndLastBits(bits)
+
+	n := t.rootNodeByVersion(is4)
+
+	// record path to leaf node
+	stack := [maxTreeDepth]*node[V]{}
+
+	var depth int
+	var octet byte
+
+LOOP:
+	// find the last node on the octets path in the trie,
+	for depth, octet = range octets {
+		depth = depth & 0xf // BCE
+
+		if depth > maxDepth {
+			depth--
+			break
+		}
+		// push current node on stack
+		stack[depth] = n
+
+		// go down in tight loop to leaf node
+		if !n.children.Test(octet) {
+			break LOOP
+		}
+		kid := n.children.MustGet(octet)
+
+		// kid is node or leaf or fringe at octet
+		switch kid := kid.(type) {
+		case *node[V]:
+			n = kid
+			continue LOOP // descend down to next trie level
+
+		case *leafNode[V]:
+			// reached a path compressed prefix, stop traversing
+			if kid.prefix.Bits() > bits || !kid.prefix.Contains(ip) {
+				break LOOP
+			}
+			return kid.prefix, kid.value, true
+
+		case *fringeNode[V]:
+			// the bits of the fringe are defined by the depth
+			// maybe the LPM isn't needed, saves some cycles
+			fringeBits := (depth + 1) << 3
+			if fringeBits > bits {
+				break LOOP
+			}
+
+			// the LPM isn't needed, saves some cycles
+			if !withLPM {
+				return netip.Prefix{}, kid.value, true
+			}
+
+			// sic, get the LPM prefix back, it costs some cycles!
+			fringePfx := cidrForFringe(octets, depth, is4, octet)
+			return fringePfx, kid.value, true
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	// start backtracking, unwind the stack
+	for ; depth >= 0; depth-- {
+		depth = depth & 0xf // BCE
+
+		n = stack[depth]
+
+		// longest prefix match, skip if node has no prefixes
+		if n.prefixes.Len() == 0 {
+			continue
+		}
+
+		// only the lastOctet may have a different prefix len
+		// all others are just host routes
+		var idx uint
+		octet = octets[depth]
+		if depth == maxDepth {
+			idx = uint(art.PfxToIdx(octet, lastBits))
+		} else {
+			idx = art.OctetToIdx(octet)
+		}
+
+		// manually inlined: lpmGet(idx)
+		if topIdx, ok := n.prefixes.IntersectionTop(lpm.BackTrackingBitset(idx)); ok {
+			val = n.prefixes.MustGet(topIdx)
+
+			// called from LookupPrefix
+			if !withLPM {
+				return netip.Prefix{}, val, ok
+			}
+
+			// called from LookupPrefixLPM
+
+			// get the bits from depth and top idx
+			pfxBits := int(art.PfxBits(depth, topIdx))
+
+			// calculate the lpmPfx from incoming ip and new mask
+			lpmPfx, _ = ip.Prefix(pfxBits)
+			return lpmPfx, val, ok
+		}
+	}
+
+	return
+}
+
+// lookupHostLPM is the host route variant of lookupPrefixLPM,
+// the octets are walked like in [Table.Lookup], all indices are host indices.
+func (t *Table[V]) lookupHostLPM(ip netip.Addr) (lpmPfx netip.Prefix, val V, ok bool) {
+	is4 := ip.Is4()
+	octets := ip.AsSlice()
+
+	n := t.rootNodeByVersion(is4)
+
+	// stack of the traversed nodes for fast backtracking, if needed
+	stack := [maxTreeDepth]*node[V]{}
+
+	// run variable, used after for loop
+	var depth int
+	var octet byte
+
+LOOP:
+	// find leaf node
+	for depth, octet = range octets {
+		depth = depth & 0xf // BCE
+
+		// push current node on stack for fast backtracking
+		stack[depth] = n
+
+		// go down in tight loop to last octet
+		if !n.children.Test(octet) {
+			// no more nodes below octet
+			break LOOP
+		}
+		kid := n.children.MustGet(octet)
+
+		// kid is node or leaf or fringe at octet
+		switch kid := kid.(type) {
+		case *node[V]:
+			n = kid
+			continue // descend down to next trie level
+
+		case *fringeNode[V]:
+			// fringe is the default-route for all possible nodes below
+			return cidrForFringe(octets, depth, is4, octet), kid.value, true
+
+		case *leafNode[V]:
+			if kid.prefix.Contains(ip) {
+				return kid.prefix, kid.value, true
+			}
+			// reached a path compressed prefix, stop traversing
+			break LOOP
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	// start backtracking, unwind the stack, bounds check eliminated
+	for ; depth >= 0; depth-- {
+		depth = depth & 0xf // BCE
+
+		n = stack[depth]
+
+		// longest prefix match, skip if node has no prefixes
+		if n.prefixes.Len() != 0 {
+			idx := art.OctetToIdx(octets[depth])
+			if topIdx, ok := n.prefixes.IntersectionTop(lpm.BackTrackingBitset(idx)); ok {
+				// get the bits from depth and top idx
+				lpmPfx, _ = ip.Prefix(int(art.PfxBits(depth, topIdx)))
+				return lpmPfx, n.prefixes.MustGet(topIdx), true
+			}
+		}
+	}
+
+	return
+}
+
+// Supernets returns an iterator over all supernet routes that cover the given prefix pfx.
+//
+// The traversal searches both exact-length and shorter (less specific) prefixes that
+// overlap or include pfx. Starting from the most specific position in the trie,
+// it walks upward through parent nodes and yields any matching entries found at each level.
+//
+// The iteration order is reverse-CIDR: from longest prefix match (LPM) towards
+// least-specific routes.
+//
+// The search is protocol-specific (IPv4 or IPv6) and stops immediately if the yield
+// function returns false. If pfx is invalid, the function silently returns.
+//
+// This can be used to enumerate all covering supernet routes in routing-based
+// policy engines, diagnostics tools, or fallback resolution logic.
+//
+// Example:
+//
+//	for supernet, val := range table.Supernets(netip.MustParsePrefix("192.0.2.128/25")) {
+//	    fmt.Println("Matched covering route:", supernet, "->", val)
+//	}
+func (t *Table[V]) Supernets(pfx netip.Prefix) func(yield func(netip.Prefix, V) bool) {
+	return func(yield func(netip.Prefix, V) bool) {
+		if !pfx.IsValid() {
+			return
+		}
+
+		// canonicalize the prefix
+		pfx = pfx.Masked()
+
+		ip := pfx.Addr()
+		is4 := ip.Is4()
+		bits := pfx.Bits()
+		octets := ip.AsSlice()
+		maxDepth, lastBits := maxDepthAndLastBits(bits)
+
+		n := t.rootNodeByVersion(is4)
+
+		// stack of the traversed nodes for reverse ordering of supernets
+		stack := [maxTreeDepth]*node[V]{}
+
+		// run variable, used after for loop
+		var depth int
+		var octet byte
+
+		// find last node along this octet path
+	LOOP:
+		for depth, octet = range octets {
+			if depth > maxDepth {
+				depth--
+				break
+			}
+			// push current node on stack
+			stack[depth] = n
+
+			// descend down the trie
+			if !n.children.Test(octet) {
+				break LOOP
+			}
+			kid := n.children.MustGet(octet)
+
+			// kid is node or leaf or fringe at octet
+			switch kid := kid.(type) {
+			case *node[V]:
+				n = kid
+				continue LOOP // descend down to next trie level
+
+			case *leafNode[V]:
+				if kid.prefix.Bits() > pfx.Bits() {
+					break LOOP
+				}
+
+				if kid.prefix.Overlaps(pfx) {
+					if !yield(kid.prefix, kid.value) {
+						// early exit
+						return
+					}
+				}
+				// end of trie along this octets path
+				break LOOP
+
+			case *fringeNode[V]:
+				fringePfx := cidrForFringe(octets, depth, is4, octet)
+				if fringePfx.Bits() > pfx.Bits() {
+					break LOOP
+				}
+
+				if fringePfx.Overlaps(pfx) {
+					if !yield(fringePfx, kid.value) {
+						// early exit
+						return
+					}
+				}
+				// end of trie along this octets path
+				break LOOP
+
+			default:
+				panic("logic error, wrong node type")
+			}
+		}
+
+		// start backtracking, unwind the stack
+		for ; depth >= 0; depth-- {
+			n = stack[depth]
+
+			// only the lastOctet may have a different prefix len
+			// all others are just host routes
+			var idx uint
+			octet = octets[depth]
+			if depth == maxDepth {
+				idx = uint(art.PfxToIdx(octet, lastBits))
+			} else {
+				idx = art.OctetToIdx(octet)
+			}
+
+			// micro benchmarking, skip if there is no match
+			if !n.lpmTest(idx) {
+				continue
+			}
+
+			// yield all the matching prefixes, not just the lpm
+			if !n.eachLookupPrefix(octets, depth, is4, idx, yield) {
+				// early exit
+				return
+			}
+		}
+	}
+}
+
+// SupernetCount returns the number of stored prefixes that cover pfx,
+// including pfx itself. It's the counting variant of [Table.Supernets]
+// without materializing the supernets, e.g. for a route ambiguity metric.
+func (t *Table[V]) SupernetCount(pfx netip.Prefix) (count int) {
+	if !pfx.IsValid() {
+		return 0
+	}
+
+	// canonicalize the prefix
+	pfx = pfx.Masked()
+
+	ip := pfx.Addr()
+	is4 := ip.Is4()
+	bits := pfx.Bits()
+	octets := ip.AsSlice()
+	maxDepth, lastBits := maxDepthAndLastBits(bits)
+
+	n := t.rootNodeByVersion(is4)
+
+	// count the matching prefixes in each node along the octet path
+	for depth, octet := range octets {
+		if depth > maxDepth {
+			break
+		}
+
+		// only the lastOctet may have a different prefix len
+		// all others are just host routes
+		var idx uint
+		if depth == maxDepth {
+			idx = uint(art.PfxToIdx(octet, lastBits))
+		} else {
+			idx = art.OctetToIdx(octet)
+		}
+
+		if n.prefixes.Len() != 0 {
+			matches := n.prefixes.Intersection(lpm.BackTrackingBitset(idx))
+			count += matches.Count()
+		}
+
+		// descend down the trie
+		if depth == maxDepth || !n.children.Test(octet) {
+			break
+		}
+		kid := n.children.MustGet(octet)
+
+		// kid is node or leaf or fringe at octet
+		switch kid := kid.(type) {
+		case *node[V]:
+			n = kid
+			continue // descend down to next trie level
+
+		case *leafNode[V]:
+			if kid.prefix.Bits() <= bits && kid.prefix.Contains(ip) {
+				count++
+			}
+			return count
+
+		case *fringeNode[V]:
+			if (depth+1)<<3 <= bits {
+				count++
+			}
+			return count
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	return count
+}
+
+// Subnets returns an iterator over all prefix–value pairs in the routing table
+// that are fully contained within the given prefix pfx.
+//
+// Entries are returned in CIDR sort order.
+//
+// Example:
+//
+//	for sub, val := range table.Subnets(netip.MustParsePrefix("10.0.0.0/8")) {
+//	    fmt.Println("Covered:", sub, "->", val)
+//	}
+func (t *Table[V]) Subnets(pfx netip.Prefix) func(yield func(netip.Prefix, V) bool) {
+	return func(yield func(netip.Prefix, V) bool) {
+		if !pfx.IsValid() {
+			return
+		}
+
+		// canonicalize the prefix
+		pfx = pfx.Masked()
+
+		// values derived from pfx
+		ip := pfx.Addr()
+		is4 := ip.Is4()
+		bits := pfx.Bits()
+		octets := ip.AsSlice()
+		maxDepth, lastBits := maxDepthAndLastBits(bits)
+
+		n := t.rootNodeByVersion(is4)
+
+		// find the trie node
+		for depth, octet := range octets {
+			if depth == maxDepth {
+				idx := art.PfxToIdx(octet, lastBits)
+				_ = n.eachSubnet(octets, depth, is4, idx, yield)
+				return
+			}
+
+			if !n.children.Test(octet) {
+				return
+			}
+			kid := n.children.MustGet(octet)
+
+			// kid is node or leaf or fringe at octet
+			switch kid := kid.(type) {
+			case *node[V]:
+				n = kid
+				continue // descend down to next trie level
+
+			case *leafNode[V]:
+				if pfx.Bits() <= kid.prefix.Bits() && pfx.Overlaps(kid.prefix) {
+					_ = yield(kid.prefix, kid.value)
+				}
+				return
+
+			case *fringeNode[V]:
+				fringePfx := cidrForFringe(octets, depth, is4, octet)
+				if pfx.Bits() <= fringePfx.Bits() && pfx.Overlaps(fringePfx) {
+					_ = yield(fringePfx, kid.value)
+				}
+				return
+
+			default:
+				panic("logic error, wrong node type")
+			}
+		}
+	}
+}
+
+// OverlapsPrefix reports whether any route in the table overlaps with the given pfx or vice versa.
+//
+// The check is bidirectional: it returns true if the input prefix is covered by an existing
+// route, or if any stored route is itself contained within the input prefix.
+//
+// Internally, the function normalizes the prefix and descends the relevant trie branch,
+// using stride-based logic to identify overlap without performing a full lookup.
+//
+// This is useful for containment tests, route validation, or policy checks using prefix
+// semantics without retrieving exact matches.
+func (t *Table[V]) OverlapsPrefix(pfx netip.Prefix) bool {
+	if !pfx.IsValid() {
+		return false
+	}
+
+	// canonicalize the prefix
+	pfx = pfx.Masked()
+
+	is4 := pfx.Addr().Is4()
+	n := t.rootNodeByVersion(is4)
+
+	return n.overlapsPrefixAtDepth(pfx, 0)
+}
+
+// OverlapsPrefixExcept is like [Table.OverlapsPrefix], but the stored
+// route except doesn't count as overlap, e.g. to re-validate a route
+// during an in-place edit without matching against itself.
+//
+// The table isn't modified, it's safe for concurrent readers.
+// If except overlaps pfx, the covering routes are counted without except
+// and at most two subnets of pfx are visited.
+func (t *Table[V]) OverlapsPrefixExcept(pfx netip.Prefix, except netip.Prefix) bool {
+	if !pfx.IsValid() {
+		return false
+	}
+
+	// canonicalize the prefixes
+	pfx = pfx.Masked()
+	except = except.Masked()
+
+	// except can't be responsible for an overlap
+	if !except.IsValid() || !except.Overlaps(pfx) || !t.Has(except) {
+		return t.OverlapsPrefix(pfx)
+	}
+
+	// the covering routes, including pfx itself, without except
+	count := t.SupernetCount(pfx)
+	if except.Bits() <= pfx.Bits() {
+		count--
+	}
+
+	if count > 0 {
+		return true
+	}
+
+	// the covered routes, without except
+	found := false
+	t.Subnets(pfx)(func(sub netip.Prefix, _ V) bool {
+		found = sub != except
+		return !found
+	})
+
+	return found
+}
+
+// OverlapsPrefixMaxBits is like [Table.OverlapsPrefix], but only routes
+// with a prefix length <= maxBits count, e.g. to test whether any
+// aggregate of /16 or shorter covers pfx, ignoring the more-specifics.
+//
+// maxBits must be in the range of the address family of pfx,
+// [0..32] for IPv4 and [0..128] for IPv6, otherwise false is returned.
+func (t *Table[V]) OverlapsPrefixMaxBits(pfx netip.Prefix, maxBits int) bool {
+	if !pfx.IsValid() || maxBits < 0 || maxBits > pfx.Addr().BitLen() {
+		return false
+	}
+
+	// canonicalize the prefix
+	pfx = pfx.Masked()
+
+	ip := pfx.Addr()
+	is4 := ip.Is4()
+	bits := pfx.Bits()
+
+	// covering routes, including pfx, up to maxBits
+	coverBits := bits
+	if maxBits < coverBits {
+		coverBits = maxBits
+	}
+
+	if _, ok := t.LookupPrefix(netip.PrefixFrom(ip, coverBits)); ok {
+		return true
+	}
+
+	if maxBits <= bits {
+		return false
+	}
+
+	// covered routes, longer than pfx, up to maxBits
+	octets := ip.AsSlice()
+	maxDepth, lastBits := maxDepthAndLastBits(bits)
+
+	n := t.rootNodeByVersion(is4)
+
+	// find the trie node
+	for depth, octet := range octets {
+		if depth == maxDepth {
+			return n.subnetsMaxBits(depth, art.PfxToIdx(octet, lastBits), maxBits)
+		}
+
+		if !n.children.Test(octet) {
+			return false
+		}
+		kid := n.children.MustGet(octet)
+
+		// kid is node or leaf or fringe at octet
+		switch kid := kid.(type) {
+		case *node[V]:
+			n = kid
+			continue // descend down to next trie level
+
+		case *leafNode[V]:
+			return kid.prefix.Bits() <= maxBits && pfx.Overlaps(kid.prefix)
+
+		case *fringeNode[V]:
+			fringePfx := cidrForFringe(octets, depth, is4, octet)
+			return fringePfx.Bits() <= maxBits && pfx.Overlaps(fringePfx)
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	return false
+}
+
+// OverlapsAny reports whether any route in the table overlaps with
+// any of the given prefixes, see [Table.OverlapsPrefix].
+// It returns true on the first overlap and false for an empty slice.
+//
+// The candidates are sorted first, a candidate covered by an already
+// tested prefix without overlap can't overlap either and is skipped.
+func (t *Table[V]) OverlapsAny(pfxs []netip.Prefix) bool {
+	if len(pfxs) == 0 || t.IsEmpty() {
+		return false
+	}
+
+	// canonicalize and sort the candidates
+	sorted := make([]netip.Prefix, 0, len(pfxs))
+	for _, pfx := range pfxs {
+		if pfx.IsValid() {
+			sorted = append(sorted, pfx.Masked())
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return lessPrefix(sorted[i], sorted[j])
+	})
+
+	// the last tested prefix, all its subnets follow in sort order
+	var last netip.Prefix
+
+	for _, pfx := range sorted {
+		if last.IsValid() && last.Bits() <= pfx.Bits() && last.Contains(pfx.Addr()) {
+			continue
+		}
+
+		n := t.rootNodeByVersion(pfx.Addr().Is4())
+		if n.overlapsPrefixAtDepth(pfx, 0) {
+			return true
+		}
+
+		last = pfx
+	}
+
+	return false
+}
+
+// Overlaps reports whether any route in the receiver table overlaps
+// with a route in the other table, in either direction.
+//
+// The overlap check is bidirectional: it returns true if any IP prefix
+// in the receiver is covered by the other table, or vice versa.
+// This includes partial overlaps, exact matches, and supernet/subnet relationships.
+//
+// Both IPv4 and IPv6 route trees are compared independently. If either
+// tree has overlapping routes, the function returns true.
+//
+// This is useful for conflict detection, policy enforcement,
+// or validating mutually exclusive routing domains.
+func (t *Table[V]) Overlaps(o *Table[V]) bool {
+	return t.Overlaps4(o) || t.Overlaps6(o)
+}
+
+// Overlaps4 is like [Table.Overlaps] but for the v4 routing table only.
+func (t *Table[V]) Overlaps4(o *Table[V]) bool {
+	if t.size4 == 0 || o.size4 == 0 {
+		return false
+	}
+	return t.root4.overlaps(&o.root4, 0)
+}
+
+// Overlaps6 is like [Table.Overlaps] but for the v6 routing table only.
+func (t *Table[V]) Overlaps6(o *Table[V]) bool {
+	if t.size6 == 0 || o.size6 == 0 {
+		return false
+	}
+	return t.root6.overlaps(&o.root6, 0)
+}
+
+// OverlapsDetail is like [Table.Overlaps], but returns one concrete
+// overlapping pair, prefix a from the receiver and prefix b from o,
+// e.g. to show the operator an example of two conflicting route sets.
+//
+// The pair is deterministic for equal tables: a is the first prefix of the
+// receiver in the order of [Table.All] that overlaps o. b is the longest
+// prefix in o covering a, or else the first subnet of a in o in CIDR order.
+//
+// It's meant for diagnostics, after the overlap test the
+// receiver is walked until the first overlapping prefix.
+func (t *Table[V]) OverlapsDetail(o *Table[V]) (a, b netip.Prefix, ok bool) {
+	if !t.Overlaps(o) {
+		return
+	}
+
+	t.All()(func(pfx netip.Prefix, _ V) bool {
+		if !o.OverlapsPrefix(pfx) {
+			return true
+		}
+
+		// a route in o covers pfx, or else pfx covers routes in o
+		if lpmPfx, _, found := o.LookupPrefixLPM(pfx); found {
+			b = lpmPfx
+		} else {
+			o.Subnets(pfx)(func(sub netip.Prefix, _ V) bool {
+				b = sub
+				return false
+			})
+		}
+
+		a, ok = pfx, true
+		return false
+	})
+
+	return a, b, ok
+}
+
+// Union merges another routing table into the receiver table, modifying it in-place.
+//
+// All prefixes and values from the other table (o) are inserted into the receiver.
+// If a duplicate prefix exists in both tables, the value from o replaces the existing entry.
+// This duplicate is shallow-copied by default, but if the value type V implements the
+// Cloner interface, the value is deeply cloned before insertion. See also Table.Clone.
+//
+// Union returns the number of duplicate prefixes, present in both tables.
+func (t *Table[V]) Union(o *Table[V]) (duplicates int) {
+	return t.union(o, nil)
+}
+
+// UnionFunc is like [Table.Union], but for duplicate prefixes the values
+// are combined by the merge callback instead of last-writer-wins.
+//
+// merge is called with the duplicate prefix, the value from the receiver (a)
+// and the value from the other table (b), its return value is stored in the receiver.
+// As with Union, the value from o is cloned if V implements the [Cloner] interface.
+//
+// The duplicates are merged during the same trie descent as Union,
+// UnionFunc returns the number of duplicate prefixes, present in both tables.
+// If merge is nil, UnionFunc behaves like Union.
+func (t *Table[V]) UnionFunc(o *Table[V], merge func(pfx netip.Prefix, a, b V) V) (duplicates int) {
+	return t.union(o, merge)
+}
+
+// union is the implementation of Union and UnionFunc,
+// duplicates are combined by merge, if not nil.
+func (t *Table[V]) union(o *Table[V], merge mergeFunc[V]) (duplicates int) {
+	// Create a cloning function for deep copying values;
+	// returns nil if V does not implement the Cloner interface.
+	cloneFn := cloneFnFactory[V]()
+	if cloneFn == nil {
+		cloneFn = copyVal[V]
+	}
+
+	t.unshare()
+
+	if t.onChange != nil {
+		// slow path, report each prefix to the observer
+		o.All()(func(pfx netip.Prefix, oVal V) bool {
+			t.Update(pfx, func(tVal V, exists bool) V {
+				if !exists {
+					return cloneFn(oVal)
+				}
+				duplicates++
+				if merge != nil {
+					return merge(pfx, tVal, cloneFn(oVal))
+				}
+				return cloneFn(oVal)
+			})
+			return true
+		})
+		return duplicates
+	}
+
+	cloneFn = t.internCloneFn(cloneFn)
+
+	// the merged values are interned too
+	if merge != nil && t.intern != nil {
+		combine := merge
+		merge = func(pfx netip.Prefix, a, b V) V { return t.intern(combine(pfx, a, b)) }
+	}
+
+	dup4 := t.root4.unionRec(cloneFn, merge, &o.root4, stridePath{}, 0, true)
+	dup6 := t.root6.unionRec(cloneFn, merge, &o.root6, stridePath{}, 0, false)
+
+	t.size4 += o.size4 - dup4
+	t.size6 += o.size6 - dup6
+
+	t.missFilterUnion(o)
+
+	return dup4 + dup6
+}
+
+// Absorb is like [Table.Union], but it consumes the other table o.
+// The nodes, leaves and values of o are moved into the receiver
+// instead of cloned, this is much cheaper for big tables, e.g. when
+// merging the results of parallel table builders.
+//
+// After Absorb, o is empty and can be reused. The values are not cloned,
+// even if V implements the [Cloner] interface. The nodes of o must not be
+// shared with other tables, e.g. tables derived by the ...Persist methods,
+// otherwise these tables are corrupted.
+//
+// With an observer or an interner registered on the receiver, or if o is
+// a lazy clone, Absorb falls back to Union and deletes the prefixes from o.
+func (t *Table[V]) Absorb(o *Table[V]) (duplicates int) {
+	if o == nil || o == t {
+		return 0
+	}
+
+	if t.onChange != nil || t.intern != nil || o.shared.Load() || o.onChange != nil {
+		// slow path, copy the values and report each change
+		duplicates = t.Union(o)
+
+		var pfxs []netip.Prefix
+		o.All()(func(pfx netip.Prefix, _ V) bool {
+			pfxs = append(pfxs, pfx)
+			return true
+		})
+		o.DeleteMany(pfxs)
+
+		return duplicates
+	}
+
+	t.unshare()
+	t.missFilterUnion(o)
+
+	dup4 := t.root4.absorbRec(&o.root4, 0)
+	dup6 := t.root6.absorbRec(&o.root6, 0)
+
+	t.size4 += o.size4 - dup4
+	t.size6 += o.size6 - dup6
+
+	// o is now empty, its nodes belong to t
+	o.gen++
+	o.root4 = node[V]{}
+	o.root6 = node[V]{}
+	o.size4 = 0
+	o.size6 = 0
+	o.sources = nil
+	if o.filter != nil {
+		*o.filter = missFilter{}
+	}
+
+	return dup4 + dup6
+}
+
+// ErrMergeConflict is returned by [Table.MergeStrict] if both tables
+// hold the same prefix with differing values.
+var ErrMergeConflict = errors.New("bart: merge conflict")
+
+// MergeStrict is like [Table.Union], but duplicate prefixes must have equal
+// values in both tables, compared by eq, e.g. to catch two config sources
+// disagreeing on the same route.
+//
+// All prefixes present in both tables with differing values are returned
+// as conflicts, in canonical CIDR sort order, together with [ErrMergeConflict].
+// In that case the merge is aborted and the receiver is left unmodified.
+// Use [Table.MergeLenient] to merge anyway.
+func (t *Table[V]) MergeStrict(o *Table[V], eq func(a, b V) bool) (conflicts []netip.Prefix, err error) {
+	o.AllSorted()(func(pfx netip.Prefix, oVal V) bool {
+		if tVal, ok := t.Get(pfx); ok && !eq(tVal, oVal) {
+			conflicts = append(conflicts, pfx)
+		}
+		return true
+	})
+
+	if len(conflicts) != 0 {
+		return conflicts, fmt.Errorf("%w: %d differing prefixes", ErrMergeConflict, len(conflicts))
+	}
+
+	t.Union(o)
+	return nil, nil
+}
+
+// MergeLenient is like [Table.MergeStrict], but the merge is not aborted.
+// The conflicting prefixes keep the value of the receiver, all other
+// prefixes of o are merged as with [Table.Union].
+//
+// The conflicts are collected during the trie descent of the merge,
+// see [Table.UnionFunc], and returned in canonical CIDR sort order,
+// e.g. to log them after the merge.
+func (t *Table[V]) MergeLenient(o *Table[V], eq func(a, b V) bool) (conflicts []netip.Prefix) {
+	t.UnionFunc(o, func(pfx netip.Prefix, a, b V) V {
+		if eq(a, b) {
+			return b
+		}
+		conflicts = append(conflicts, pfx)
+		return a
+	})
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return lessPrefix(conflicts[i], conflicts[j])
+	})
+
+	return conflicts
+}
+
+// UpdateWhere replaces the value of every entry where match returns true
+// with newVal(old) and returns the number of changed entries.
+//
+// The values are rewritten in place, the trie structure is untouched, e.g.
+// to move all routes from next-hop A to next-hop B without rebuilding the table.
+//
+// UpdateWhere modifies the shared nodes of tables derived
+// by the persistent methods, see [Table.InsertPersist].
+func (t *Table[V]) UpdateWhere(match func(netip.Prefix, V) bool, newVal func(V) V) (count int) {
+	if t == nil {
+		return 0
+	}
+	t.unshare()
+
+	if t.intern != nil {
+		rewrite := newVal
+		newVal = func(val V) V { return t.intern(rewrite(val)) }
+	}
+
+	// record the changed prefixes for the observer
+	var changed []netip.Prefix
+	if t.onChange != nil {
+		accept := match
+		match = func(pfx netip.Prefix, val V) bool {
+			if accept(pfx, val) {
+				changed = append(changed, pfx)
+				return true
+			}
+			return false
+		}
+	}
+
+	count += t.root4.updateWhereRec(stridePath{}, 0, true, match, newVal)
+	count += t.root6.updateWhereRec(stridePath{}, 0, false, match, newVal)
+
+	for _, pfx := range changed {
+		val, _ := t.Get(pfx)
+		t.notify(ChangeUpdate, pfx, val)
+	}
+
+	return count
+}
+
+// SetAll overwrites the value of every entry with val, e.g. to mark
+// a snapshot with a generation counter before diffing.
+//
+// Like [Table.UpdateWhere], the values are rewritten in place and the
+// shared nodes of tables derived by the persistent methods are modified.
+// Without an observer no prefixes are reconstructed, it's cheaper than
+// UpdateWhere with a constant value.
+func (t *Table[V]) SetAll(val V) {
+	if t == nil {
+		return
+	}
+
+	if t.onChange != nil {
+		t.UpdateWhere(
+			func(netip.Prefix, V) bool { return true },
+			func(V) V { return val })
+		return
+	}
+
+	t.unshare()
+	val = t.internVal(val)
+
+	t.root4.setAllRec(val)
+	t.root6.setAllRec(val)
+}
+
+// Coarsen returns a new table with all routes longer than maxBits4 (IPv4)
+// or maxBits6 (IPv6) replaced by a single entry at the max prefix length,
+// e.g. for devices with a limited FIB. Routes up to the max length are kept as-is.
+//
+// The value of a coarsened entry is chosen by pick from the values of all
+// covered routes in canonical CIDR sort order, including an existing route
+// at exactly the max length as first element.
+// If pick is nil, the first value is taken.
+func (t *Table[V]) Coarsen(maxBits4, maxBits6 int, pick func(covered []V) V) *Table[V] {
+	if t == nil {
+		return nil
+	}
+
+	if pick == nil {
+		pick = func(covered []V) V { return covered[0] }
+	}
+
+	c := &Table[V]{intern: t.intern, fallback: t.fallback}
+	if t.filter != nil {
+		c.filter = new(missFilter)
+	}
+
+	// the covered values, grouped by the coarsened prefix
+	groups := make(map[netip.Prefix][]V)
+	var order []netip.Prefix
+
+	t.AllSorted()(func(pfx netip.Prefix, val V) bool {
+		maxBits := maxBits6
+		if pfx.Addr().Is4() {
+			maxBits = maxBits4
+		}
+		if maxBits < 0 {
+			maxBits = 0
+		}
+
+		if pfx.Bits() < maxBits {
+			c.Insert(pfx, val)
+			return true
+		}
+
+		coarse := netip.PrefixFrom(pfx.Addr(), maxBits).Masked()
+		if _, ok := groups[coarse]; !ok {
+			order = append(order, coarse)
+		}
+		groups[coarse] = append(groups[coarse], val)
+
+		return true
+	})
+
+	for _, coarse := range order {
+		c.Insert(coarse, pick(groups[coarse]))
+	}
+
+	return c
+}
+
+// Clone returns a copy of the routing table.
+// The payload of type V is shallow copied, but if type V implements the [Cloner] interface,
+// the values are cloned.
+//
+// If V doesn't implement the Cloner interface, the clone is lazy and
+// near O(1): both tables share the trie nodes, copy-on-write.
+// The first in-place modification of either table copies its trie,
+// after that the tables are fully independent.
+func (t *Table[V]) Clone() *Table[V] {
+	if t == nil {
+		return nil
+	}
+
+	c := new(Table[V])
+
+	cloneFn := cloneFnFactory[V]()
+
+	if cloneFn == nil {
+		// lazy clone, share the nodes
+		c.root4 = t.root4
+		c.root6 = t.root6
+
+		t.shared.Store(true)
+		c.shared.Store(true)
+	} else {
+		c.root4 = *t.root4.cloneRec(cloneFn)
+		c.root6 = *t.root6.cloneRec(cloneFn)
+	}
+
+	c.size4 = t.size4
+	c.size6 = t.size6
+
+	c.filter = t.filter.clone()
+	c.intern = t.intern
+	c.fallback = t.fallback
+
+	return c
+}
+
+// unshare copies the trie before an in-place modification,
+// if the nodes may be shared with a lazy clone, see Clone.
+// It also bumps the modification counter, all in-place
+// modifications must call unshare first.
+//
+// The persistent methods never modify nodes in place, they
+// just pass the shared flag to the new table.
+func (t *Table[V]) unshare() {
+	t.gen++
+
+	if !t.shared.Load() {
+		return
+	}
+
+	// values are shallow copied, lazy clones are only used without Cloner
+	t.root4 = *t.root4.cloneRec(nil)
+	t.root6 = *t.root6.cloneRec(nil)
+
+	t.shared.Store(false)
+}
+
+// CloneShallow returns a copy of the routing table, like [Table.Clone],
+// but the values are always copied by assignment, even if type V
+// implements the [Cloner] interface.
+//
+// Only the trie structure is duplicated, pointers, maps, slices, etc. in V
+// are intentionally shared between the table and the copy. Use it for
+// read-mostly snapshots, where the values are never mutated in place.
+// It's cheaper than Clone for types with a costly Clone method.
+func (t *Table[V]) CloneShallow() *Table[V] {
+	if t == nil {
+		return nil
+	}
+
+	c := new(Table[V])
+
+	// nil cloneFn, just copy the values
+	c.root4 = *t.root4.cloneRec(nil)
+	c.root6 = *t.root6.cloneRec(nil)
+
+	c.size4 = t.size4
+	c.size6 = t.size6
+
+	c.filter = t.filter.clone()
+	c.intern = t.intern
+	c.fallback = t.fallback
+
+	return c
+}
+
+// OnlyV4 returns a new table with a copy of just the IPv4 routes,
+// cloned like [Table.Clone]. The trie structure is preserved.
+func (t *Table[V]) OnlyV4() *Table[V] {
+	if t == nil {
+		return nil
+	}
+
+	c := new(Table[V])
+	c.root4 = *t.root4.cloneRec(cloneFnFactory[V]())
+	c.size4 = t.size4
+	c.filter = t.filter.clone()
+	c.intern = t.intern
+	c.fallback = t.fallback
+
+	return c
+}
+
+// OnlyV6 returns a new table with a copy of just the IPv6 routes,
+// cloned like [Table.Clone]. The trie structure is preserved.
+func (t *Table[V]) OnlyV6() *Table[V] {
+	if t == nil {
+		return nil
+	}
+
+	c := new(Table[V])
+	c.root6 = *t.root6.cloneRec(cloneFnFactory[V]())
+	c.size6 = t.size6
+	c.filter = t.filter.clone()
+	c.intern = t.intern
+	c.fallback = t.fallback
+
+	return c
+}
+
+// Partition splits the prefixes of t and o into three new tables,
+// the prefixes only in t (onlyA), only in o (onlyB) and in both tables.
+// The values for both are taken from the receiver t. The values are
+// cloned like [Table.Clone], e.g. to reconcile the intended with the actual FIB.
+//
+// The new tables are built by a walk over both tables in CIDR sort order
+// with an exact-match test in the other table.
+func (t *Table[V]) Partition(o *Table[V]) (onlyA, onlyB, both *Table[V]) {
+	cloneFn := cloneFnFactory[V]()
+	if cloneFn == nil {
+		cloneFn = copyVal[V]
+	}
+
+	// new table in the mode of src, with miss filter, interning and fallback
+	newLike := func(src *Table[V]) *Table[V] {
+		c := &Table[V]{intern: src.intern, fallback: src.fallback}
+		if src.filter != nil {
+			c.filter = new(missFilter)
+		}
+		return c
+	}
+
+	onlyA, onlyB, both = newLike(t), newLike(o), newLike(t)
+
+	t.AllSorted()(func(pfx netip.Prefix, val V) bool {
+		if o.Has(pfx) {
+			both.Insert(pfx, cloneFn(val))
+		} else {
+			onlyA.Insert(pfx, cloneFn(val))
+		}
+		return true
+	})
+
+	o.AllSorted()(func(pfx netip.Prefix, val V) bool {
+		if !t.Has(pfx) {
+			onlyB.Insert(pfx, cloneFn(val))
+		}
+		return true
+	})
+
+	return onlyA, onlyB, both
+}
+
+// IsSubsetOf reports whether every prefix of t exists in o with a value
+// equal to the value in t, as reported by eq, e.g. to verify that a filtered
+// or derived table didn't invent routes. An empty t is a subset of any table.
+//
+// The prefixes of t are probed in o by exact match, the walk stops
+// at the first missing or mismatched prefix.
+func (t *Table[V]) IsSubsetOf(o *Table[V], eq func(a, b V) bool) bool {
+	if t == nil || t.Size() == 0 {
+		return true
+	}
+	if o == nil || t.Size4() > o.Size4() || t.Size6() > o.Size6() {
+		return false
+	}
+
+	subset := true
+	t.All()(func(pfx netip.Prefix, val V) bool {
+		oVal, ok := o.Get(pfx)
+		if !ok || !eq(val, oVal) {
+			subset = false
+		}
+		return subset
+	})
+
+	return subset
+}
+
+// Similarity returns the Jaccard index |A∩B| / |A∪B| of the prefixes
+// of t and o, ignoring the values, e.g. as drift metric between two
+// consecutive FIB snapshots. Two empty tables have the similarity 1.0.
+//
+// The prefixes of the smaller table are probed in the other one by exact
+// match, no result tables are built.
+func (t *Table[V]) Similarity(o *Table[V]) float64 {
+	a, b := t, o
+	if a.Size() > b.Size() {
+		a, b = b, a
+	}
+
+	common := 0
+	a.All()(func(pfx netip.Prefix, _ V) bool {
+		if b.Has(pfx) {
+			common++
+		}
+		return true
+	})
+
+	union := a.Size() + b.Size() - common
+	if union == 0 {
+		return 1.0
+	}
+
+	return float64(common) / float64(union)
+}
+
+func (t *Table[V]) sizeUpdate(is4 bool, n int) {
+	if is4 {
+		t.size4 += n
+		return
+	}
+	t.size6 += n
+}
+
+// Size returns the prefix count.
+func (t *Table[V]) Size() int {
+	return t.size4 + t.size6
+}
+
+// IsEmpty reports whether the table holds no prefixes.
+// It's safe to call on a nil Table.
+func (t *Table[V]) IsEmpty() bool {
+	if t == nil {
+		return true
+	}
+
+	return t.root4.isEmpty() && t.root6.isEmpty()
+}
+
+// Size4 returns the IPv4 prefix count.
+func (t *Table[V]) Size4() int {
+	return t.size4
+}
+
+// Size6 returns the IPv6 prefix count.
+func (t *Table[V]) Size6() int {
+	return t.size6
+}
+
+// Recount walks both tries and returns the true IPv4 and IPv6 prefix counts.
+// The cached counters behind [Table.Size], [Table.Size4] and [Table.Size6]
+// are reset to these counts.
+//
+// The cached counters are maintained incrementally and must always match,
+// Recount is a safety valve and a test helper, it costs a full trie walk.
+func (t *Table[V]) Recount() (v4, v6 int) {
+	if t == nil {
+		return
+	}
 
-			// kid is node or leaf or fringe at octet
-			switch kid := kid.(type) {
-			case *node[V]:
-				n = kid
-				continue // descend down to next trie level
+	s4 := t.root4.nodeStatsRec()
+	s6 := t.root6.nodeStatsRec()
 
-			case *leafNode[V]:
-				if pfx.Bits() <= kid.prefix.Bits() && pfx.Overlaps(kid.prefix) {
-					_ = yield(kid.prefix, kid.value)
-				}
-				return
+	t.size4 = s4.pfxs + s4.leaves + s4.fringes
+	t.size6 = s6.pfxs + s6.leaves + s6.fringes
 
-			case *fringeNode[V]:
-				fringePfx := cidrForFringe(octets, depth, is4, octet)
-				if pfx.Bits() <= fringePfx.Bits() && pfx.Overlaps(fringePfx) {
-					_ = yield(fringePfx, kid.value)
-				}
-				return
+	return t.size4, t.size6
+}
 
-			default:
-				panic("logic error, wrong node type")
-			}
-		}
+// EstimatedBytes returns an analytical estimate of the memory used by
+// the table, computed from the sizes of the trie nodes, leaves and fringes
+// and the capacities of their item slices.
+//
+// Unlike [runtime.ReadMemStats] it's cheap, local to the table and doesn't
+// stop the world, e.g. for production metrics. Memory referenced by the
+// values, like the bytes of strings, the data behind pointers and
+// allocator overhead isn't included.
+func (t *Table[V]) EstimatedBytes() int {
+	if t == nil {
+		return 0
 	}
+
+	bytes := int(unsafe.Sizeof(*t))
+	bytes += t.root4.estimatedBytesRec()
+	bytes += t.root6.estimatedBytesRec()
+
+	if t.filter != nil {
+		bytes += int(unsafe.Sizeof(*t.filter))
+	}
+
+	return bytes
 }
 
-// OverlapsPrefix reports whether any route in the table overlaps with the given pfx or vice versa.
-//
-// The check is bidirectional: it returns true if the input prefix is covered by an existing
-// route, or if any stored route is itself contained within the input prefix.
+// CompressionRatio returns the number of entries per trie node, e.g. as
+// monitoring metric to detect a Union that pushed many leaves down into
+// new nodes and to decide on a [Table.Recompress].
+// Higher is better, an empty table returns 0.
 //
-// Internally, the function normalizes the prefix and descends the relevant trie branch,
-// using stride-based logic to identify overlap without performing a full lookup.
-//
-// This is useful for containment tests, route validation, or policy checks using prefix
-// semantics without retrieving exact matches.
-func (t *Table[V]) OverlapsPrefix(pfx netip.Prefix) bool {
-	if !pfx.IsValid() {
-		return false
+// The ratio is computed from the stats of both tries, like the dumper.
+func (t *Table[V]) CompressionRatio() float64 {
+	if t == nil {
+		return 0
 	}
 
-	// canonicalize the prefix
-	pfx = pfx.Masked()
+	s4 := t.root4.nodeStatsRec()
+	s6 := t.root6.nodeStatsRec()
 
-	is4 := pfx.Addr().Is4()
-	n := t.rootNodeByVersion(is4)
+	nodes := s4.nodes + s6.nodes
+	if nodes == 0 {
+		return 0
+	}
 
-	return n.overlapsPrefixAtDepth(pfx, 0)
+	entries := s4.pfxs + s4.leaves + s4.fringes + s6.pfxs + s6.leaves + s6.fringes
+	return float64(entries) / float64(nodes)
 }
 
-// Overlaps reports whether any route in the receiver table overlaps
-// with a route in the other table, in either direction.
-//
-// The overlap check is bidirectional: it returns true if any IP prefix
-// in the receiver is covered by the other table, or vice versa.
-// This includes partial overlaps, exact matches, and supernet/subnet relationships.
-//
-// Both IPv4 and IPv6 route trees are compared independently. If either
-// tree has overlapping routes, the function returns true.
-//
-// This is useful for conflict detection, policy enforcement,
-// or validating mutually exclusive routing domains.
-func (t *Table[V]) Overlaps(o *Table[V]) bool {
-	return t.Overlaps4(o) || t.Overlaps6(o)
+// ContainsDefaultRoute reports whether the table holds a default route,
+// 0.0.0.0/0 or ::/0.
+func (t *Table[V]) ContainsDefaultRoute() bool {
+	return t.ContainsDefaultRoute4() || t.ContainsDefaultRoute6()
 }
 
-// Overlaps4 is like [Table.Overlaps] but for the v4 routing table only.
-func (t *Table[V]) Overlaps4(o *Table[V]) bool {
-	if t.size4 == 0 || o.size4 == 0 {
+// ContainsDefaultRoute4 reports whether the table holds 0.0.0.0/0.
+func (t *Table[V]) ContainsDefaultRoute4() bool {
+	if t == nil {
 		return false
 	}
-	return t.root4.overlaps(&o.root4, 0)
+	// the default route is always stored at idx 1 in the root node
+	return t.root4.prefixes.Test(1)
 }
 
-// Overlaps6 is like [Table.Overlaps] but for the v6 routing table only.
-func (t *Table[V]) Overlaps6(o *Table[V]) bool {
-	if t.size6 == 0 || o.size6 == 0 {
+// ContainsDefaultRoute6 reports whether the table holds ::/0.
+func (t *Table[V]) ContainsDefaultRoute6() bool {
+	if t == nil {
 		return false
 	}
-	return t.root6.overlaps(&o.root6, 0)
+	// the default route is always stored at idx 1 in the root node
+	return t.root6.prefixes.Test(1)
 }
 
-// Union merges another routing table into the receiver table, modifying it in-place.
+// HasPrefix reports whether the table holds any prefix of exactly
+// the prefix length bits, IPv4 or IPv6, e.g. to validate route hygiene.
+// Use [Table.Has] for the membership test of a single prefix.
 //
-// All prefixes and values from the other table (o) are inserted into the receiver.
-// If a duplicate prefix exists in both tables, the value from o replaces the existing entry.
-// This duplicate is shallow-copied by default, but if the value type V implements the
-// Cloner interface, the value is deeply cloned before insertion. See also Table.Clone.
-func (t *Table[V]) Union(o *Table[V]) {
-	// Create a cloning function for deep copying values;
-	// returns nil if V does not implement the Cloner interface.
-	cloneFn := cloneFnFactory[V]()
-	if cloneFn == nil {
-		cloneFn = copyVal[V]
+// The tries are walked until the first match, subtries with
+// longer prefixes only are skipped.
+func (t *Table[V]) HasPrefix(bits int) bool {
+	if t == nil || bits < 0 || bits > 128 {
+		return false
 	}
 
-	dup4 := t.root4.unionRec(cloneFn, &o.root4, 0)
-	dup6 := t.root6.unionRec(cloneFn, &o.root6, 0)
+	if bits <= 32 && t.root4.hasPrefixLenRec(0, bits) {
+		return true
+	}
 
-	t.size4 += o.size4 - dup4
-	t.size6 += o.size6 - dup6
+	return t.root6.hasPrefixLenRec(0, bits)
 }
 
-// Clone returns a copy of the routing table.
-// The payload of type V is shallow copied, but if type V implements the [Cloner] interface,
-// the values are cloned.
-func (t *Table[V]) Clone() *Table[V] {
+// PrefixLenHistogram returns the number of prefixes per prefix length,
+// for IPv4 and IPv6, e.g. for a RIB by prefix length chart.
+func (t *Table[V]) PrefixLenHistogram() (v4 [33]int, v6 [129]int) {
 	if t == nil {
-		return nil
+		return
 	}
 
-	c := new(Table[V])
+	t.root4.prefixLenRec(0, v4[:])
+	t.root6.prefixLenRec(0, v6[:])
 
-	cloneFn := cloneFnFactory[V]()
+	return v4, v6
+}
 
-	c.root4 = *t.root4.cloneRec(cloneFn)
-	c.root6 = *t.root6.cloneRec(cloneFn)
+// CountDistinctValues returns the number of unique values stored in the table,
+// e.g. the number of distinct next-hops in a FIB.
+//
+// V must be comparable, see [Table.CountDistinctValuesFunc]
+// for other payloads.
+func CountDistinctValues[V comparable](t *Table[V]) int {
+	if t == nil {
+		return 0
+	}
 
-	c.size4 = t.size4
-	c.size6 = t.size6
+	seen := make(map[V]struct{})
+	t.All()(func(_ netip.Prefix, val V) bool {
+		seen[val] = struct{}{}
+		return true
+	})
 
-	return c
+	return len(seen)
 }
 
-func (t *Table[V]) sizeUpdate(is4 bool, n int) {
-	if is4 {
-		t.size4 += n
-		return
+// CountDistinctValuesFunc is like [CountDistinctValues] for non-comparable values,
+// the values are distinguished by the given hash func. Values with colliding
+// hashes are counted once.
+func (t *Table[V]) CountDistinctValuesFunc(hash func(V) uint64) int {
+	if t == nil {
+		return 0
 	}
-	t.size6 += n
-}
-
-// Size returns the prefix count.
-func (t *Table[V]) Size() int {
-	return t.size4 + t.size6
-}
 
-// Size4 returns the IPv4 prefix count.
-func (t *Table[V]) Size4() int {
-	return t.size4
-}
+	seen := make(map[uint64]struct{})
+	t.All()(func(_ netip.Prefix, val V) bool {
+		seen[hash(val)] = struct{}{}
+		return true
+	})
 
-// Size6 returns the IPv6 prefix count.
-func (t *Table[V]) Size6() int {
-	return t.size6
+	return len(seen)
 }
 
 // All returns an iterator over all prefix–value pairs in the table.
 //
 // The entries from both IPv4 and IPv6 subtries are yielded using an internal recursive traversal.
-// The iteration order is unspecified and may vary between calls; for a stable order, use AllSorted.
+// The iteration order is not the CIDR sort order, but it is stable: repeated calls on an
+// unmodified table yield the same sequence. For canonical CIDR order, use AllSorted.
 //
 // You can use All directly in a for-range loop without providing a yield function.
 // The Go compiler automatically synthesizes the yield callback for you:
@@ -989,6 +3178,78 @@ func (t *Table[V]) All6() func(yield func(netip.Prefix, V) bool) {
 	}
 }
 
+// AllOfLength returns an iterator over the prefixes with exactly bits
+// mask length and their values, IPv4 prefixes first, e.g. to audit all
+// /24 routes. For bits > 32 only IPv6 prefixes are yielded.
+//
+// The walk is pruned to the trie nodes up to depth bits/8,
+// it's faster than filtering the output of [Table.All].
+func (t *Table[V]) AllOfLength(bits int) func(yield func(netip.Prefix, V) bool) {
+	return func(yield func(netip.Prefix, V) bool) {
+		if bits < 0 || bits > 128 {
+			return
+		}
+
+		if bits <= 32 && !t.root4.allOfLengthRec(stridePath{}, 0, true, bits, yield) {
+			return
+		}
+
+		_ = t.root6.allOfLengthRec(stridePath{}, 0, false, bits, yield)
+	}
+}
+
+// First returns the first prefix in CIDR sort order and its value,
+// or false if the table is empty, see [Table.AllSorted].
+// IPv4 prefixes sort before IPv6 prefixes.
+//
+// Just one path of the trie is descended, it's O(depth) and not O(n),
+// e.g. to show the bounds of the table in a paginated UI.
+func (t *Table[V]) First() (pfx netip.Prefix, val V, ok bool) {
+	if t.size4 != 0 {
+		return t.root4.first(stridePath{}, 0, true)
+	}
+	return t.root6.first(stridePath{}, 0, false)
+}
+
+// Last returns the last prefix in CIDR sort order and its value,
+// or false if the table is empty, see [Table.First].
+func (t *Table[V]) Last() (pfx netip.Prefix, val V, ok bool) {
+	if t.size6 != 0 {
+		return t.root6.last(stridePath{}, 0, false)
+	}
+	return t.root4.last(stridePath{}, 0, true)
+}
+
+// allCtxCheckInterval is the number of entries between the context checks
+// in AllCtx, ctx.Err() takes a mutex and must not dominate the walk.
+const allCtxCheckInterval = 1024
+
+// AllCtx is like [Table.All], but the walk is canceled with the context,
+// e.g. to abandon a large table dump when the client disconnects.
+//
+// The context is checked before the walk and then every 1024 entries,
+// on cancellation the walk stops and ctx.Err() is returned.
+// If yield returns false, the walk stops and nil is returned.
+func (t *Table[V]) AllCtx(ctx context.Context, yield func(netip.Prefix, V) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var err error
+	n := 0
+
+	t.All()(func(pfx netip.Prefix, val V) bool {
+		if n++; n%allCtxCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		return yield(pfx, val)
+	})
+
+	return err
+}
+
 // AllSorted returns an iterator over all prefix–value pairs in the table,
 // ordered in canonical CIDR prefix sort order.
 //
@@ -1007,6 +3268,43 @@ func (t *Table[V]) AllSorted() func(yield func(netip.Prefix, V) bool) {
 	}
 }
 
+// AllSortedDesc is like [Table.AllSorted], but in reverse (descending)
+// canonical CIDR sort order, the IPv6 prefixes first, from the highest address
+// and most-specific prefix down to the default routes.
+func (t *Table[V]) AllSortedDesc() func(yield func(netip.Prefix, V) bool) {
+	return func(yield func(netip.Prefix, V) bool) {
+		_ = t.root6.allRecSortedDesc(stridePath{}, 0, false, yield) &&
+			t.root4.allRecSortedDesc(stridePath{}, 0, true, yield)
+	}
+}
+
+// AllFrom returns an iterator over all prefix–value pairs in the table,
+// in canonical CIDR prefix sort order like [Table.AllSorted], but starting
+// at the first prefix greater than or equal to start, e.g. to resume
+// a paginated listing after a page boundary.
+//
+// Subtries before start are skipped without descent. All IPv4 prefixes
+// sort before the IPv6 prefixes. An invalid start yields all prefixes.
+func (t *Table[V]) AllFrom(start netip.Prefix) func(yield func(netip.Prefix, V) bool) {
+	return func(yield func(netip.Prefix, V) bool) {
+		if !start.IsValid() {
+			t.AllSorted()(yield)
+			return
+		}
+
+		// canonicalize the prefix
+		start = start.Masked()
+
+		if start.Addr().Is4() {
+			_ = t.root4.allRecSortedFrom(stridePath{}, 0, true, start, yield) &&
+				t.root6.allRecSorted(stridePath{}, 0, false, yield)
+			return
+		}
+
+		_ = t.root6.allRecSortedFrom(stridePath{}, 0, false, start, yield)
+	}
+}
+
 // AllSorted4 is like [Table.AllSorted] but only for the v4 routing table.
 func (t *Table[V]) AllSorted4() func(yield func(netip.Prefix, V) bool) {
 	return func(yield func(netip.Prefix, V) bool) {
@@ -1020,3 +3318,239 @@ func (t *Table[V]) AllSorted6() func(yield func(netip.Prefix, V) bool) {
 		_ = t.root6.allRecSorted(stridePath{}, 0, false, yield)
 	}
 }
+
+// Prefixes returns an iterator over all prefixes in the table,
+// ordered in canonical CIDR prefix sort order, see [Table.AllSorted].
+//
+//	pfxs := slices.Collect(t.Prefixes())
+func (t *Table[V]) Prefixes() func(yield func(netip.Prefix) bool) {
+	return func(yield func(netip.Prefix) bool) {
+		t.AllSorted()(func(pfx netip.Prefix, _ V) bool {
+			return yield(pfx)
+		})
+	}
+}
+
+// Values returns an iterator over all values in the table,
+// ordered by the canonical CIDR sort order of their prefixes, see [Table.AllSorted].
+func (t *Table[V]) Values() func(yield func(V) bool) {
+	return func(yield func(V) bool) {
+		t.AllSorted()(func(_ netip.Prefix, val V) bool {
+			return yield(val)
+		})
+	}
+}
+
+// AllByValue returns an iterator over all prefix–value pairs sorted by
+// value with less, e.g. to group the routes by next-hop.
+// Prefixes with equal values are yielded in canonical CIDR sort order.
+//
+// The trie isn't indexed by value, AllByValue materializes the
+// whole table in a slice and sorts it before the first yield.
+func (t *Table[V]) AllByValue(less func(a, b V) bool) func(yield func(netip.Prefix, V) bool) {
+	return func(yield func(netip.Prefix, V) bool) {
+		type item struct {
+			pfx netip.Prefix
+			val V
+		}
+
+		items := make([]item, 0, t.Size())
+		t.AllSorted()(func(pfx netip.Prefix, val V) bool {
+			items = append(items, item{pfx, val})
+			return true
+		})
+
+		sort.SliceStable(items, func(i, j int) bool {
+			return less(items[i].val, items[j].val)
+		})
+
+		for _, it := range items {
+			if !yield(it.pfx, it.val) {
+				return
+			}
+		}
+	}
+}
+
+// Sample returns up to n prefixes chosen uniformly at random from
+// the table, e.g. for spot checks and load tests. If the table holds
+// fewer than n prefixes, all prefixes are returned.
+//
+// Sample uses reservoir sampling over the iterator, the memory is O(n)
+// and not O(table size). The result is deterministic for a given
+// state of rng and table content.
+func (t *Table[V]) Sample(n int, rng *rand.Rand) []netip.Prefix {
+	if n <= 0 || t.IsEmpty() {
+		return nil
+	}
+
+	reservoir := make([]netip.Prefix, 0, n)
+	seen := 0
+
+	t.All()(func(pfx netip.Prefix, _ V) bool {
+		seen++
+
+		if len(reservoir) < n {
+			reservoir = append(reservoir, pfx)
+			return true
+		}
+
+		// replace with probability n/seen
+		if j := rng.Intn(seen); j < n {
+			reservoir[j] = pfx
+		}
+		return true
+	})
+
+	return reservoir
+}
+
+// CoveringBlocks returns an iterator over the top-level prefixes,
+// the prefixes not covered by any shorter prefix in the table,
+// in canonical CIDR sort order. These are the roots of the route
+// hierarchies and don't overlap each other, e.g. for a summarized
+// view of the address allocation.
+func (t *Table[V]) CoveringBlocks() func(yield func(netip.Prefix) bool) {
+	return func(yield func(netip.Prefix) bool) {
+		// the last top-level prefix, all its subnets follow in sort order
+		var last netip.Prefix
+
+		t.AllSorted()(func(pfx netip.Prefix, _ V) bool {
+			if last.IsValid() && last.Bits() <= pfx.Bits() && last.Contains(pfx.Addr()) {
+				return true
+			}
+
+			last = pfx
+			return yield(pfx)
+		})
+	}
+}
+
+// ChildrenOf returns an iterator over the immediate more-specifics of pfx,
+// the stored subnets of pfx without any other stored prefix between them
+// and pfx, in canonical CIDR sort order, e.g. to drill down one level
+// in an IPAM browser. pfx itself is excluded, it needn't be stored.
+//
+// The subnets are walked like [Table.CoveringBlocks], all descendants
+// of a yielded child follow in sort order and are skipped.
+func (t *Table[V]) ChildrenOf(pfx netip.Prefix) func(yield func(netip.Prefix, V) bool) {
+	return func(yield func(netip.Prefix, V) bool) {
+		if !pfx.IsValid() {
+			return
+		}
+		pfx = pfx.Masked()
+
+		// the last yielded child, all its subnets follow in sort order
+		var last netip.Prefix
+
+		t.Subnets(pfx)(func(sub netip.Prefix, val V) bool {
+			if sub == pfx {
+				return true
+			}
+
+			if last.IsValid() && last.Bits() <= sub.Bits() && last.Contains(sub.Addr()) {
+				return true
+			}
+
+			last = sub
+			return yield(sub, val)
+		})
+	}
+}
+
+// AllWithDepth calls yield for all prefixes and values, like [Table.All],
+// together with the trie depth of the node where the entry is stored,
+// e.g. to analyze the effectiveness of the path compression.
+//
+// A prefix stored in the node's stride has a length in [depth*8, depth*8+7],
+// a path-compressed leaf or fringe is stored in a child slot of the node
+// and is at least (depth+1)*8 long.
+// The walk stops early if yield returns false.
+func (t *Table[V]) AllWithDepth(yield func(pfx netip.Prefix, val V, depth int) bool) {
+	if t == nil {
+		return
+	}
+
+	_ = t.root4.allRecWithDepth(stridePath{}, 0, true, yield) &&
+		t.root6.allRecWithDepth(stridePath{}, 0, false, yield)
+}
+
+// Leaves returns an iterator over the prefix–value pairs stored as
+// path-compressed leaves or fringes, the prefixes in the node strides
+// are skipped, e.g. to validate the compression on real data.
+//
+// The order is the order of [Table.All].
+func (t *Table[V]) Leaves() func(yield func(netip.Prefix, V) bool) {
+	return func(yield func(netip.Prefix, V) bool) {
+		t.AllWithDepth(func(pfx netip.Prefix, val V, depth int) bool {
+			// a stride prefix is shorter than the child slots
+			if pfx.Bits() < (depth+1)<<3 {
+				return true
+			}
+			return yield(pfx, val)
+		})
+	}
+}
+
+// CountWhere returns the number of prefixes and values where pred
+// returns true, e.g. the routes pointing to a given next-hop.
+//
+// The trie is walked directly, without an iterator and without allocations.
+func (t *Table[V]) CountWhere(pred func(pfx netip.Prefix, val V) bool) int {
+	if t == nil {
+		return 0
+	}
+
+	return t.root4.countWhereRec(stridePath{}, 0, true, pred) +
+		t.root6.countWhereRec(stridePath{}, 0, false, pred)
+}
+
+// WalkPostorder calls yield for all prefixes and values in post-order
+// of the prefix hierarchy: all subnets of a prefix are visited before
+// the prefix itself, e.g. to fold values bottom-up like summing bandwidth.
+//
+// depth is the nesting level of the prefix, the number of its supernets
+// in the table, 0 for a top-level prefix.
+// The walk stops early if yield returns false.
+//
+// The prefixes are walked in CIDR sort order, the pending supernets
+// are kept on a stack, at most one per prefix length.
+func (t *Table[V]) WalkPostorder(yield func(pfx netip.Prefix, val V, depth int) bool) {
+	type item struct {
+		pfx netip.Prefix
+		val V
+	}
+
+	stack := make([]item, 0, 8)
+	stopped := false
+
+	t.AllSorted()(func(pfx netip.Prefix, val V) bool {
+		// pop all finished supernets, pfx isn't covered by them
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.pfx.Bits() <= pfx.Bits() && top.pfx.Contains(pfx.Addr()) {
+				break
+			}
+
+			stack = stack[:len(stack)-1]
+			if !yield(top.pfx, top.val, len(stack)) {
+				stopped = true
+				return false
+			}
+		}
+
+		stack = append(stack, item{pfx, val})
+		return true
+	})
+
+	if stopped {
+		return
+	}
+
+	// the remaining prefixes, most-specific first
+	for i := len(stack) - 1; i >= 0; i-- {
+		if !yield(stack[i].pfx, stack[i].val, i) {
+			return
+		}
+	}
+}
@@ -170,6 +170,41 @@ func TestAllRangeOverFunc(t *testing.T) {
 	})
 }
 
+func TestAllDeterministic(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes(prng, 10_000)
+
+	rtbl := new(Table[int])
+	for _, item := range pfxs {
+		rtbl.Insert(item.pfx, item.val)
+	}
+
+	type entry struct {
+		pfx netip.Prefix
+		val int
+	}
+
+	collect := func(tbl *Table[int]) (entries []entry) {
+		for pfx, val := range tbl.All() {
+			entries = append(entries, entry{pfx, val})
+		}
+		return entries
+	}
+
+	first := collect(rtbl)
+	if len(first) != rtbl.Size() {
+		t.Fatalf("All, want %d entries, got %d", rtbl.Size(), len(first))
+	}
+
+	// back-to-back walks must yield identical sequences
+	for range 3 {
+		if !slices.Equal(first, collect(rtbl)) {
+			t.Fatalf("All, iteration order differs between calls")
+		}
+	}
+}
+
 func TestAll4SortedIter(t *testing.T) {
 	t.Parallel()
 	prng := rand.New(rand.NewSource(42))
@@ -326,6 +361,107 @@ func TestAllSortedRangeOverFunc(t *testing.T) {
 	})
 }
 
+func TestAllSortedDesc(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes(prng, 10_000)
+
+	rtbl := new(Table[int])
+	for _, item := range pfxs {
+		rtbl.Insert(item.pfx, item.val)
+	}
+
+	type entry struct {
+		pfx netip.Prefix
+		val int
+	}
+
+	var want []entry
+	for pfx, val := range rtbl.AllSorted() {
+		want = append(want, entry{pfx, val})
+	}
+	slices.Reverse(want)
+
+	var got []entry
+	for pfx, val := range rtbl.AllSortedDesc() {
+		got = append(got, entry{pfx, val})
+	}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("AllSortedDesc, not the reverse of AllSorted")
+	}
+
+	// premature exit
+	count := 0
+	for range rtbl.AllSortedDesc() {
+		count++
+		if count >= 1000 {
+			break
+		}
+	}
+}
+
+func TestAllFromCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes(prng, 5_000)
+
+	rtbl := new(Table[int])
+	for _, item := range pfxs {
+		rtbl.Insert(item.pfx, item.val)
+	}
+
+	var all []netip.Prefix
+	for pfx := range rtbl.AllSorted() {
+		all = append(all, pfx)
+	}
+
+	starts := []netip.Prefix{mpp("0.0.0.0/0"), mpp("::/0"), mpp("255.255.255.255/32")}
+	for _, item := range randomPrefixes(prng, 200) {
+		starts = append(starts, item.pfx)
+	}
+	for _, item := range pfxs[:200] {
+		starts = append(starts, item.pfx)
+	}
+
+	for _, start := range starts {
+		// first index not less than start
+		i := 0
+		for i < len(all) && lessPrefix(all[i], start) {
+			i++
+		}
+		want := all[i:]
+
+		var got []netip.Prefix
+		for pfx := range rtbl.AllFrom(start) {
+			got = append(got, pfx)
+		}
+
+		if !slices.Equal(got, want) {
+			t.Fatalf("AllFrom(%s), got %d prefixes, want %d", start, len(got), len(want))
+		}
+	}
+
+	// invalid start yields all
+	var got []netip.Prefix
+	for pfx := range rtbl.AllFrom(netip.Prefix{}) {
+		got = append(got, pfx)
+	}
+
+	if !slices.Equal(got, all) {
+		t.Errorf("AllFrom(invalid), not equal to AllSorted")
+	}
+
+	// premature exit
+	count := 0
+	for range rtbl.AllFrom(mpp("10.0.0.0/8")) {
+		count++
+		if count >= 100 {
+			break
+		}
+	}
+}
+
 func TestSupernetsEdgeCase(t *testing.T) {
 	t.Parallel()
 
@@ -404,6 +540,36 @@ func TestSupernetsCompare(t *testing.T) {
 	}
 }
 
+func TestSupernetCountCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 10_000)
+
+	fast := new(Table[int])
+	gold := new(goldTable[int])
+
+	for _, item := range pfxs {
+		fast.Insert(item.pfx, item.val)
+		gold.insert(item.pfx, item.val)
+	}
+
+	// probe with stored and random prefixes
+	probes := randomPrefixes(prng, 10_000)
+	probes = append(probes, pfxs[:1_000]...)
+
+	for _, item := range probes {
+		want := len(gold.supernets(item.pfx))
+		if got := fast.SupernetCount(item.pfx); got != want {
+			t.Fatalf("SupernetCount(%s), want %d, got %d", item.pfx, want, got)
+		}
+	}
+
+	if got := fast.SupernetCount(netip.Prefix{}); got != 0 {
+		t.Errorf("SupernetCount(invalid), want 0, got %d", got)
+	}
+}
+
 func TestSubnets(t *testing.T) {
 	t.Parallel()
 
@@ -569,3 +735,42 @@ func BenchmarkSupernets(b *testing.B) {
 		}
 	})
 }
+
+func TestPrefixesValues(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes(prng, 10_000)
+
+	rtbl := new(Table[int])
+	for _, item := range pfxs {
+		rtbl.Insert(item.pfx, item.val)
+	}
+
+	var wantPfxs []netip.Prefix
+	var wantVals []int
+	for pfx, val := range rtbl.AllSorted() {
+		wantPfxs = append(wantPfxs, pfx)
+		wantVals = append(wantVals, val)
+	}
+
+	if got := slices.Collect(rtbl.Prefixes()); !slices.Equal(got, wantPfxs) {
+		t.Errorf("Prefixes, not equal to AllSorted")
+	}
+
+	if got := slices.Collect(rtbl.Values()); !slices.Equal(got, wantVals) {
+		t.Errorf("Values, not equal to AllSorted")
+	}
+
+	// premature exit
+	count := 0
+	for range rtbl.Prefixes() {
+		count++
+		if count >= 1000 {
+			break
+		}
+	}
+
+	if count > 1000 {
+		t.Fatalf("expected premature stop")
+	}
+}
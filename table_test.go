@@ -10,12 +10,19 @@
 package bart
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"net"
 	"net/netip"
+	"reflect"
 	"runtime"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unsafe"
 )
 
 var mpa = netip.MustParseAddr
@@ -1281,6 +1288,35 @@ func TestContainsCompare(t *testing.T) {
 	}
 }
 
+func TestContains4Compare(t *testing.T) {
+	// the unrolled contains4 must be identical to the generic loop
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes4(prng, 10_000)
+
+	fast := new(Table[int])
+	for _, pfx := range pfxs {
+		fast.Insert(pfx.pfx, pfx.val)
+	}
+
+	for j := 0; j < 10_000; j++ {
+		a := randomIP4(prng)
+
+		if got, want := fast.contains4(a), fast.contains(a); got != want {
+			t.Fatalf("contains4(%q) = %v, want %v", a, got, want)
+		}
+	}
+
+	// probe the stored prefixes itself, hits at all depths
+	for _, pfx := range pfxs {
+		a := pfx.pfx.Addr()
+
+		if got, want := fast.contains4(a), fast.contains(a); got != want {
+			t.Fatalf("contains4(%q) = %v, want %v", a, got, want)
+		}
+	}
+}
+
 func TestLookupCompare(t *testing.T) {
 	// Create large route tables repeatedly, and compare Table's
 	// behavior to a naive and slow but correct implementation.
@@ -1327,6 +1363,394 @@ func TestLookupCompare(t *testing.T) {
 	}
 }
 
+func TestLookupIntoCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes(prng, 10_000)
+
+	fast := new(Table[int])
+	for _, pfx := range pfxs {
+		fast.Insert(pfx.pfx, pfx.val)
+	}
+
+	for j := 0; j < 10_000; j++ {
+		a := randomAddr(prng)
+
+		wantVal, wantOK := fast.Lookup(a)
+
+		// sentinel, must be untouched on miss
+		gotVal := -1
+		gotOK := fast.LookupInto(a, &gotVal)
+
+		if !wantOK {
+			wantVal = -1
+		}
+
+		if gotOK != wantOK || gotVal != wantVal {
+			t.Fatalf("LookupInto(%q) = (%v, %v), want (%v, %v)", a, gotVal, gotOK, wantVal, wantOK)
+		}
+	}
+
+	var val int
+	if fast.LookupInto(netip.Addr{}, &val) {
+		t.Errorf("LookupInto(invalid), want false")
+	}
+}
+
+func TestLookupBytesCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes(prng, 10_000)
+
+	fast := new(Table[int])
+	for _, pfx := range pfxs {
+		fast.Insert(pfx.pfx, pfx.val)
+	}
+
+	for j := 0; j < 10_000; j++ {
+		a := randomAddr(prng)
+
+		wantVal, wantOK := fast.Lookup(a)
+		gotVal, gotOK := fast.LookupBytes(a.AsSlice())
+
+		if gotOK != wantOK || gotVal != wantVal {
+			t.Fatalf("LookupBytes(%q) = (%v, %v), want (%v, %v)", a, gotVal, gotOK, wantVal, wantOK)
+		}
+	}
+
+	for _, ip := range [][]byte{nil, {}, {10, 0, 0}, make([]byte, 5), make([]byte, 17)} {
+		if _, ok := fast.LookupBytes(ip); ok {
+			t.Errorf("LookupBytes(%v), len %d, want false", ip, len(ip))
+		}
+	}
+}
+
+func TestLookup4And16Compare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes(prng, 10_000)
+
+	tbl := new(Table[int])
+	for _, pfx := range pfxs {
+		tbl.Insert(pfx.pfx, pfx.val)
+	}
+
+	for j := 0; j < 10_000; j++ {
+		a := randomAddr(prng)
+
+		wantVal, wantOK := tbl.Lookup(a)
+
+		var gotVal int
+		var gotOK bool
+		if a.Is4() {
+			gotVal, gotOK = tbl.Lookup4(a.As4())
+		} else {
+			gotVal, gotOK = tbl.Lookup16(a.As16())
+		}
+
+		if gotOK != wantOK || gotVal != wantVal {
+			t.Fatalf("Lookup4/16(%s) = (%v, %v), want (%v, %v)", a, gotVal, gotOK, wantVal, wantOK)
+		}
+	}
+
+	// IPv4-mapped, no unmapping
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	if _, ok := tbl.Lookup16(mpa("::ffff:10.0.0.1").As16()); ok != tbl.Contains(mpa("::ffff:10.0.0.1")) {
+		t.Errorf("Lookup16, IPv4-mapped address differs from Lookup")
+	}
+}
+
+func TestLookupIPNet(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 8)
+	tbl.Insert(mpp("10.0.0.0/24"), 24)
+	tbl.Insert(mpp("2001:db8::/32"), 32)
+
+	parse := func(s string) *net.IPNet {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ipNet
+	}
+
+	tests := []struct {
+		name   string
+		ipNet  *net.IPNet
+		lpmPfx netip.Prefix
+		val    int
+		ok     bool
+	}{
+		{"nil", nil, netip.Prefix{}, 0, false},
+		{"v4", parse("10.0.0.0/25"), mpp("10.0.0.0/24"), 24, true},
+		{"v4 short", parse("10.1.0.0/16"), mpp("10.0.0.0/8"), 8, true},
+		{"v4 miss", parse("11.0.0.0/8"), netip.Prefix{}, 0, false},
+		{"v4 in 16 bytes", &net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, mpp("10.0.0.0/24"), 24, true},
+		{"v6", parse("2001:db8::1/128"), mpp("2001:db8::/32"), 32, true},
+		{"non-contiguous mask", &net.IPNet{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.IPv4Mask(255, 0, 255, 0)}, netip.Prefix{}, 0, false},
+		{"invalid ip", &net.IPNet{IP: net.IP{10, 0}, Mask: net.CIDRMask(8, 32)}, netip.Prefix{}, 0, false},
+		{"v6 addr, v4 mask", &net.IPNet{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(8, 32)}, netip.Prefix{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		val, ok := tbl.LookupIPNet(tt.ipNet)
+		if val != tt.val || ok != tt.ok {
+			t.Errorf("%s: LookupIPNet = (%d, %v), want (%d, %v)", tt.name, val, ok, tt.val, tt.ok)
+		}
+
+		lpmPfx, val, ok := tbl.LookupIPNetLPM(tt.ipNet)
+		if lpmPfx != tt.lpmPfx || val != tt.val || ok != tt.ok {
+			t.Errorf("%s: LookupIPNetLPM = (%s, %d, %v), want (%s, %d, %v)", tt.name, lpmPfx, val, ok, tt.lpmPfx, tt.val, tt.ok)
+		}
+	}
+}
+
+func TestLookupFuncCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes(prng, 10_000)
+
+	fast := new(Table[int])
+	gold := new(goldTable[int]).insertMany(pfxs)
+	for _, pfx := range pfxs {
+		fast.Insert(pfx.pfx, pfx.val)
+	}
+
+	// accept only even values
+	accept := func(val int) bool { return val%2 == 0 }
+
+	for j := 0; j < 10_000; j++ {
+		a := randomAddr(prng)
+
+		var wantVal int
+		var wantOK bool
+		bits := -1
+		for _, item := range *gold {
+			if item.pfx.Bits() > bits && item.pfx.Contains(a) && accept(item.val) {
+				bits = item.pfx.Bits()
+				wantVal, wantOK = item.val, true
+			}
+		}
+
+		gotVal, gotOK := fast.LookupFunc(a, accept)
+		if gotOK != wantOK || gotVal != wantVal {
+			t.Fatalf("LookupFunc(%q) = (%v, %v), want (%v, %v)", a, gotVal, gotOK, wantVal, wantOK)
+		}
+
+		// accept all, same as Lookup
+		wantVal, wantOK = fast.Lookup(a)
+		gotVal, gotOK = fast.LookupFunc(a, func(int) bool { return true })
+		if gotOK != wantOK || gotVal != wantVal {
+			t.Fatalf("LookupFunc(%q), accept all = (%v, %v), want (%v, %v)", a, gotVal, gotOK, wantVal, wantOK)
+		}
+	}
+}
+
+func TestLookupDiff(t *testing.T) {
+	t.Parallel()
+
+	a := new(Table[string])
+	a.Insert(mpp("10.0.0.0/16"), "X")
+	a.Insert(mpp("2001:db8::/32"), "V6")
+
+	b := new(Table[string])
+	b.Insert(mpp("10.0.0.0/8"), "Z")
+	b.Insert(mpp("10.0.1.0/24"), "Y")
+
+	tests := []struct {
+		ip         netip.Addr
+		aPfx, bPfx netip.Prefix
+		aVal, bVal string
+		aOK, bOK   bool
+	}{
+		{netip.Addr{}, netip.Prefix{}, netip.Prefix{}, "", "", false, false},
+		{mpa("10.0.1.1"), mpp("10.0.0.0/16"), mpp("10.0.1.0/24"), "X", "Y", true, true},
+		{mpa("10.0.2.1"), mpp("10.0.0.0/16"), mpp("10.0.0.0/8"), "X", "Z", true, true},
+		{mpa("10.1.0.1"), netip.Prefix{}, mpp("10.0.0.0/8"), "", "Z", false, true},
+		{mpa("2001:db8::1"), mpp("2001:db8::/32"), netip.Prefix{}, "V6", "", true, false},
+	}
+
+	for _, tt := range tests {
+		aPfx, bPfx, aVal, bVal, aOK, bOK := a.LookupDiff(b, tt.ip)
+		if aPfx != tt.aPfx || bPfx != tt.bPfx || aVal != tt.aVal || bVal != tt.bVal || aOK != tt.aOK || bOK != tt.bOK {
+			t.Errorf("LookupDiff(%s) = (%s, %s, %q, %q, %v, %v), want (%s, %s, %q, %q, %v, %v)",
+				tt.ip, aPfx, bPfx, aVal, bVal, aOK, bOK, tt.aPfx, tt.bPfx, tt.aVal, tt.bVal, tt.aOK, tt.bOK)
+		}
+	}
+
+	// nil table never matches
+	_, _, _, _, aOK, bOK := a.LookupDiff(nil, mpa("10.0.1.1"))
+	if !aOK || bOK {
+		t.Errorf("LookupDiff(nil), got (%v, %v), want (true, false)", aOK, bOK)
+	}
+}
+
+func TestLookupRange(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 8)
+	tbl.Insert(mpp("10.0.0.0/23"), 23)
+	tbl.Insert(mpp("10.0.0.1/32"), 32)
+	tbl.Insert(mpp("2001:db8::/32"), 128)
+
+	tests := []struct {
+		ip          netip.Addr
+		first, last netip.Addr
+		val         int
+		ok          bool
+	}{
+		{netip.Addr{}, netip.Addr{}, netip.Addr{}, 0, false},
+		{mpa("11.0.0.1"), netip.Addr{}, netip.Addr{}, 0, false},
+		{mpa("10.1.2.3"), mpa("10.0.0.0"), mpa("10.255.255.255"), 8, true},
+		{mpa("10.0.1.3"), mpa("10.0.0.0"), mpa("10.0.1.255"), 23, true},
+		{mpa("10.0.0.1"), mpa("10.0.0.1"), mpa("10.0.0.1"), 32, true},
+		{mpa("2001:db8::1"), mpa("2001:db8::"), mpa("2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"), 128, true},
+	}
+
+	for _, tt := range tests {
+		first, last, val, ok := tbl.LookupRange(tt.ip)
+		if first != tt.first || last != tt.last || val != tt.val || ok != tt.ok {
+			t.Errorf("LookupRange(%s) = (%s, %s, %d, %v), want (%s, %s, %d, %v)",
+				tt.ip, first, last, val, ok, tt.first, tt.last, tt.val, tt.ok)
+		}
+	}
+}
+
+func TestLookupAllLPM(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 8)
+	tbl.Insert(mpp("10.0.0.0/25"), 25)
+	tbl.Insert(mpp("10.0.0.0/26"), 26)
+	tbl.Insert(mpp("10.0.0.0/28"), 28)
+	tbl.Insert(mpp("2001:db8::1/128"), 128)
+
+	tests := []struct {
+		ip   netip.Addr
+		bits int
+		vals []int
+		ok   bool
+	}{
+		{ip: mpa("10.0.0.1"), bits: 28, vals: []int{28, 26, 25}, ok: true},
+		{ip: mpa("10.0.0.100"), bits: 25, vals: []int{25}, ok: true},
+		{ip: mpa("10.0.0.200"), bits: 8, vals: []int{8}, ok: true},
+		{ip: mpa("2001:db8::1"), bits: 128, vals: []int{128}, ok: true},
+		{ip: mpa("11.0.0.1"), bits: 0, vals: nil, ok: false},
+		{ip: netip.Addr{}, bits: 0, vals: nil, ok: false},
+	}
+
+	for _, tt := range tests {
+		bits, vals, ok := tbl.LookupAllLPM(tt.ip)
+		if bits != tt.bits || ok != tt.ok || fmt.Sprint(vals) != fmt.Sprint(tt.vals) {
+			t.Errorf("LookupAllLPM(%s) = (%d, %v, %v), want (%d, %v, %v)",
+				tt.ip, bits, vals, ok, tt.bits, tt.vals, tt.ok)
+		}
+	}
+}
+
+func TestLookupAllLPMCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes(prng, 10_000)
+
+	fast := new(Table[int])
+	for _, pfx := range pfxs {
+		fast.Insert(pfx.pfx, pfx.val)
+	}
+
+	for j := 0; j < 10_000; j++ {
+		a := randomAddr(prng)
+
+		lpm, wantVal, wantOK := fast.LookupPrefixLPM(netip.PrefixFrom(a, a.BitLen()))
+		bits, vals, ok := fast.LookupAllLPM(a)
+
+		if ok != wantOK {
+			t.Fatalf("LookupAllLPM(%s), ok: %v, want: %v", a, ok, wantOK)
+		}
+
+		if !ok {
+			continue
+		}
+
+		if bits != lpm.Bits() || vals[0] != wantVal {
+			t.Fatalf("LookupAllLPM(%s) = (%d, %v), want (%d, %v)", a, bits, vals[0], lpm.Bits(), wantVal)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	for i, s := range []string{"0.0.0.0/0", "10.0.0.0/8", "10.0.0.0/9", "10.0.0.0/12", "10.0.0.0/16", "10.0.4.0/22", "10.0.0.0/24"} {
+		tbl.Insert(mpp(s), i)
+	}
+
+	result, candidates, ok := tbl.Explain(mpa("10.0.4.1"))
+	if !ok || result != mpp("10.0.4.0/22") {
+		t.Fatalf("Explain, result: (%s, %v), want: (10.0.4.0/22, true)", result, ok)
+	}
+
+	// the /8 is a fringe in the root node and not considered anymore
+	want := []netip.Prefix{mpp("10.0.4.0/22"), mpp("10.0.0.0/16")}
+	if !reflect.DeepEqual(candidates, want) {
+		t.Errorf("Explain, candidates: %v, want: %v", candidates, want)
+	}
+
+	result, candidates, ok = tbl.Explain(mpa("10.1.0.1"))
+	want = []netip.Prefix{mpp("10.0.0.0/12"), mpp("10.0.0.0/9"), mpp("10.0.0.0/8")}
+	if !ok || result != want[0] || !reflect.DeepEqual(candidates, want) {
+		t.Errorf("Explain, got: (%s, %v, %v), want: (%s, %v, true)", result, candidates, ok, want[0], want)
+	}
+
+	if _, _, ok = tbl.Explain(mpa("2001:db8::1")); ok {
+		t.Errorf("Explain, ok: true, want: false")
+	}
+}
+
+func TestExplainCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes(prng, 10_000)
+
+	fast := new(Table[int])
+	for _, pfx := range pfxs {
+		fast.Insert(pfx.pfx, pfx.val)
+	}
+
+	for j := 0; j < 10_000; j++ {
+		a := randomAddr(prng)
+
+		lpm, _, wantOK := fast.LookupPrefixLPM(netip.PrefixFrom(a, a.BitLen()))
+		result, candidates, ok := fast.Explain(a)
+
+		if ok != wantOK {
+			t.Fatalf("Explain(%s), ok: %v, want: %v", a, ok, wantOK)
+		}
+
+		if !ok {
+			continue
+		}
+
+		if result != lpm || candidates[0] != lpm {
+			t.Fatalf("Explain(%s) = (%s, %v), want %s", a, result, candidates, lpm)
+		}
+
+		for i, pfx := range candidates {
+			if !pfx.Contains(a) {
+				t.Fatalf("Explain(%s), candidate %s doesn't contain addr", a, pfx)
+			}
+			if i > 0 && pfx.Bits() >= candidates[i-1].Bits() {
+				t.Fatalf("Explain(%s), candidates not in reverse CIDR order: %v", a, candidates)
+			}
+		}
+	}
+}
+
 func TestLookupPrefixUnmasked(t *testing.T) {
 	// test that the pfx must not be masked on input for LookupPrefix
 	t.Parallel()
@@ -1479,6 +1903,40 @@ func TestLookupPrefixLPMCompare(t *testing.T) {
 	}
 }
 
+func TestLookupPrefixLPMHostCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes(prng, 10_000)
+
+	fast := new(Table[int])
+	gold := new(goldTable[int]).insertMany(pfxs)
+
+	for _, pfx := range pfxs {
+		fast.Insert(pfx.pfx, pfx.val)
+	}
+
+	for j := 0; j < 10_000; j++ {
+		ip := randomAddr(prng)
+		pfx := netip.PrefixFrom(ip, ip.BitLen())
+
+		goldLPM, goldVal, goldOK := gold.lookupPfxLPM(pfx)
+		fastLPM, fastVal, fastOK := fast.LookupPrefixLPM(pfx)
+
+		if !getsEqual(goldVal, goldOK, fastVal, fastOK) {
+			t.Fatalf("LookupPrefixLPM(%q) = (%v, %v), want (%v, %v)", pfx, fastVal, fastOK, goldVal, goldOK)
+		}
+
+		if !getsEqual(goldLPM, goldOK, fastLPM, fastOK) {
+			t.Fatalf("LookupPrefixLPM(%q) = (%v, %v), want (%v, %v)", pfx, fastLPM, fastOK, goldLPM, goldOK)
+		}
+
+		lookupVal, lookupOK := fast.LookupPrefix(pfx)
+		if !getsEqual(goldVal, goldOK, lookupVal, lookupOK) {
+			t.Fatalf("LookupPrefix(%q) = (%v, %v), want (%v, %v)", pfx, lookupVal, lookupOK, goldVal, goldOK)
+		}
+	}
+}
+
 func TestInsertShuffled(t *testing.T) {
 	// The order in which you insert prefixes into a route table
 	// should not matter, as long as you're inserting the same set of
@@ -1518,8 +1976,270 @@ func TestInsertShuffled(t *testing.T) {
 	}
 }
 
-func TestInsertPersistShuffled(t *testing.T) {
-	// The order in which you insert prefixes into a route table
+func TestInsertOrderConsistency(t *testing.T) {
+	// The final trie, structure and values, must be identical
+	// regardless of the insert order, also when leaves and fringes
+	// are pushed down by colliding prefixes at the same octet.
+	t.Parallel()
+
+	sets := [][]netip.Prefix{
+		{mpp("10.0.0.0/8"), mpp("10.0.0.0/32")},
+		{mpp("10.0.0.0/8"), mpp("10.0.0.0/16"), mpp("10.0.0.0/32")},
+		{mpp("10.0.0.0/8"), mpp("10.0.0.0/9"), mpp("10.0.0.0/24"), mpp("10.0.0.1/32")},
+		{mpp("10.0.0.0/16"), mpp("10.0.0.0/17"), mpp("10.0.1.0/24"), mpp("10.0.0.0/32")},
+		{mpp("0.0.0.0/0"), mpp("0.0.0.0/8"), mpp("0.0.0.0/32")},
+		{mpp("2001:db8::/32"), mpp("2001:db8::/128"), mpp("2001:db8::/64"), mpp("2001:db8::1/128")},
+	}
+
+	for _, pfxs := range sets {
+		var want string
+
+		// all permutations
+		permute(pfxs, 0, func(perm []netip.Prefix) {
+			tbl := new(Table[string])
+			for _, pfx := range perm {
+				tbl.Insert(pfx, pfx.String())
+			}
+
+			// overwrite in the same order, values must not get lost
+			for _, pfx := range perm {
+				tbl.Insert(pfx, pfx.String())
+			}
+
+			if tbl.Size() != len(perm) {
+				t.Fatalf("insert order %v, Size: %d, want %d", perm, tbl.Size(), len(perm))
+			}
+
+			for _, pfx := range perm {
+				if val, ok := tbl.Get(pfx); !ok || val != pfx.String() {
+					t.Fatalf("insert order %v, Get(%s) = (%q, %v)", perm, pfx, val, ok)
+				}
+			}
+
+			got := tbl.dumpString()
+			if want == "" {
+				want = got
+			}
+
+			if got != want {
+				t.Fatalf("insert order %v, trie differs:\n%s\nwant:\n%s", perm, got, want)
+			}
+
+			// Update and InsertPersist push down leaves and fringes on their own
+			upd := new(Table[string])
+			pt := new(Table[string])
+			for _, pfx := range perm {
+				upd.Update(pfx, func(string, bool) string { return pfx.String() })
+				pt = pt.InsertPersist(pfx, pfx.String())
+			}
+
+			if got := upd.dumpString(); got != want {
+				t.Fatalf("update order %v, trie differs:\n%s\nwant:\n%s", perm, got, want)
+			}
+			if got := pt.dumpString(); got != want {
+				t.Fatalf("persist insert order %v, trie differs:\n%s\nwant:\n%s", perm, got, want)
+			}
+		})
+	}
+
+	// random sets, shuffled
+	prng := rand.New(rand.NewSource(42))
+	pfxs := randomPrefixes(prng, 1_000)
+
+	rt1 := new(Table[int])
+	for _, pfx := range pfxs {
+		rt1.Insert(pfx.pfx, pfx.val)
+	}
+
+	for j := 0; j < 10; j++ {
+		prng.Shuffle(len(pfxs), func(i, j int) { pfxs[i], pfxs[j] = pfxs[j], pfxs[i] })
+
+		rt2 := new(Table[int])
+		for _, pfx := range pfxs {
+			rt2.Insert(pfx.pfx, pfx.val)
+		}
+
+		if rt1.dumpString() != rt2.dumpString() {
+			t.Fatalf("shuffled insert order, trie differs")
+		}
+	}
+}
+
+// permute calls fn for all permutations of pfxs[k:], in place.
+func permute(pfxs []netip.Prefix, k int, fn func([]netip.Prefix)) {
+	if k == len(pfxs) {
+		fn(pfxs)
+		return
+	}
+
+	for i := k; i < len(pfxs); i++ {
+		pfxs[k], pfxs[i] = pfxs[i], pfxs[k]
+		permute(pfxs, k+1, fn)
+		pfxs[k], pfxs[i] = pfxs[i], pfxs[k]
+	}
+}
+
+func TestInsertAddr(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	rt1 := new(Table[int])
+	rt2 := new(Table[int])
+
+	// invalid addr is a noop
+	rt1.InsertAddr(netip.Addr{}, 0)
+	if rt1.Size() != 0 {
+		t.Fatalf("InsertAddr(invalid), Size() = %d, want 0", rt1.Size())
+	}
+
+	for i := 0; i < 10_000; i++ {
+		ip := randomAddr(prng)
+		val := prng.Int()
+
+		rt1.InsertAddr(ip, val)
+		rt2.Insert(netip.PrefixFrom(ip, ip.BitLen()), val)
+	}
+
+	if rt1.Size4() != rt2.Size4() || rt1.Size6() != rt2.Size6() {
+		t.Fatalf("InsertAddr, sizes (%d, %d), want (%d, %d)", rt1.Size4(), rt1.Size6(), rt2.Size4(), rt2.Size6())
+	}
+
+	rt2.All()(func(pfx netip.Prefix, want int) bool {
+		got, ok := rt1.Lookup(pfx.Addr())
+		if !ok || got != want {
+			t.Errorf("Lookup(%s) = (%v, %v), want (%v, true)", pfx.Addr(), got, ok, want)
+		}
+		return true
+	})
+}
+
+func TestInsertAddrMappedZoned(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		ip   netip.Addr
+		want netip.Prefix
+	}{
+		{mpa("::ffff:1.2.3.4"), mpp("::ffff:1.2.3.4/128")},
+		{mpa("fe80::1%eth0"), mpp("fe80::1/128")},
+		{mpa("1.2.3.4"), mpp("1.2.3.4/32")},
+	}
+
+	rt := NewTableMissFilter[int]()
+	lt := new(Lite)
+
+	var changes []netip.Prefix
+	rt.OnChange(func(_ ChangeOp, pfx netip.Prefix, _ int) {
+		changes = append(changes, pfx)
+	})
+
+	for i, tt := range tests {
+		rt.InsertAddr(tt.ip, i)
+		lt.InsertAddr(tt.ip)
+
+		if got, ok := rt.Get(tt.want); !ok || got != i {
+			t.Errorf("InsertAddr(%s), Get(%s) = (%v, %v), want (%v, true)", tt.ip, tt.want, got, ok, i)
+		}
+		if !lt.Exists(tt.want) {
+			t.Errorf("Lite.InsertAddr(%s), Exists(%s) = false, want true", tt.ip, tt.want)
+		}
+		if ip := tt.want.Addr(); !rt.Contains(ip) || !lt.Contains(ip) {
+			t.Errorf("InsertAddr(%s), Contains(%s) = false, want true", tt.ip, ip)
+		}
+		if got := changes[len(changes)-1]; got != tt.want {
+			t.Errorf("InsertAddr(%s), OnChange prefix = %s, want %s", tt.ip, got, tt.want)
+		}
+	}
+
+	// the mapped address is an IPv6 host route, not unmapped
+	if rt.Size4() != 1 || rt.Size6() != 2 || lt.Size4() != 1 || lt.Size6() != 2 {
+		t.Errorf("InsertAddr, sizes (%d, %d), want (1, 2)", rt.Size4(), rt.Size6())
+	}
+
+	// same host route with zone, just an update
+	rt.InsertAddr(mpa("fe80::1%eth1"), 42)
+	if got, _ := rt.Get(mpp("fe80::1/128")); got != 42 || rt.Size6() != 2 {
+		t.Errorf("InsertAddr(fe80::1%%eth1), Get = %v, Size6 = %d, want 42, 2", got, rt.Size6())
+	}
+}
+
+func TestInsertMapped(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pfx  netip.Prefix
+		asV4 bool
+		want netip.Prefix
+	}{
+		{mpp("::ffff:10.0.0.0/104"), true, mpp("10.0.0.0/8")},
+		{mpp("::ffff:10.0.0.0/104"), false, mpp("::ffff:10.0.0.0/104")},
+		{mpp("::ffff:1.2.3.4/128"), true, mpp("1.2.3.4/32")},
+		{mpp("::/80"), true, mpp("::/80")},
+		{mpp("10.0.0.0/8"), false, mpp("::ffff:10.0.0.0/104")},
+		{mpp("10.0.0.0/8"), true, mpp("10.0.0.0/8")},
+		{mpp("0.0.0.0/0"), false, mpp("::ffff:0.0.0.0/96")},
+		{mpp("2001:db8::/32"), true, mpp("2001:db8::/32")},
+	}
+
+	for _, tt := range tests {
+		rt := new(Table[int])
+		rt.InsertMapped(tt.pfx, 1, tt.asV4)
+
+		if rt.Size() != 1 {
+			t.Fatalf("InsertMapped(%s, %v), Size, want 1, got %d", tt.pfx, tt.asV4, rt.Size())
+		}
+
+		if _, ok := rt.Get(tt.want); !ok {
+			t.Errorf("InsertMapped(%s, %v), want %s, got:\n%s", tt.pfx, tt.asV4, tt.want, rt.String())
+		}
+	}
+
+	// lookups with the native representation
+	rt := new(Table[int])
+	rt.InsertMapped(mpp("::ffff:10.0.0.0/104"), 1, true)
+	if _, ok := rt.Lookup(mpa("10.1.2.3")); !ok {
+		t.Errorf("InsertMapped asV4, Lookup(10.1.2.3), want true")
+	}
+}
+
+func TestInsertCols(t *testing.T) {
+	t.Parallel()
+
+	pfxs := []netip.Prefix{mpp("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.1/16"), mpp("2001:db8::/32")}
+	vals := []int{1, 2, 3}
+
+	rt := new(Table[int])
+	if err := rt.InsertCols(pfxs, vals); err != nil {
+		t.Fatalf("InsertCols, unexpected error: %v", err)
+	}
+
+	if rt.Size() != 3 {
+		t.Errorf("InsertCols, Size, want 3, got %d", rt.Size())
+	}
+
+	// masked
+	if val, ok := rt.Get(mpp("192.168.0.0/16")); !ok || val != 2 {
+		t.Errorf("InsertCols, Get(192.168.0.0/16), want (2, true), got (%d, %v)", val, ok)
+	}
+
+	// length mismatch
+	if err := rt.InsertCols(pfxs, vals[:2]); err == nil {
+		t.Errorf("InsertCols, length mismatch, expected error")
+	}
+
+	// invalid prefix, table unmodified
+	bad := []netip.Prefix{mpp("172.16.0.0/12"), {}}
+	if err := rt.InsertCols(bad, []int{4, 5}); err == nil || !strings.Contains(err.Error(), "index 1") {
+		t.Errorf("InsertCols, invalid prefix, expected error with index 1, got %v", err)
+	}
+
+	if rt.Size() != 3 {
+		t.Errorf("InsertCols, invalid prefix, table modified, Size: %d", rt.Size())
+	}
+}
+
+func TestInsertPersistShuffled(t *testing.T) {
+	// The order in which you insert prefixes into a route table
 	// should not matter, as long as you're inserting the same set of
 	// routes.
 	t.Parallel()
@@ -1780,6 +2500,202 @@ func TestDeleteButOne(t *testing.T) {
 	}
 }
 
+// deleteRandomRec, delete items randomly without purge and compress,
+// returns the number of deleted prefixes.
+func (n *node[V]) deleteRandomRec(prng *rand.Rand) (deleted int) {
+	for _, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
+		if prng.Intn(4) != 0 {
+			n.prefixes.DeleteAt(idx)
+			deleted++
+		}
+	}
+
+	for _, addr := range n.children.AsSlice(&[256]uint8{}) {
+		if kid, ok := n.children.MustGet(addr).(*node[V]); ok {
+			deleted += kid.deleteRandomRec(prng)
+			continue
+		}
+		if prng.Intn(4) != 0 {
+			n.children.DeleteAt(addr)
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+// descendantsRec, count all descendant nodes, even empty ones.
+func (n *node[V]) descendantsRec() (count int) {
+	for _, kidAny := range n.children.Items {
+		if kid, ok := kidAny.(*node[V]); ok {
+			count += 1 + kid.descendantsRec()
+		}
+	}
+	return count
+}
+
+func TestRecompress(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	for j := 0; j < 100; j++ {
+		tbl := new(Table[int])
+		for _, p := range randomPrefixes(prng, 1_000) {
+			tbl.Insert(p.pfx, p.val)
+		}
+
+		if got := tbl.Recompress(); got != 0 {
+			t.Fatalf("Recompress on compressed table, reclaimed: %d, want: 0", got)
+		}
+
+		// bulk delete without compression
+		tbl.sizeUpdate(true, -tbl.root4.deleteRandomRec(prng))
+		tbl.sizeUpdate(false, -tbl.root6.deleteRandomRec(prng))
+
+		before := tbl.root4.descendantsRec() + tbl.root6.descendantsRec()
+
+		// build the wanted table from scratch
+		want := new(Table[int])
+		tbl.All()(func(pfx netip.Prefix, val int) bool {
+			want.Insert(pfx, val)
+			return true
+		})
+
+		reclaimed := tbl.Recompress()
+
+		after := tbl.root4.descendantsRec() + tbl.root6.descendantsRec()
+		if before-after != reclaimed {
+			t.Errorf("Recompress, reclaimed: %d, want: %d", reclaimed, before-after)
+		}
+
+		if got := tbl.dumpString(); got != want.dumpString() {
+			t.Fatalf("Recompress, mismatch:\n\n got: %s\n\nwant: %s", got, want.dumpString())
+		}
+	}
+}
+
+func TestDeleteReleasesValue(t *testing.T) {
+	t.Parallel()
+
+	type payload struct {
+		buf [64]byte
+	}
+
+	tests := []struct {
+		name   string
+		pfx    netip.Prefix
+		others []netip.Prefix
+	}{
+		{
+			name: "prefix in root node",
+			pfx:  mpp("0.0.0.0/7"),
+			others: []netip.Prefix{
+				mpp("2.0.0.0/7"),
+				mpp("4.0.0.0/7"),
+			},
+		},
+		{
+			name: "leaf",
+			pfx:  mpp("10.1.0.0/16"),
+		},
+		{
+			name: "fringe",
+			pfx:  mpp("10.0.0.0/8"),
+		},
+		{
+			name: "prefix in node, purge and compress",
+			pfx:  mpp("10.1.0.0/17"),
+			others: []netip.Prefix{
+				mpp("10.1.128.0/17"),
+			},
+		},
+		{
+			name: "prefix in node, with siblings",
+			pfx:  mpp("2001:db8::/33"),
+			others: []netip.Prefix{
+				mpp("2001:db8:8000::/33"),
+				mpp("2001:db8::/34"),
+				mpp("2001:db8::/64"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		rt := new(Table[*payload])
+		for _, pfx := range tt.others {
+			rt.Insert(pfx, new(payload))
+		}
+
+		released := make(chan struct{})
+		func() {
+			p := new(payload)
+			runtime.SetFinalizer(p, func(*payload) { close(released) })
+			rt.Insert(tt.pfx, p)
+		}()
+
+		rt.Delete(tt.pfx)
+
+		ok := false
+		for i := 0; i < 10 && !ok; i++ {
+			runtime.GC()
+			select {
+			case <-released:
+				ok = true
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+
+		if !ok {
+			t.Errorf("%s: value of %s not released after Delete", tt.name, tt.pfx)
+		}
+
+		runtime.KeepAlive(rt)
+	}
+}
+
+func TestDeleteManyCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 10_000)
+
+	many := new(Table[int])
+	single := new(Table[int])
+	for _, pfx := range pfxs {
+		many.Insert(pfx.pfx, pfx.val)
+		single.Insert(pfx.pfx, pfx.val)
+	}
+
+	// half of the prefixes and some non-existent
+	var toDelete []netip.Prefix
+	for _, pfx := range pfxs[:5_000] {
+		toDelete = append(toDelete, pfx.pfx)
+	}
+	for _, pfx := range randomPrefixes(prng, 1_000) {
+		toDelete = append(toDelete, pfx.pfx)
+	}
+
+	want := 0
+	for _, pfx := range toDelete {
+		if _, ok := single.GetAndDelete(pfx); ok {
+			want++
+		}
+	}
+
+	if got := many.DeleteMany(toDelete); got != want {
+		t.Errorf("DeleteMany, count, want %d, got %d", want, got)
+	}
+
+	if many.Size4() != single.Size4() || many.Size6() != single.Size6() {
+		t.Errorf("DeleteMany, size4/size6, want %d/%d, got %d/%d",
+			single.Size4(), single.Size6(), many.Size4(), many.Size6())
+	}
+
+	if many.dumpString() != single.dumpString() {
+		t.Errorf("DeleteMany, trie differs from single Deletes")
+	}
+}
+
 func TestGetAndDelete(t *testing.T) {
 	t.Parallel()
 	prng := rand.New(rand.NewSource(42))
@@ -1905,6 +2821,63 @@ func TestGetCompare(t *testing.T) {
 	}
 }
 
+func TestGetCanonical(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 8)
+	tbl.Insert(mpp("2001:db8::/32"), 32)
+	tbl.Insert(mpp("::ffff:10.0.0.0/104"), 104)
+
+	tests := []struct {
+		pfx       netip.Prefix
+		canonical netip.Prefix
+		val       int
+		ok        bool
+	}{
+		{netip.Prefix{}, netip.Prefix{}, 0, false},
+		{mpp("10.0.0.0/8"), mpp("10.0.0.0/8"), 8, true},
+		{netip.MustParsePrefix("10.1.2.3/8"), mpp("10.0.0.0/8"), 8, true},
+		{netip.MustParsePrefix("10.1.2.3/16"), netip.Prefix{}, 0, false},
+		{netip.MustParsePrefix("2001:db8::1/32"), mpp("2001:db8::/32"), 32, true},
+		{mpp("::ffff:10.0.0.0/104"), mpp("::ffff:10.0.0.0/104"), 104, true},
+		{netip.MustParsePrefix("::ffff:10.1.2.3/104"), mpp("::ffff:10.0.0.0/104"), 104, true},
+	}
+
+	for _, tt := range tests {
+		canonical, val, ok := tbl.GetCanonical(tt.pfx)
+		if canonical != tt.canonical || val != tt.val || ok != tt.ok {
+			t.Errorf("GetCanonical(%s) = (%s, %d, %v), want (%s, %d, %v)",
+				tt.pfx, canonical, val, ok, tt.canonical, tt.val, tt.ok)
+		}
+	}
+}
+
+func TestHasCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 10_000)
+	fast := new(Table[int])
+
+	for _, pfx := range pfxs {
+		fast.Insert(pfx.pfx, pfx.val)
+	}
+
+	for _, pfx := range append(pfxs, randomPrefixes(prng, 10_000)...) {
+		_, getOK := fast.Get(pfx.pfx)
+		hasOK := fast.Has(pfx.pfx)
+
+		if getOK != hasOK {
+			t.Fatalf("Has(%q) = %v, want %v", pfx.pfx, hasOK, getOK)
+		}
+	}
+
+	if fast.Has(netip.Prefix{}) {
+		t.Errorf("Has(invalid), want false")
+	}
+}
+
 func TestUpdateCompare(t *testing.T) {
 	t.Parallel()
 
@@ -2059,19 +3032,70 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
-func TestUnionEdgeCases(t *testing.T) {
+func TestUpdateWhere(t *testing.T) {
 	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
 
-	t.Run("empty", func(t *testing.T) {
-		t.Parallel()
-		aTbl := new(Table[int])
-		bTbl := new(Table[int])
+	pfxs := randomPrefixes(prng, 10_000)
+	tbl := new(Table[int])
+	gold := new(Table[int])
 
-		// union empty tables
-		aTbl.Union(bTbl)
+	for _, pfx := range pfxs {
+		tbl.Insert(pfx.pfx, pfx.val%3)
+		gold.Insert(pfx.pfx, pfx.val%3)
+	}
 
-		want := ""
-		got := aTbl.String()
+	// rewrite all values 1 to 42
+	match := func(_ netip.Prefix, v int) bool { return v == 1 }
+	newVal := func(int) int { return 42 }
+
+	var matched []netip.Prefix
+	gold.All()(func(pfx netip.Prefix, val int) bool {
+		if val == 1 {
+			matched = append(matched, pfx)
+		}
+		return true
+	})
+
+	want := len(matched)
+	for _, pfx := range matched {
+		gold.Insert(pfx, 42)
+	}
+
+	if got := tbl.UpdateWhere(match, newVal); got != want {
+		t.Errorf("UpdateWhere, count: want: %d, got: %d", want, got)
+	}
+
+	if tbl.Size() != gold.Size() {
+		t.Errorf("UpdateWhere, size: want: %d, got: %d", gold.Size(), tbl.Size())
+	}
+
+	gold.All()(func(pfx netip.Prefix, goldVal int) bool {
+		if val, _ := tbl.Get(pfx); val != goldVal {
+			t.Fatalf("UpdateWhere, Get(%s): want: %d, got: %d", pfx, goldVal, val)
+		}
+		return true
+	})
+
+	// nothing left to match
+	if got := tbl.UpdateWhere(match, newVal); got != 0 {
+		t.Errorf("UpdateWhere, second run: want: 0, got: %d", got)
+	}
+}
+
+func TestUnionEdgeCases(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+		aTbl := new(Table[int])
+		bTbl := new(Table[int])
+
+		// union empty tables
+		aTbl.Union(bTbl)
+
+		want := ""
+		got := aTbl.String()
 		if got != want {
 			t.Fatalf("got:\n%v\nwant:\n%v", got, want)
 		}
@@ -2385,8 +3409,19 @@ func TestUnionCompare(t *testing.T) {
 			fast2.Insert(pfx.pfx, pfx.val)
 		}
 
+		wantDups := 0
+		for _, pfx := range pfxs2 {
+			if _, ok := gold.get(pfx.pfx); ok {
+				wantDups++
+			}
+		}
+
 		gold.union(gold2)
-		fast.Union(fast2)
+		gotDups := fast.Union(fast2)
+
+		if gotDups != wantDups {
+			t.Errorf("Union(...): duplicates, got: %d, want: %d", gotDups, wantDups)
+		}
 
 		// dump as slow table for comparison
 		fastAsGoldenTbl := fast.dumpAsGoldTable()
@@ -2410,6 +3445,332 @@ func TestUnionCompare(t *testing.T) {
 	}
 }
 
+func TestAbsorbCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	const numEntries = 200
+
+	for j := 0; j < 100; j++ {
+		pfxs := randomPrefixes(prng, numEntries)
+		pfxs2 := randomPrefixes(prng, numEntries)
+
+		want := new(Table[int])
+		got := new(Table[int])
+		other := new(Table[int])
+
+		for _, pfx := range pfxs {
+			want.Insert(pfx.pfx, pfx.val)
+			got.Insert(pfx.pfx, pfx.val)
+		}
+		for _, pfx := range pfxs2 {
+			other.Insert(pfx.pfx, pfx.val)
+		}
+
+		wantDups := want.Union(other)
+		gotDups := got.Absorb(other)
+
+		if gotDups != wantDups {
+			t.Errorf("Absorb(...): duplicates, got: %d, want: %d", gotDups, wantDups)
+		}
+
+		eq := func(a, b int) bool { return a == b }
+		if got.Size() != want.Size() || !got.IsSubsetOf(want, eq) {
+			t.Fatalf("Absorb(...): differs from Union\ngot:\n%s\nwant:\n%s", got.String(), want.String())
+		}
+
+		if err := got.Validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		// the other table is consumed, but usable
+		if other.Size() != 0 {
+			t.Errorf("Absorb(...): other table not empty, size: %d", other.Size())
+		}
+		if err := other.Validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		other.Insert(mpp("10.0.0.0/8"), 1)
+		if _, ok := other.Get(mpp("10.0.0.0/8")); !ok || other.Size() != 1 {
+			t.Errorf("Absorb(...): other table not reusable")
+		}
+	}
+}
+
+func TestAbsorbShared(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+
+	other := new(Table[int])
+	other.Insert(mpp("10.0.0.0/8"), 2)
+	other.Insert(mpp("192.168.0.0/16"), 3)
+
+	// lazy clone, the nodes of other are shared with snap
+	snap := other.Clone()
+
+	if dups := tbl.Absorb(other); dups != 1 {
+		t.Errorf("Absorb(...): duplicates, got: %d, want: 1", dups)
+	}
+
+	if tbl.Size() != 2 || other.Size() != 0 {
+		t.Errorf("Absorb(...): sizes got: (%d, %d), want: (2, 0)", tbl.Size(), other.Size())
+	}
+
+	if snap.Size() != 2 {
+		t.Errorf("Absorb(...): shared clone modified, size: %d, want: 2", snap.Size())
+	}
+}
+
+func TestUnionDuplicates(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 1_000)
+
+	tbl1 := new(Table[int])
+	for _, item := range pfxs[:750] {
+		tbl1.Insert(item.pfx, item.val)
+	}
+
+	tbl2 := new(Table[int])
+	for _, item := range pfxs[250:] {
+		tbl2.Insert(item.pfx, item.val)
+	}
+
+	if got := tbl1.Union(tbl2); got != 500 {
+		t.Errorf("Union, duplicates: %d, want: %d", got, 500)
+	}
+
+	// union with itself, all prefixes are duplicates
+	if got := tbl1.Union(tbl1.Clone()); got != len(pfxs) {
+		t.Errorf("Union, duplicates: %d, want: %d", got, len(pfxs))
+	}
+}
+
+func TestUnionFunc(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 1_000)
+
+	// overlapping tables, 500 duplicates
+	tbl1 := new(Table[int])
+	for _, item := range pfxs[:750] {
+		tbl1.Insert(item.pfx, 1)
+	}
+
+	tbl2 := new(Table[int])
+	for _, item := range pfxs[250:] {
+		tbl2.Insert(item.pfx, 2)
+	}
+
+	// the duplicate prefixes, merge must be called with them
+	dups := make(map[netip.Prefix]bool)
+	for _, item := range pfxs[250:750] {
+		dups[item.pfx] = true
+	}
+
+	var calls int
+	duplicates := tbl1.UnionFunc(tbl2, func(pfx netip.Prefix, a, b int) int {
+		calls++
+		if a != 1 || b != 2 {
+			t.Errorf("UnionFunc, merge(%s, %d, %d), want (1, 2)", pfx, a, b)
+		}
+		if !dups[pfx] {
+			t.Errorf("UnionFunc, merge(%s), no duplicate prefix", pfx)
+		}
+		delete(dups, pfx)
+		return a + b
+	})
+
+	if calls != 500 || duplicates != 500 {
+		t.Errorf("UnionFunc, merge called %d times, duplicates %d, want: %d", calls, duplicates, 500)
+	}
+
+	if tbl1.Size() != len(pfxs) {
+		t.Errorf("UnionFunc, Size() = %d, want: %d", tbl1.Size(), len(pfxs))
+	}
+
+	for i, item := range pfxs {
+		want := 1
+		switch {
+		case i >= 250 && i < 750:
+			want = 3
+		case i >= 750:
+			want = 2
+		}
+
+		if got, ok := tbl1.Get(item.pfx); !ok || got != want {
+			t.Fatalf("UnionFunc, Get(%s) = (%d, %v), want (%d, true)", item.pfx, got, ok, want)
+		}
+	}
+}
+
+func TestMergeStrict(t *testing.T) {
+	t.Parallel()
+
+	eq := func(a, b int) bool { return a == b }
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Insert(mpp("192.168.0.0/16"), 2)
+	tbl.Insert(mpp("2001:db8::/32"), 3)
+
+	// equal duplicates are no conflict
+	agree := new(Table[int])
+	agree.Insert(mpp("10.0.0.0/8"), 1)
+	agree.Insert(mpp("172.16.0.0/12"), 4)
+
+	conflicts, err := tbl.MergeStrict(agree, eq)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("MergeStrict, unexpected conflicts: %v, %v", conflicts, err)
+	}
+
+	if tbl.Size() != 4 {
+		t.Errorf("MergeStrict, Size, want 4, got %d", tbl.Size())
+	}
+
+	// differing duplicates abort the merge
+	disagree := new(Table[int])
+	disagree.Insert(mpp("2001:db8::/32"), 33)
+	disagree.Insert(mpp("10.0.0.0/8"), 11)
+	disagree.Insert(mpp("192.168.0.0/16"), 2)
+	disagree.Insert(mpp("10.1.0.0/16"), 5)
+
+	conflicts, err = tbl.MergeStrict(disagree, eq)
+	if !errors.Is(err, ErrMergeConflict) {
+		t.Errorf("MergeStrict, want ErrMergeConflict, got %v", err)
+	}
+
+	want := []netip.Prefix{mpp("10.0.0.0/8"), mpp("2001:db8::/32")}
+	if !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("MergeStrict, conflicts, want %v, got %v", want, conflicts)
+	}
+
+	if tbl.Size() != 4 {
+		t.Errorf("MergeStrict, aborted, table modified, Size: %d", tbl.Size())
+	}
+
+	if val, _ := tbl.Get(mpp("10.0.0.0/8")); val != 1 {
+		t.Errorf("MergeStrict, aborted, value overwritten: %d", val)
+	}
+}
+
+func TestMergeLenient(t *testing.T) {
+	t.Parallel()
+
+	eq := func(a, b int) bool { return a == b }
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Insert(mpp("192.168.0.0/16"), 2)
+	tbl.Insert(mpp("2001:db8::/32"), 3)
+
+	// without conflicts it's a Union
+	agree := new(Table[int])
+	agree.Insert(mpp("10.0.0.0/8"), 1)
+	agree.Insert(mpp("172.16.0.0/12"), 4)
+
+	if conflicts := tbl.MergeLenient(agree, eq); len(conflicts) != 0 {
+		t.Fatalf("MergeLenient, unexpected conflicts: %v", conflicts)
+	}
+
+	if tbl.Size() != 4 {
+		t.Errorf("MergeLenient, Size, want 4, got %d", tbl.Size())
+	}
+
+	// differing duplicates are collected, but the merge goes on
+	disagree := new(Table[int])
+	disagree.Insert(mpp("2001:db8::/32"), 33)
+	disagree.Insert(mpp("10.0.0.0/8"), 11)
+	disagree.Insert(mpp("192.168.0.0/16"), 2)
+	disagree.Insert(mpp("10.1.0.0/16"), 5)
+
+	conflicts := tbl.MergeLenient(disagree, eq)
+
+	want := []netip.Prefix{mpp("10.0.0.0/8"), mpp("2001:db8::/32")}
+	if !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("MergeLenient, conflicts, want %v, got %v", want, conflicts)
+	}
+
+	if tbl.Size() != 5 {
+		t.Errorf("MergeLenient, Size, want 5, got %d", tbl.Size())
+	}
+
+	// the conflicts keep the value of the receiver
+	for _, tt := range []struct {
+		pfx netip.Prefix
+		val int
+	}{
+		{mpp("10.0.0.0/8"), 1},
+		{mpp("2001:db8::/32"), 3},
+		{mpp("192.168.0.0/16"), 2},
+		{mpp("10.1.0.0/16"), 5},
+	} {
+		if val, ok := tbl.Get(tt.pfx); !ok || val != tt.val {
+			t.Errorf("MergeLenient, Get(%s) = (%d, %v), want (%d, true)", tt.pfx, val, ok, tt.val)
+		}
+	}
+}
+
+func TestUnionFuncCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	merge := func(pfx netip.Prefix, a, b int) int { return a*31 + b + pfx.Bits() }
+
+	for j := 0; j < 100; j++ {
+		pfxs := randomPrefixes(prng, 500)
+
+		// partially overlapping tables with all kinds of leaf, fringe and node combinations
+		tbl1 := new(Table[int])
+		tbl2 := new(Table[int])
+		for i, item := range pfxs {
+			if i%3 != 0 {
+				tbl1.Insert(item.pfx, item.val)
+			}
+			if i%3 != 1 {
+				tbl2.Insert(item.pfx, item.val+1)
+			}
+		}
+
+		// reference, one Update per prefix
+		want := tbl1.Clone()
+		wantDups := 0
+		tbl2.All()(func(pfx netip.Prefix, b int) bool {
+			want.Update(pfx, func(a int, ok bool) int {
+				if ok {
+					wantDups++
+					return merge(pfx, a, b)
+				}
+				return b
+			})
+			return true
+		})
+
+		got := tbl1.Clone()
+		if dups := got.UnionFunc(tbl2, merge); dups != wantDups {
+			t.Fatalf("UnionFunc, duplicates %d, want %d", dups, wantDups)
+		}
+
+		if !SamePrefixes(got, want) {
+			t.Fatalf("UnionFunc, prefixes differ from the reference")
+		}
+		want.All()(func(pfx netip.Prefix, val int) bool {
+			if gotVal, _ := got.Get(pfx); gotVal != val {
+				t.Fatalf("UnionFunc, Get(%s) = %d, want %d", pfx, gotVal, val)
+			}
+			return true
+		})
+		if err := got.Validate(); err != nil {
+			t.Fatalf("UnionFunc, %v", err)
+		}
+	}
+}
+
 func TestUnionPersistCompare(t *testing.T) {
 	t.Parallel()
 	prng := rand.New(rand.NewSource(42))
@@ -2508,88 +3869,333 @@ func TestClone(t *testing.T) {
 	}
 }
 
-func TestCloneShallow(t *testing.T) {
+func TestPartition(t *testing.T) {
 	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
 
-	tbl := new(Table[*int])
-	clone := tbl.Clone()
-	if tbl.dumpString() != clone.dumpString() {
-		t.Errorf("empty Clone: got:\n%swant:\n%s", clone.String(), tbl.String())
+	pfxs := randomPrefixes(prng, 3_000)
+
+	a := new(Table[int])
+	b := new(Table[int])
+	for _, pfx := range pfxs[:2_000] {
+		a.Insert(pfx.pfx, pfx.val)
+	}
+	for _, pfx := range pfxs[1_000:] {
+		b.Insert(pfx.pfx, -pfx.val)
 	}
 
-	val := 1
-	pfx := mpp("10.0.0.1/32")
-	tbl.Insert(pfx, &val)
+	onlyA, onlyB, both := a.Partition(b)
 
-	clone = tbl.Clone()
-	want, _ := tbl.Get(pfx)
-	got, _ := clone.Get(pfx)
+	if onlyA.Size() != 1_000 || onlyB.Size() != 1_000 || both.Size() != 1_000 {
+		t.Fatalf("Partition, sizes (%d, %d, %d), want (1000, 1000, 1000)", onlyA.Size(), onlyB.Size(), both.Size())
+	}
 
-	if *got != *want || got != want {
-		t.Errorf("shallow copy, values and pointers must be equal:\nvalues(%d, %d)\n(ptr(%v, %v)", *got, *want, got, want)
+	check := func(tbl *Table[int], items []goldTableItem[int], sign int, what string) {
+		t.Helper()
+		for _, item := range items {
+			if val, ok := tbl.Get(item.pfx); !ok || val != sign*item.val {
+				t.Fatalf("Partition, %s, Get(%s) = (%d, %v), want (%d, true)", what, item.pfx, val, ok, sign*item.val)
+			}
+		}
 	}
 
-	// update value, shallow copy of values, clone must be equal
-	val = 2
-	want, _ = tbl.Get(pfx)
-	got, _ = clone.Get(pfx)
+	check(onlyA, pfxs[:1_000], 1, "onlyA")
+	check(both, pfxs[1_000:2_000], 1, "both")
+	check(onlyB, pfxs[2_000:], -1, "onlyB")
 
-	if *got != *want {
-		t.Errorf("memory aliasing after shallow copy, values must be equal:\nvalues(%d, %d)", *got, *want)
+	// sources unmodified
+	if a.Size() != 2_000 || b.Size() != 2_000 {
+		t.Errorf("Partition, sources modified, sizes (%d, %d)", a.Size(), b.Size())
+	}
+
+	// empty tables
+	onlyA, onlyB, both = new(Table[int]).Partition(new(Table[int]))
+	if !onlyA.IsEmpty() || !onlyB.IsEmpty() || !both.IsEmpty() {
+		t.Errorf("Partition of empty tables, want empty tables")
 	}
 }
 
-func TestUpdatePersistDeep(t *testing.T) {
+func TestCloneLazy(t *testing.T) {
 	t.Parallel()
 	prng := rand.New(rand.NewSource(42))
 
-	tbl := new(Table[*MyInt])
-	val1 := MyInt(1)
-	pfx := mpp("10.0.0.1/32")
-	tbl.Insert(pfx, &val1)
+	pfxs := randomPrefixes(prng, 1_000)
+	other := new(Table[int])
+	for _, pfx := range randomPrefixes(prng, 100) {
+		other.Insert(pfx.pfx, pfx.val)
+	}
 
-	val2 := val1
-	immu, _ := tbl.UpdatePersist(pfx, func(*MyInt, bool) *MyInt { return &val2 })
+	// in-place modifications, each must copy the shared trie first
+	mutations := []struct {
+		name string
+		fn   func(tbl *Table[int])
+	}{
+		{"Insert", func(tbl *Table[int]) { tbl.Insert(pfxs[0].pfx, -1) }},
+		{"InsertAddr", func(tbl *Table[int]) { tbl.InsertAddr(pfxs[1].pfx.Addr(), -1) }},
+		{"Update", func(tbl *Table[int]) { tbl.Update(pfxs[2].pfx, func(int, bool) int { return -1 }) }},
+		{"Delete", func(tbl *Table[int]) { tbl.Delete(pfxs[3].pfx) }},
+		{"DeleteMany", func(tbl *Table[int]) { tbl.DeleteMany([]netip.Prefix{pfxs[4].pfx, pfxs[5].pfx}) }},
+		{"Union", func(tbl *Table[int]) { tbl.Union(other) }},
+		{"UpdateWhere", func(tbl *Table[int]) {
+			tbl.UpdateWhere(func(netip.Prefix, int) bool { return true }, func(int) int { return -1 })
+		}},
+		{"Recompress", func(tbl *Table[int]) { tbl.Delete(pfxs[6].pfx); tbl.Recompress() }},
+	}
 
-	want, _ := tbl.Get(pfx)
-	got, _ := immu.Get(pfx)
+	for _, tt := range mutations {
+		tbl := new(Table[int])
+		for _, pfx := range pfxs[100:] {
+			tbl.Insert(pfx.pfx, pfx.val)
+		}
+		for _, pfx := range pfxs[:10] {
+			tbl.Insert(pfx.pfx, pfx.val)
+		}
+		want := tbl.dumpString()
 
-	if *got != *want || got == want {
-		t.Errorf("value with Cloner interface, pointers must be different:\nvalues(%d, %d)\n(ptr(%v, %v)",
-			*got, *want, got, want)
+		// modify the clone, the original must be untouched ...
+		clone := tbl.Clone()
+		tt.fn(clone)
+		if got := tbl.dumpString(); got != want {
+			t.Errorf("%s on clone modified the original", tt.name)
+		}
+
+		// ... and vice versa
+		clone = tbl.Clone()
+		tt.fn(tbl)
+		if got := clone.dumpString(); got != want {
+			t.Errorf("%s on original modified the clone", tt.name)
+		}
+
+		// persistent versions of a shared table must stay shared
+		clone = tbl.Clone()
+		want = tbl.dumpString()
+		pt := clone.InsertPersist(mpp("10.0.0.0/8"), 1)
+		tt.fn(pt)
+		if got := tbl.dumpString(); got != want {
+			t.Errorf("%s on persist version of clone modified the original", tt.name)
+		}
 	}
+}
 
-	// change val1, value after UpdatePersist must now be different
-	val1 = 2
-	want, _ = tbl.Get(pfx)
-	got, _ = immu.Get(pfx)
+func TestCloneShallowCloner(t *testing.T) {
+	t.Parallel()
 
-	if *got == *want {
-		t.Errorf("memory aliasing after UpdatePersist, values must be different:\nvalues(%d, %d)", *got, *want)
+	var nilTbl *Table[*MyInt]
+	if nilTbl.CloneShallow() != nil {
+		t.Errorf("CloneShallow(nil), want nil")
 	}
 
-	pfxs := randomRealWorldPrefixes(prng, 100_000)
-	tbl = new(Table[*MyInt])
+	tbl := new(Table[*MyInt])
+	pfxs := []netip.Prefix{mpp("10.0.0.0/8"), mpp("10.0.0.0/24"), mpp("192.168.1.1/32"), mpp("2001:db8::/32")}
 	for i, pfx := range pfxs {
-		i := MyInt(i)
-		tbl.Insert(pfx, &i)
+		val := MyInt(i)
+		tbl.Insert(pfx, &val)
 	}
 
-	immu = tbl
-	for i, pfx := range pfxs {
-		// increment value by 1, no memory aliasing with tbl values
-		immu, _ = immu.UpdatePersist(pfx, func(oldVal *MyInt, ok bool) *MyInt {
-			if !ok {
-				t.Fatalf("UpdatePersist, expected old value at %d", i)
-			}
-			newVal := *oldVal + 1
-			return &newVal
-		})
+	shallow := tbl.CloneShallow()
+	deep := tbl.Clone()
+
+	if tbl.dumpString() != shallow.dumpString() {
+		t.Errorf("CloneShallow: got:\n%swant:\n%s", shallow.dumpString(), tbl.dumpString())
 	}
 
-	for i, pfx := range pfxs {
-		got1, _ := tbl.Get(pfx)
-		got2, _ := immu.Get(pfx)
+	for _, pfx := range pfxs {
+		orig, _ := tbl.Get(pfx)
+		shared, _ := shallow.Get(pfx)
+		cloned, _ := deep.Get(pfx)
+
+		if orig != shared {
+			t.Errorf("CloneShallow, Get(%s): value isn't shared", pfx)
+		}
+		if orig == cloned {
+			t.Errorf("Clone, Get(%s): value is shared", pfx)
+		}
+	}
+
+	// the trie structure is independent
+	tbl.Delete(pfxs[0])
+	if _, ok := shallow.Get(pfxs[0]); !ok {
+		t.Errorf("CloneShallow, delete in original changed the copy")
+	}
+}
+
+func TestOnlyV4V6(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 10_000)
+
+	golden4 := new(Table[int])
+	golden6 := new(Table[int])
+	tbl := new(Table[int])
+	for _, pfx := range pfxs {
+		if pfx.pfx.Addr().Is4() {
+			golden4.Insert(pfx.pfx, pfx.val)
+		} else {
+			golden6.Insert(pfx.pfx, pfx.val)
+		}
+		tbl.Insert(pfx.pfx, pfx.val)
+	}
+
+	only4 := tbl.OnlyV4()
+	only6 := tbl.OnlyV6()
+
+	// modify the source, the copies must be independent
+	for _, pfx := range pfxs[:1_000] {
+		tbl.Delete(pfx.pfx)
+	}
+
+	if only4.Size4() != golden4.Size4() || only4.Size6() != 0 {
+		t.Errorf("OnlyV4: size4/size6, want: %d/0, got: %d/%d", golden4.Size4(), only4.Size4(), only4.Size6())
+	}
+
+	if only6.Size6() != golden6.Size6() || only6.Size4() != 0 {
+		t.Errorf("OnlyV6: size4/size6, want: 0/%d, got: %d/%d", golden6.Size6(), only6.Size4(), only6.Size6())
+	}
+
+	if golden4.dumpString() != only4.dumpString() {
+		t.Errorf("OnlyV4: got:\n%swant:\n%s", only4.dumpString(), golden4.dumpString())
+	}
+
+	if golden6.dumpString() != only6.dumpString() {
+		t.Errorf("OnlyV6: got:\n%swant:\n%s", only6.dumpString(), golden6.dumpString())
+	}
+}
+
+func TestCoarsen(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Insert(mpp("10.1.0.0/16"), 2)
+	tbl.Insert(mpp("10.1.1.0/24"), 3)
+	tbl.Insert(mpp("10.1.2.0/25"), 4)
+	tbl.Insert(mpp("10.1.2.128/25"), 5)
+	tbl.Insert(mpp("10.2.3.4/32"), 6)
+	tbl.Insert(mpp("2001:db8::/32"), 7)
+	tbl.Insert(mpp("2001:db8:1::/48"), 8)
+	tbl.Insert(mpp("2001:db8:1::1/128"), 9)
+
+	sum := func(covered []int) (s int) {
+		for _, v := range covered {
+			s += v
+		}
+		return s
+	}
+
+	got := tbl.Coarsen(24, 48, sum)
+
+	want := new(Table[int])
+	want.Insert(mpp("10.0.0.0/8"), 1)
+	want.Insert(mpp("10.1.0.0/16"), 2)
+	want.Insert(mpp("10.1.1.0/24"), 3)
+	want.Insert(mpp("10.1.2.0/24"), 4+5)
+	want.Insert(mpp("10.2.3.0/24"), 6)
+	want.Insert(mpp("2001:db8::/32"), 7)
+	want.Insert(mpp("2001:db8:1::/48"), 8+9)
+
+	if got.String() != want.String() {
+		t.Errorf("Coarsen, got:\n%swant:\n%s", got.String(), want.String())
+	}
+
+	if got.Size4() != 5 || got.Size6() != 2 {
+		t.Errorf("Coarsen, Size4/Size6, want 5/2, got %d/%d", got.Size4(), got.Size6())
+	}
+
+	// nil pick takes the first covered value
+	first := tbl.Coarsen(16, 32, nil)
+	if val, ok := first.Get(mpp("10.1.0.0/16")); !ok || val != 2 {
+		t.Errorf("Coarsen, nil pick, want (2, true), got (%d, %v)", val, ok)
+	}
+
+	// source unmodified
+	if tbl.Size() != 9 {
+		t.Errorf("Coarsen, source modified, Size: %d", tbl.Size())
+	}
+}
+
+func TestCloneShallow(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[*int])
+	clone := tbl.Clone()
+	if tbl.dumpString() != clone.dumpString() {
+		t.Errorf("empty Clone: got:\n%swant:\n%s", clone.String(), tbl.String())
+	}
+
+	val := 1
+	pfx := mpp("10.0.0.1/32")
+	tbl.Insert(pfx, &val)
+
+	clone = tbl.Clone()
+	want, _ := tbl.Get(pfx)
+	got, _ := clone.Get(pfx)
+
+	if *got != *want || got != want {
+		t.Errorf("shallow copy, values and pointers must be equal:\nvalues(%d, %d)\n(ptr(%v, %v)", *got, *want, got, want)
+	}
+
+	// update value, shallow copy of values, clone must be equal
+	val = 2
+	want, _ = tbl.Get(pfx)
+	got, _ = clone.Get(pfx)
+
+	if *got != *want {
+		t.Errorf("memory aliasing after shallow copy, values must be equal:\nvalues(%d, %d)", *got, *want)
+	}
+}
+
+func TestUpdatePersistDeep(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[*MyInt])
+	val1 := MyInt(1)
+	pfx := mpp("10.0.0.1/32")
+	tbl.Insert(pfx, &val1)
+
+	val2 := val1
+	immu, _ := tbl.UpdatePersist(pfx, func(*MyInt, bool) *MyInt { return &val2 })
+
+	want, _ := tbl.Get(pfx)
+	got, _ := immu.Get(pfx)
+
+	if *got != *want || got == want {
+		t.Errorf("value with Cloner interface, pointers must be different:\nvalues(%d, %d)\n(ptr(%v, %v)",
+			*got, *want, got, want)
+	}
+
+	// change val1, value after UpdatePersist must now be different
+	val1 = 2
+	want, _ = tbl.Get(pfx)
+	got, _ = immu.Get(pfx)
+
+	if *got == *want {
+		t.Errorf("memory aliasing after UpdatePersist, values must be different:\nvalues(%d, %d)", *got, *want)
+	}
+
+	pfxs := randomRealWorldPrefixes(prng, 100_000)
+	tbl = new(Table[*MyInt])
+	for i, pfx := range pfxs {
+		i := MyInt(i)
+		tbl.Insert(pfx, &i)
+	}
+
+	immu = tbl
+	for i, pfx := range pfxs {
+		// increment value by 1, no memory aliasing with tbl values
+		immu, _ = immu.UpdatePersist(pfx, func(oldVal *MyInt, ok bool) *MyInt {
+			if !ok {
+				t.Fatalf("UpdatePersist, expected old value at %d", i)
+			}
+			newVal := *oldVal + 1
+			return &newVal
+		})
+	}
+
+	for i, pfx := range pfxs {
+		got1, _ := tbl.Get(pfx)
+		got2, _ := immu.Get(pfx)
 
 		if int(*got1) != i {
 			t.Fatalf("UpdatePersist, want: %d, got: %d", i, *got1)
@@ -2805,383 +4411,1216 @@ func TestSize(t *testing.T) {
 	}
 }
 
-func TestLastIdxLastBits(t *testing.T) {
+func TestIsEmpty(t *testing.T) {
 	t.Parallel()
 
-	tests := []struct {
-		pfx       netip.Prefix
-		wantDepth int
-		wantBits  uint8
-	}{
-		{
-			pfx:       mpp("0.0.0.0/0"),
-			wantDepth: 0,
-			wantBits:  0,
-		},
-		{
-			pfx:       mpp("0.0.0.0/32"),
-			wantDepth: 4,
-			wantBits:  0,
-		},
-		{
-			pfx:       mpp("10.0.0.0/7"),
-			wantDepth: 0,
-			wantBits:  7,
-		},
-		{
-			pfx:       mpp("10.20.0.0/14"),
-			wantDepth: 1,
-			wantBits:  6,
-		},
-		{
-			pfx:       mpp("10.20.30.0/24"),
-			wantDepth: 3,
-			wantBits:  0,
-		},
-		{
-			pfx:       mpp("10.20.30.40/31"),
-			wantDepth: 3,
-			wantBits:  7,
-		},
-		//
-		{
-			pfx:       mpp("::/0"),
-			wantDepth: 0,
-			wantBits:  0,
-		},
-		{
-			pfx:       mpp("::/128"),
-			wantDepth: 16,
-			wantBits:  0,
-		},
-		{
-			pfx:       mpp("2001:db8::/31"),
-			wantDepth: 3,
-			wantBits:  7,
-		},
+	var nilTbl *Table[int]
+	if !nilTbl.IsEmpty() {
+		t.Errorf("nil Table: IsEmpty, want true")
 	}
 
-	for _, tc := range tests {
-		gotMaxDepth, gotBits := maxDepthAndLastBits(tc.pfx.Bits())
-		if gotMaxDepth != tc.wantDepth {
-			t.Errorf("maxDepthAndLastBits(%d), maxDepth got: %d, want: %d", tc.pfx.Bits(), gotMaxDepth, tc.wantDepth)
-		}
-		if gotBits != tc.wantBits {
-			t.Errorf("maxDepthAndLastBits(%d), lastBits got: %d, want: %d", tc.pfx.Bits(), gotBits, tc.wantBits)
-		}
+	tbl := new(Table[int])
+	if !tbl.IsEmpty() {
+		t.Errorf("empty Table: IsEmpty, want true")
 	}
-}
 
-// ############ benchmarks ################################
+	tbl.Insert(mpp("2001:db8::/32"), 1)
+	if tbl.IsEmpty() {
+		t.Errorf("IsEmpty after Insert v6, want false")
+	}
 
-var benchRouteCount = []int{1, 2, 5, 10, 100, 1000, 10_000, 100_000, 200_000}
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Delete(mpp("2001:db8::/32"))
+	if tbl.IsEmpty() {
+		t.Errorf("IsEmpty with v4 route, want false")
+	}
 
-func BenchmarkTableInsertRandom(b *testing.B) {
+	tbl.Delete(mpp("10.0.0.0/8"))
+	if !tbl.IsEmpty() {
+		t.Errorf("IsEmpty after Delete all, want true")
+	}
+}
+
+func TestRecount(t *testing.T) {
+	t.Parallel()
 	prng := rand.New(rand.NewSource(42))
-	for _, n := range []int{10_000, 100_000, 1_000_000, 2_000_000} {
-		randomPfxs := randomRealWorldPrefixes(prng, n)
 
-		rt := new(Table[*MyInt])
-		for i, pfx := range randomPfxs {
-			myInt := MyInt(i)
-			rt.Insert(pfx, &myInt)
+	pfxs := randomPrefixes(prng, 10_000)
+	other := new(Table[int])
+	for _, pfx := range randomPrefixes(prng, 1_000) {
+		other.Insert(pfx.pfx, pfx.val)
+	}
+
+	tbl := new(Table[int])
+	check := func(what string) {
+		t.Helper()
+		size4, size6 := tbl.Size4(), tbl.Size6()
+		if v4, v6 := tbl.Recount(); v4 != size4 || v6 != size6 {
+			t.Fatalf("%s: Recount() = (%d, %d), cached (%d, %d)", what, v4, v6, size4, size6)
 		}
+	}
 
-		prt := rt
+	// random sequence of ops
+	for i := 0; i < 20_000; i++ {
+		pfx := pfxs[prng.Intn(len(pfxs))]
+		switch prng.Intn(4) {
+		case 0, 1:
+			tbl.Insert(pfx.pfx, pfx.val)
+		case 2:
+			tbl.Delete(pfx.pfx)
+		case 3:
+			tbl.Update(pfx.pfx, func(val int, _ bool) int { return val + 1 })
+		}
+	}
+	check("random ops")
 
-		probe := randomPrefix(prng)
-		myInt := MyInt(42)
+	tbl.Union(other)
+	check("Union")
 
-		b.ResetTimer()
-		b.Run(fmt.Sprintf("mutable into %d", n), func(b *testing.B) {
-			for j := 0; j < b.N; j++ {
-				rt.Insert(probe, &myInt)
-			}
+	tbl = tbl.InsertPersist(mpp("10.0.0.0/8"), 1).DeletePersist(pfxs[0].pfx)
+	check("InsertPersist, DeletePersist")
 
-			s4 := rt.root4.nodeStatsRec()
-			s6 := rt.root6.nodeStatsRec()
-			stats := stats{
-				s4.pfxs + s6.pfxs,
-				s4.childs + s6.childs,
-				s4.nodes + s6.nodes,
-				s4.leaves + s6.leaves,
-				s4.fringes + s6.fringes,
-			}
+	// the cached counters are reset
+	tbl.size4, tbl.size6 = -1, -1
+	v4, v6 := tbl.Recount()
+	if tbl.Size4() != v4 || tbl.Size6() != v6 {
+		t.Errorf("Recount, cached counters not reset: (%d, %d), want (%d, %d)", tbl.Size4(), tbl.Size6(), v4, v6)
+	}
+}
 
-			b.ReportMetric(float64(rt.Size())/float64(stats.nodes), "Prefix/Node")
-		})
+func TestEstimatedBytes(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
 
-		b.ResetTimer()
-		b.Run(fmt.Sprintf("persist into %d", n), func(b *testing.B) {
-			for j := 0; j < b.N; j++ {
-				_ = prt.InsertPersist(probe, &myInt)
-			}
+	var nilTbl *Table[int]
+	if got := nilTbl.EstimatedBytes(); got != 0 {
+		t.Errorf("nil table, EstimatedBytes() = %d, want 0", got)
+	}
 
-			s4 := rt.root4.nodeStatsRec()
-			s6 := rt.root6.nodeStatsRec()
-			stats := stats{
-				s4.pfxs + s6.pfxs,
-				s4.childs + s6.childs,
-				s4.nodes + s6.nodes,
-				s4.leaves + s6.leaves,
-				s4.fringes + s6.fringes,
-			}
-
-			b.ReportMetric(float64(rt.Size())/float64(stats.nodes), "Prefix/Node")
-		})
+	tbl := new(Table[int])
+	empty := tbl.EstimatedBytes()
+	if want := int(unsafe.Sizeof(*tbl)); empty != want {
+		t.Errorf("empty table, EstimatedBytes() = %d, want %d", empty, want)
+	}
 
+	if got := NewTableMissFilter[int]().EstimatedBytes(); got <= empty {
+		t.Errorf("EstimatedBytes() with miss filter = %d, want > %d", got, empty)
 	}
-}
 
-func BenchmarkTableDelete(b *testing.B) {
-	prng := rand.New(rand.NewSource(42))
-	for _, n := range benchRouteCount {
-		rt := new(Table[*MyInt])
-		for i, route := range randomPrefixes(prng, n) {
-			myInt := MyInt(i)
-			rt.Insert(route.pfx, &myInt)
+	prev := empty
+	for _, n := range []int{10, 1_000, 10_000} {
+		for _, pfx := range randomPrefixes(prng, n) {
+			tbl.Insert(pfx.pfx, pfx.val)
 		}
 
-		prt := rt
-		probe := randomPrefix(prng)
-
-		b.ResetTimer()
-		b.Run(fmt.Sprintf("mutable from_%d", n), func(b *testing.B) {
-			for j := 0; j < b.N; j++ {
-				rt.Delete(probe)
-			}
-		})
+		got := tbl.EstimatedBytes()
+		if got <= prev {
+			t.Errorf("EstimatedBytes() = %d after %d more inserts, want > %d", got, n, prev)
+		}
+		prev = got
+
+		// lower bound, all nodes, leaves, fringes and values
+		var lower int
+		for _, s := range []stats{tbl.root4.nodeStatsRec(), tbl.root6.nodeStatsRec()} {
+			lower += (s.nodes-1)*int(unsafe.Sizeof(node[int]{})) +
+				s.leaves*int(unsafe.Sizeof(leafNode[int]{})) +
+				s.fringes*int(unsafe.Sizeof(fringeNode[int]{})) +
+				s.pfxs*int(unsafe.Sizeof(int(0)))
+		}
 
-		b.ResetTimer()
-		b.Run(fmt.Sprintf("persist from_%d", n), func(b *testing.B) {
-			for j := 0; j < b.N; j++ {
-				_ = prt.DeletePersist(probe)
-			}
-		})
+		if got < lower {
+			t.Errorf("EstimatedBytes() = %d, want >= %d", got, lower)
+		}
 	}
 }
 
-func BenchmarkTableGet(b *testing.B) {
+func TestInsertManyProgress(t *testing.T) {
+	t.Parallel()
 	prng := rand.New(rand.NewSource(42))
-	for _, fam := range []string{"ipv4", "ipv6"} {
-		rng := randomPrefixes4
-		if fam == "ipv6" {
-			rng = randomPrefixes6
-		}
 
-		for _, nroutes := range benchRouteCount {
-			rt := new(Table[int])
-			for _, route := range rng(prng, nroutes) {
-				rt.Insert(route.pfx, route.val)
-			}
+	pfxs := randomPrefixes(prng, 1_000)
+	items := make([]Item[int], 0, len(pfxs))
+	want := new(Table[int])
+	for _, pfx := range pfxs {
+		items = append(items, Item[int]{CIDR: pfx.pfx, Value: pfx.val})
+		want.Insert(pfx.pfx, pfx.val)
+	}
 
-			probe := rng(prng, 1)[0]
+	tests := []struct {
+		items []Item[int]
+		every int
+		calls []int
+	}{
+		{items[:10], 3, []int{3, 6, 9, 10}},
+		{items[:9], 3, []int{3, 6, 9}},
+		{items[:10], 0, []int{10}},
+		{items[:0], 5, []int{0}},
+		{items, 400, []int{400, 800, 1000}},
+	}
 
-			b.ResetTimer()
-			b.Run(fmt.Sprintf("%s/From_%d", fam, nroutes), func(b *testing.B) {
-				for j := 0; j < b.N; j++ {
-					_, boolSink = rt.Get(probe.pfx)
-				}
-			})
+	for _, tt := range tests {
+		var calls []int
+		tbl := new(Table[int])
+		tbl.InsertManyProgress(tt.items, tt.every, func(done int) { calls = append(calls, done) })
+
+		if !reflect.DeepEqual(calls, tt.calls) {
+			t.Errorf("InsertManyProgress(%d items, every %d), calls: %v, want %v", len(tt.items), tt.every, calls, tt.calls)
 		}
 	}
-}
 
-func BenchmarkTableLPM(b *testing.B) {
-	prng := rand.New(rand.NewSource(42))
-	for _, fam := range []string{"ipv4", "ipv6"} {
-		rng := randomPrefixes4
-		if fam == "ipv6" {
-			rng = randomPrefixes6
-		}
+	// nil callback
+	tbl := new(Table[int])
+	tbl.InsertManyProgress(items, 10, nil)
+	if tbl.String() != want.String() {
+		t.Errorf("InsertManyProgress, table differs from Insert")
+	}
+}
 
-		for _, nroutes := range benchRouteCount {
-			rt := new(Table[int])
-			for _, route := range rng(prng, nroutes) {
-				rt.Insert(route.pfx, route.val)
-			}
+func TestCanonical(t *testing.T) {
+	t.Parallel()
 
-			probe := rng(prng, 1)[0]
+	tests := []struct {
+		pfx  netip.Prefix
+		want netip.Prefix
+	}{
+		{netip.MustParsePrefix("10.1.2.3/8"), mpp("10.0.0.0/8")},
+		{mpp("10.0.0.0/8"), mpp("10.0.0.0/8")},
+		{netip.MustParsePrefix("2001:db8::1/32"), mpp("2001:db8::/32")},
+		{netip.MustParsePrefix("::ffff:10.1.2.3/104"), mpp("10.0.0.0/8")},
+		{mpp("::ffff:10.0.0.0/104"), mpp("10.0.0.0/8")},
+		{mpp("::ffff:0.0.0.0/96"), mpp("0.0.0.0/0")},
+		{mpp("::ffff:10.1.2.3/128"), mpp("10.1.2.3/32")},
+		{netip.MustParsePrefix("::ffff:10.1.2.3/95"), mpp("::fffe:0:0/95")},
+		{netip.MustParsePrefix("::ffff:10.1.2.3/80"), mpp("::/80")},
+		{netip.Prefix{}, netip.Prefix{}},
+	}
 
-			b.ResetTimer()
-			b.Run(fmt.Sprintf("%s/In_%6d/%s", fam, nroutes, "Contains"), func(b *testing.B) {
-				for j := 0; j < b.N; j++ {
-					boolSink = rt.Contains(probe.pfx.Addr())
-				}
-			})
+	for _, tt := range tests {
+		got := Canonical(tt.pfx)
+		if got != tt.want {
+			t.Errorf("Canonical(%s) = %s, want %s", tt.pfx, got, tt.want)
+		}
 
-			b.ResetTimer()
-			b.Run(fmt.Sprintf("%s/In_%6d/%s", fam, nroutes, "Lookup"), func(b *testing.B) {
-				for j := 0; j < b.N; j++ {
-					_, boolSink = rt.Lookup(probe.pfx.Addr())
-				}
-			})
+		if !tt.pfx.IsValid() {
+			continue
+		}
 
-			b.ResetTimer()
-			b.Run(fmt.Sprintf("%s/In_%6d/%s", fam, nroutes, "Prefix"), func(b *testing.B) {
-				for j := 0; j < b.N; j++ {
-					_, boolSink = rt.LookupPrefix(probe.pfx)
-				}
-			})
+		// the canonical form is stored as is
+		tbl := new(Table[int])
+		if err := tbl.InsertCanonical(got, 1); err != nil {
+			t.Errorf("InsertCanonical(%s), err: %v", got, err)
+		}
+		tbl.All()(func(pfx netip.Prefix, _ int) bool {
+			if pfx != got {
+				t.Errorf("InsertCanonical(%s), stored as %s", got, pfx)
+			}
+			return true
+		})
 
-			b.ResetTimer()
-			b.Run(fmt.Sprintf("%s/In_%6d/%s", fam, nroutes, "PrefixLPM"), func(b *testing.B) {
-				for j := 0; j < b.N; j++ {
-					_, _, boolSink = rt.LookupPrefixLPM(probe.pfx)
-				}
-			})
+		// the table itself only masks, it doesn't unmap
+		tbl = new(Table[int])
+		tbl.Insert(tt.pfx, 1)
+		if canonical, _, ok := tbl.GetCanonical(tt.pfx); !ok || canonical != tt.pfx.Masked() {
+			t.Errorf("Insert(%s), GetCanonical = (%s, %v), want (%s, true)", tt.pfx, canonical, ok, tt.pfx.Masked())
 		}
 	}
 }
 
-func BenchmarkTableOverlapsPrefix(b *testing.B) {
+func TestFirstLast(t *testing.T) {
+	t.Parallel()
 	prng := rand.New(rand.NewSource(42))
-	for _, fam := range []string{"ipv4", "ipv6"} {
-		rng := randomPrefixes4
-		if fam == "ipv6" {
-			rng = randomPrefixes6
-		}
 
-		for _, nroutes := range benchRouteCount {
-			rt := new(Table[int])
-			for _, route := range rng(prng, nroutes) {
-				rt.Insert(route.pfx, route.val)
-			}
+	tbl := new(Table[int])
+	if _, _, ok := tbl.First(); ok {
+		t.Errorf("First, empty table, ok: true, want false")
+	}
+	if _, _, ok := tbl.Last(); ok {
+		t.Errorf("Last, empty table, ok: true, want false")
+	}
 
-			probe := rng(prng, 1)[0]
+	check := func() {
+		t.Helper()
 
-			b.ResetTimer()
-			b.Run(fmt.Sprintf("%s/With_%d", fam, nroutes), func(b *testing.B) {
-				for j := 0; j < b.N; j++ {
-					boolSink = rt.OverlapsPrefix(probe.pfx)
-				}
-			})
+		var sorted []netip.Prefix
+		tbl.AllSorted()(func(pfx netip.Prefix, _ int) bool {
+			sorted = append(sorted, pfx)
+			return true
+		})
+
+		first, firstVal, ok := tbl.First()
+		if !ok || first != sorted[0] {
+			t.Fatalf("First() = (%s, %v), want (%s, true)", first, ok, sorted[0])
+		}
+		if want, _ := tbl.Get(first); firstVal != want {
+			t.Fatalf("First(), value: %d, want %d", firstVal, want)
 		}
-	}
-}
 
-func BenchmarkTableOverlaps(b *testing.B) {
-	prng := rand.New(rand.NewSource(42))
-	for _, fam := range []string{"ipv4", "ipv6"} {
-		rng := randomPrefixes4
-		if fam == "ipv6" {
-			rng = randomPrefixes6
+		last, lastVal, ok := tbl.Last()
+		if !ok || last != sorted[len(sorted)-1] {
+			t.Fatalf("Last() = (%s, %v), want (%s, true)", last, ok, sorted[len(sorted)-1])
+		}
+		if want, _ := tbl.Get(last); lastVal != want {
+			t.Fatalf("Last(), value: %d, want %d", lastVal, want)
 		}
+	}
 
-		for _, nroutes := range benchRouteCount {
-			rt := new(Table[int])
-			for _, route := range rng(prng, nroutes) {
-				rt.Insert(route.pfx, route.val)
-			}
+	// equal addresses, node prefixes against children with the same octet
+	for _, s := range []string{"10.0.0.0/7", "10.0.0.0/8", "10.0.0.0/16", "11.0.0.0/8", "11.255.0.0/16", "11.255.255.255/32"} {
+		tbl.Insert(mpp(s), 0)
+		check()
+	}
 
-			inter := new(Table[int])
-			for _, route := range rng(prng, nroutes) {
-				inter.Insert(route.pfx, route.val)
-			}
+	for i := 0; i < 100; i++ {
+		tbl = new(Table[int])
+		for _, pfx := range randomPrefixes(prng, 1+prng.Intn(50)) {
+			tbl.Insert(pfx.pfx, pfx.val)
+		}
+		check()
+	}
 
-			b.ResetTimer()
-			b.Run(fmt.Sprintf("%s/%d_with_%d", fam, nroutes, nroutes), func(b *testing.B) {
-				for j := 0; j < b.N; j++ {
-					boolSink = rt.Overlaps(inter)
-				}
-			})
+	for i := 0; i < 100; i++ {
+		tbl = new(Table[int])
+		for _, pfx := range randomPrefixes4(prng, 1+prng.Intn(50)) {
+			tbl.Insert(pfx.pfx, pfx.val)
 		}
+		check()
 	}
 }
 
-func BenchmarkTableClone(b *testing.B) {
+func TestAllOfLength(t *testing.T) {
+	t.Parallel()
 	prng := rand.New(rand.NewSource(42))
-	for _, fam := range []string{"ipv4", "ipv6"} {
-		rng := randomPrefixes4
-		if fam == "ipv6" {
-			rng = randomPrefixes6
-		}
-
-		for _, nroutes := range benchRouteCount {
-			rt := new(Table[int])
-			for _, route := range rng(prng, nroutes) {
-				rt.Insert(route.pfx, route.val)
-			}
 
-			b.ResetTimer()
-			b.Run(fmt.Sprintf("%s/%d", fam, nroutes), func(b *testing.B) {
-				for j := 0; j < b.N; j++ {
-					rt.Clone()
-				}
-			})
-		}
+	tbl := new(Table[int])
+	for _, pfx := range randomPrefixes(prng, 10_000) {
+		tbl.Insert(pfx.pfx, pfx.val)
 	}
-}
 
-func BenchmarkMemIP4(b *testing.B) {
-	prng := rand.New(rand.NewSource(42))
-	for _, k := range []int{1_000, 10_000, 100_000, 1_000_000} {
-		var startMem, endMem runtime.MemStats
+	// path compressed fringes and leaves, pushed down later
+	for _, s := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.2.3/32", "2001:db8::/32"} {
+		tbl.Insert(mpp(s), 0)
+	}
 
-		runtime.GC()
-		runtime.ReadMemStats(&startMem)
+	for bits := -1; bits <= 129; bits++ {
+		want := map[netip.Prefix]int{}
+		tbl.All()(func(pfx netip.Prefix, val int) bool {
+			if pfx.Bits() == bits {
+				want[pfx] = val
+			}
+			return true
+		})
 
-		b.Run(strconv.Itoa(k), func(b *testing.B) {
-			rt := new(Table[struct{}])
-			for j := 0; j < b.N; j++ {
-				rt = new(Table[struct{}])
-				for _, pfx := range randomRealWorldPrefixes4(prng, k) {
-					rt.Insert(pfx, struct{}{})
-				}
+		got := map[netip.Prefix]int{}
+		tbl.AllOfLength(bits)(func(pfx netip.Prefix, val int) bool {
+			if _, ok := got[pfx]; ok {
+				t.Fatalf("AllOfLength(%d), %s yielded twice", bits, pfx)
 			}
+			got[pfx] = val
+			return true
+		})
 
-			runtime.GC()
-			runtime.ReadMemStats(&endMem)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("AllOfLength(%d), got %d prefixes, want %d", bits, len(got), len(want))
+		}
+	}
 
-			stats := rt.root4.nodeStatsRec()
-			b.ReportMetric(float64(int(endMem.HeapAlloc-startMem.HeapAlloc)/k), "bytes/pfx")
-			b.ReportMetric(float64(stats.nodes), "node")
-			b.ReportMetric(float64(stats.pfxs), "pfxs")
-			b.ReportMetric(float64(stats.leaves), "leaf")
-			b.ReportMetric(float64(stats.fringes), "fringe")
-			b.ReportMetric(0, "ns/op")
-		})
+	// early stop
+	count := 0
+	tbl.AllOfLength(32)(func(netip.Prefix, int) bool {
+		count++
+		return count < 2
+	})
+	if count != 2 {
+		t.Errorf("AllOfLength, early stop, got %d calls, want 2", count)
 	}
 }
 
-func BenchmarkMemIP6(b *testing.B) {
+func TestAllByValue(t *testing.T) {
+	t.Parallel()
 	prng := rand.New(rand.NewSource(42))
-	for _, k := range []int{1_000, 10_000, 100_000, 1_000_000} {
-		var startMem, endMem runtime.MemStats
 
-		runtime.GC()
-		runtime.ReadMemStats(&startMem)
+	tbl := new(Table[string])
+	for i, pfx := range randomRealWorldPrefixes(prng, 2_000) {
+		tbl.Insert(pfx, fmt.Sprintf("next-hop-%d", i%7))
+	}
 
-		b.Run(strconv.Itoa(k), func(b *testing.B) {
-			rt := new(Table[struct{}])
-			for j := 0; j < b.N; j++ {
-				rt = new(Table[struct{}])
-				for _, pfx := range randomRealWorldPrefixes6(prng, k) {
-					rt.Insert(pfx, struct{}{})
-				}
-			}
+	var pfxs []netip.Prefix
+	var vals []string
+	tbl.AllByValue(func(a, b string) bool { return a < b })(func(pfx netip.Prefix, val string) bool {
+		pfxs = append(pfxs, pfx)
+		vals = append(vals, val)
+		return true
+	})
 
-			runtime.GC()
-			runtime.ReadMemStats(&endMem)
+	if len(pfxs) != tbl.Size() {
+		t.Fatalf("AllByValue, got %d items, want %d", len(pfxs), tbl.Size())
+	}
 
-			stats := rt.root6.nodeStatsRec()
-			b.ReportMetric(float64(int(endMem.HeapAlloc-startMem.HeapAlloc)/k), "bytes/pfx")
-			b.ReportMetric(float64(stats.nodes), "node")
-			b.ReportMetric(float64(stats.pfxs), "pfxs")
-			b.ReportMetric(float64(stats.leaves), "leaf")
-			b.ReportMetric(float64(stats.fringes), "fringe")
-			b.ReportMetric(0, "ns/op")
-		})
+	for i := 1; i < len(pfxs); i++ {
+		switch {
+		case vals[i-1] > vals[i]:
+			t.Fatalf("AllByValue, values not sorted: %q before %q", vals[i-1], vals[i])
+		case vals[i-1] == vals[i] && !lessPrefix(pfxs[i-1], pfxs[i]):
+			t.Fatalf("AllByValue, equal values, prefixes not in CIDR order: %s before %s", pfxs[i-1], pfxs[i])
+		}
+	}
+
+	// early stop
+	count := 0
+	tbl.AllByValue(func(a, b string) bool { return a < b })(func(netip.Prefix, string) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Errorf("AllByValue, early stop, got %d calls, want 3", count)
 	}
 }
 
-func BenchmarkMem(b *testing.B) {
+func TestSample(t *testing.T) {
+	t.Parallel()
 	prng := rand.New(rand.NewSource(42))
-	for _, k := range []int{1_000, 10_000, 100_000, 1_000_000} {
-		var startMem, endMem runtime.MemStats
 
-		runtime.GC()
-		runtime.ReadMemStats(&startMem)
+	tbl := new(Table[int])
+	if got := tbl.Sample(10, prng); got != nil {
+		t.Errorf("empty table, Sample: %v, want nil", got)
+	}
+
+	pfxs := randomPrefixes(prng, 1_000)
+	for i, pfx := range pfxs {
+		tbl.Insert(pfx.pfx, i)
+	}
+
+	if got := tbl.Sample(0, prng); got != nil {
+		t.Errorf("Sample(0): %v, want nil", got)
+	}
+
+	if got := tbl.Sample(2_000, prng); len(got) != tbl.Size() {
+		t.Errorf("Sample(2000), got %d prefixes, want all %d", len(got), tbl.Size())
+	}
+
+	// deterministic for the same rng seed
+	s1 := tbl.Sample(10, rand.New(rand.NewSource(1)))
+	s2 := tbl.Sample(10, rand.New(rand.NewSource(1)))
+	if !reflect.DeepEqual(s1, s2) {
+		t.Errorf("Sample, not deterministic: %v != %v", s1, s2)
+	}
+
+	// distinct members of the table
+	seen := map[netip.Prefix]bool{}
+	for _, pfx := range s1 {
+		if seen[pfx] || !tbl.Has(pfx) {
+			t.Errorf("Sample, %s is a duplicate or not in table", pfx)
+		}
+		seen[pfx] = true
+	}
+
+	// roughly uniform, each prefix is sampled with probability 10/1000
+	counts := map[netip.Prefix]int{}
+	for i := 0; i < 2_000; i++ {
+		for _, pfx := range tbl.Sample(10, prng) {
+			counts[pfx]++
+		}
+	}
+
+	// expected 20 per prefix
+	for pfx, count := range counts {
+		if count > 60 {
+			t.Errorf("Sample, not uniform, %s sampled %d times, expected about 20", pfx, count)
+		}
+	}
+	if len(counts) < 900 {
+		t.Errorf("Sample, not uniform, only %d distinct prefixes sampled", len(counts))
+	}
+}
+
+func TestCoveringBlocks(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for i, pfx := range randomRealWorldPrefixes(prng, 2_000) {
+		tbl.Insert(pfx, i)
+	}
+
+	var want []netip.Prefix
+	tbl.AllSorted()(func(pfx netip.Prefix, _ int) bool {
+		if tbl.SupernetCount(pfx) == 1 {
+			want = append(want, pfx)
+		}
+		return true
+	})
+
+	var got []netip.Prefix
+	tbl.CoveringBlocks()(func(pfx netip.Prefix) bool {
+		got = append(got, pfx)
+		return true
+	})
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("CoveringBlocks, got %d blocks, want %d", len(got), len(want))
+	}
+
+	// no overlaps between the blocks
+	for i := 1; i < len(got); i++ {
+		if got[i-1].Overlaps(got[i]) {
+			t.Errorf("CoveringBlocks, %s overlaps %s", got[i-1], got[i])
+		}
+	}
+
+	// with default route just one block per family
+	tbl.Insert(mpp("0.0.0.0/0"), 0)
+	got = got[:0]
+	tbl.CoveringBlocks()(func(pfx netip.Prefix) bool {
+		got = append(got, pfx)
+		return false
+	})
+
+	if len(got) != 1 || got[0] != mpp("0.0.0.0/0") {
+		t.Errorf("CoveringBlocks with default route, early stop, got %v", got)
+	}
+}
+
+func TestAllWithDepth(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for i, pfx := range randomRealWorldPrefixes(prng, 2_000) {
+		tbl.Insert(pfx, i)
+	}
+
+	var want []netip.Prefix
+	tbl.All()(func(pfx netip.Prefix, _ int) bool {
+		want = append(want, pfx)
+		return true
+	})
+
+	var got []netip.Prefix
+	var compressed int
+	tbl.AllWithDepth(func(pfx netip.Prefix, val int, depth int) bool {
+		got = append(got, pfx)
+
+		if v, _ := tbl.Get(pfx); v != val {
+			t.Errorf("AllWithDepth, %s, wrong value %d", pfx, val)
+		}
+
+		if pfx.Bits() < depth*8 {
+			t.Errorf("AllWithDepth, %s, impossible depth %d", pfx, depth)
+		}
+
+		if pfx.Bits() >= (depth+1)*8 {
+			compressed++
+		}
+		return true
+	})
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("AllWithDepth, not the same prefixes and order as All")
+	}
+
+	stats4, stats6 := tbl.root4.nodeStatsRec(), tbl.root6.nodeStatsRec()
+	if wantCompressed := stats4.leaves + stats4.fringes + stats6.leaves + stats6.fringes; compressed != wantCompressed {
+		t.Errorf("AllWithDepth, path-compressed: %d, want %d", compressed, wantCompressed)
+	}
+
+	// fixed depths
+	tbl = new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/7"), 0)
+	tbl.Insert(mpp("10.0.0.0/16"), 0)
+	tbl.Insert(mpp("10.0.0.0/20"), 0)
+
+	depths := map[netip.Prefix]int{}
+	tbl.AllWithDepth(func(pfx netip.Prefix, _ int, depth int) bool {
+		depths[pfx] = depth
+		return true
+	})
+
+	wantDepths := map[netip.Prefix]int{mpp("10.0.0.0/7"): 0, mpp("10.0.0.0/16"): 2, mpp("10.0.0.0/20"): 2}
+	if !reflect.DeepEqual(depths, wantDepths) {
+		t.Errorf("AllWithDepth, depths %v, want %v", depths, wantDepths)
+	}
+}
+
+func TestWalkPostorder(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for _, pfx := range randomRealWorldPrefixes(prng, 2_000) {
+		tbl.Insert(pfx, pfx.Bits())
+	}
+
+	// fixed hierarchy, some nesting for sure
+	for _, s := range []string{"10.0.0.0/8", "10.0.0.0/16", "10.0.0.0/24", "10.0.1.0/24", "10.1.0.0/16", "::/0", "2001:db8::/32"} {
+		tbl.Insert(mpp(s), mpp(s).Bits())
+	}
+
+	visited := make(map[netip.Prefix]int)
+	var order []netip.Prefix
+
+	tbl.WalkPostorder(func(pfx netip.Prefix, val int, depth int) bool {
+		if _, ok := visited[pfx]; ok {
+			t.Fatalf("WalkPostorder, %s visited twice", pfx)
+		}
+		visited[pfx] = len(order)
+		order = append(order, pfx)
+
+		if val != pfx.Bits() {
+			t.Errorf("WalkPostorder, %s, wrong value %d", pfx, val)
+		}
+
+		if want := tbl.SupernetCount(pfx) - 1; depth != want {
+			t.Errorf("WalkPostorder, %s, depth %d, want %d", pfx, depth, want)
+		}
+		return true
+	})
+
+	if len(order) != tbl.Size() {
+		t.Fatalf("WalkPostorder, visited %d, want %d", len(order), tbl.Size())
+	}
+
+	// all supernets after the subnets
+	for i, pfx := range order {
+		tbl.Supernets(pfx)(func(super netip.Prefix, _ int) bool {
+			if super != pfx && visited[super] < i {
+				t.Fatalf("WalkPostorder, supernet %s before subnet %s", super, pfx)
+			}
+			return true
+		})
+	}
+
+	// early stop, also while unwinding the stack at the end
+	for _, stop := range []int{1, len(order) / 2, len(order) - 1} {
+		count := 0
+		tbl.WalkPostorder(func(netip.Prefix, int, int) bool {
+			count++
+			return count < stop
+		})
+
+		if count != stop {
+			t.Errorf("WalkPostorder, stop after %d, got %d calls", stop, count)
+		}
+	}
+}
+
+func TestContainsDefaultRoute(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	if tbl.ContainsDefaultRoute() {
+		t.Errorf("empty table, ContainsDefaultRoute, want false")
+	}
+
+	// not default routes
+	tbl.Insert(mpp("0.0.0.0/1"), 1)
+	tbl.Insert(mpp("::/8"), 1)
+	if tbl.ContainsDefaultRoute() {
+		t.Errorf("ContainsDefaultRoute, want false")
+	}
+
+	tbl.Insert(mpp("::/0"), 0)
+	if !tbl.ContainsDefaultRoute() || tbl.ContainsDefaultRoute4() || !tbl.ContainsDefaultRoute6() {
+		t.Errorf("::/0, ContainsDefaultRoute(4/6) = (%v, %v, %v), want (true, false, true)",
+			tbl.ContainsDefaultRoute(), tbl.ContainsDefaultRoute4(), tbl.ContainsDefaultRoute6())
+	}
+
+	tbl.Insert(mpp("0.0.0.0/0"), 0)
+	tbl.Delete(mpp("::/0"))
+	if !tbl.ContainsDefaultRoute() || !tbl.ContainsDefaultRoute4() || tbl.ContainsDefaultRoute6() {
+		t.Errorf("0.0.0.0/0, ContainsDefaultRoute(4/6) = (%v, %v, %v), want (true, true, false)",
+			tbl.ContainsDefaultRoute(), tbl.ContainsDefaultRoute4(), tbl.ContainsDefaultRoute6())
+	}
+}
+
+func TestHasPrefixCompare(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for i, pfx := range randomRealWorldPrefixes(prng, 500) {
+		tbl.Insert(pfx, i)
+	}
+
+	v4, v6 := tbl.PrefixLenHistogram()
+	for bits := -1; bits <= 129; bits++ {
+		want := false
+		if bits >= 0 && bits <= 32 && v4[bits] > 0 {
+			want = true
+		}
+		if bits >= 0 && bits <= 128 && v6[bits] > 0 {
+			want = true
+		}
+
+		if got := tbl.HasPrefix(bits); got != want {
+			t.Errorf("HasPrefix(%d) = %v, want %v", bits, got, want)
+		}
+	}
+}
+
+func TestPrefixLenHistogram(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for _, pfx := range randomPrefixes(prng, 10_000) {
+		tbl.Insert(pfx.pfx, pfx.val)
+	}
+
+	var want4 [33]int
+	var want6 [129]int
+	tbl.All()(func(pfx netip.Prefix, _ int) bool {
+		if pfx.Addr().Is4() {
+			want4[pfx.Bits()]++
+		} else {
+			want6[pfx.Bits()]++
+		}
+		return true
+	})
+
+	got4, got6 := tbl.PrefixLenHistogram()
+	if got4 != want4 {
+		t.Errorf("PrefixLenHistogram v4, want %v, got %v", want4, got4)
+	}
+	if got6 != want6 {
+		t.Errorf("PrefixLenHistogram v6, want %v, got %v", want6, got6)
+	}
+}
+
+func TestCountDistinctValues(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	if got := CountDistinctValues(tbl); got != 0 {
+		t.Errorf("empty Table: want: 0, got: %d", got)
+	}
+
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Insert(mpp("10.0.0.0/24"), 2)
+	tbl.Insert(mpp("192.168.0.0/16"), 1)
+	tbl.Insert(mpp("2001:db8::/32"), 3)
+	tbl.Insert(mpp("::/0"), 2)
+
+	if got := CountDistinctValues(tbl); got != 3 {
+		t.Errorf("CountDistinctValues: want: 3, got: %d", got)
+	}
+
+	// hash all odd values to the same bucket
+	hash := func(v int) uint64 { return uint64(v % 2) }
+	if got := tbl.CountDistinctValuesFunc(hash); got != 2 {
+		t.Errorf("CountDistinctValuesFunc: want: 2, got: %d", got)
+	}
+}
+
+func TestLastIdxLastBits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pfx       netip.Prefix
+		wantDepth int
+		wantBits  uint8
+	}{
+		{
+			pfx:       mpp("0.0.0.0/0"),
+			wantDepth: 0,
+			wantBits:  0,
+		},
+		{
+			pfx:       mpp("0.0.0.0/32"),
+			wantDepth: 4,
+			wantBits:  0,
+		},
+		{
+			pfx:       mpp("10.0.0.0/7"),
+			wantDepth: 0,
+			wantBits:  7,
+		},
+		{
+			pfx:       mpp("10.20.0.0/14"),
+			wantDepth: 1,
+			wantBits:  6,
+		},
+		{
+			pfx:       mpp("10.20.30.0/24"),
+			wantDepth: 3,
+			wantBits:  0,
+		},
+		{
+			pfx:       mpp("10.20.30.40/31"),
+			wantDepth: 3,
+			wantBits:  7,
+		},
+		//
+		{
+			pfx:       mpp("::/0"),
+			wantDepth: 0,
+			wantBits:  0,
+		},
+		{
+			pfx:       mpp("::/128"),
+			wantDepth: 16,
+			wantBits:  0,
+		},
+		{
+			pfx:       mpp("2001:db8::/31"),
+			wantDepth: 3,
+			wantBits:  7,
+		},
+		{
+			pfx:       mpp("2001:db8::/127"),
+			wantDepth: 15,
+			wantBits:  7,
+		},
+	}
+
+	for _, tc := range tests {
+		gotMaxDepth, gotBits := maxDepthAndLastBits(tc.pfx.Bits())
+		if gotMaxDepth != tc.wantDepth {
+			t.Errorf("maxDepthAndLastBits(%d), maxDepth got: %d, want: %d", tc.pfx.Bits(), gotMaxDepth, tc.wantDepth)
+		}
+		if gotBits != tc.wantBits {
+			t.Errorf("maxDepthAndLastBits(%d), lastBits got: %d, want: %d", tc.pfx.Bits(), gotBits, tc.wantBits)
+		}
+	}
+}
+
+// TestPointToPoint, RFC 3021 /31 and /127 links are stored as prefix idx in the last stride.
+func TestPointToPoint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		p2p      netip.Prefix
+		hosts    [2]netip.Addr
+		outside  netip.Addr
+		supernet netip.Prefix
+	}{
+		{
+			name:     "v4 /31",
+			p2p:      mpp("192.0.2.4/31"),
+			hosts:    [2]netip.Addr{mpa("192.0.2.4"), mpa("192.0.2.5")},
+			outside:  mpa("192.0.2.6"),
+			supernet: mpp("192.0.2.0/24"),
+		},
+		{
+			name:     "v4 /31 last octet 254",
+			p2p:      mpp("10.0.0.254/31"),
+			hosts:    [2]netip.Addr{mpa("10.0.0.254"), mpa("10.0.0.255")},
+			outside:  mpa("10.0.0.253"),
+			supernet: mpp("10.0.0.0/24"),
+		},
+		{
+			name:     "v6 /127",
+			p2p:      mpp("2001:db8::4/127"),
+			hosts:    [2]netip.Addr{mpa("2001:db8::4"), mpa("2001:db8::5")},
+			outside:  mpa("2001:db8::6"),
+			supernet: mpp("2001:db8::/64"),
+		},
+		{
+			name:     "v6 /127 last octet 254",
+			p2p:      mpp("2001:db8::fe/127"),
+			hosts:    [2]netip.Addr{mpa("2001:db8::fe"), mpa("2001:db8::ff")},
+			outside:  mpa("2001:db8::fd"),
+			supernet: mpp("2001:db8::/64"),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := new(Table[int])
+			rt.Insert(tt.supernet, 1)
+			rt.Insert(tt.p2p, 2)
+
+			// force a node in the last stride, not just a path-compressed leaf
+			host := netip.PrefixFrom(tt.hosts[1], tt.hosts[1].BitLen())
+			rt.Insert(host, 3)
+
+			if val, ok := rt.Get(tt.p2p); !ok || val != 2 {
+				t.Errorf("Get(%s), want (2, true), got (%d, %v)", tt.p2p, val, ok)
+			}
+
+			if val, ok := rt.Lookup(tt.hosts[0]); !ok || val != 2 {
+				t.Errorf("Lookup(%s), want (2, true), got (%d, %v)", tt.hosts[0], val, ok)
+			}
+
+			if val, ok := rt.Lookup(tt.hosts[1]); !ok || val != 3 {
+				t.Errorf("Lookup(%s), want (3, true), got (%d, %v)", tt.hosts[1], val, ok)
+			}
+
+			if val, ok := rt.Lookup(tt.outside); !ok || val != 1 {
+				t.Errorf("Lookup(%s), want (1, true), got (%d, %v)", tt.outside, val, ok)
+			}
+
+			if lpm, _, ok := rt.LookupPrefixLPM(netip.PrefixFrom(tt.hosts[0], tt.hosts[0].BitLen())); !ok || lpm != tt.p2p {
+				t.Errorf("LookupPrefixLPM(%s), want %s, got %s", tt.hosts[0], tt.p2p, lpm)
+			}
+
+			if !rt.OverlapsPrefix(tt.p2p) {
+				t.Errorf("OverlapsPrefix(%s), want true", tt.p2p)
+			}
+
+			other := new(Table[int])
+			other.Insert(netip.PrefixFrom(tt.hosts[0], tt.hosts[0].BitLen()), 0)
+			if !rt.Overlaps(other) {
+				t.Errorf("Overlaps with host %s, want true", tt.hosts[0])
+			}
+
+			rt.Delete(tt.p2p)
+			if _, ok := rt.Get(tt.p2p); ok {
+				t.Errorf("Get(%s) after Delete, want false", tt.p2p)
+			}
+
+			if val, ok := rt.Lookup(tt.hosts[0]); !ok || val != 1 {
+				t.Errorf("Lookup(%s) after Delete, want (1, true), got (%d, %v)", tt.hosts[0], val, ok)
+			}
+
+			if rt.Size() != 2 {
+				t.Errorf("Size after Delete, want 2, got %d", rt.Size())
+			}
+		})
+	}
+}
+
+// ############ benchmarks ################################
+
+var benchRouteCount = []int{1, 2, 5, 10, 100, 1000, 10_000, 100_000, 200_000}
+
+func BenchmarkTableInsertRandom(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+	for _, n := range []int{10_000, 100_000, 1_000_000, 2_000_000} {
+		randomPfxs := randomRealWorldPrefixes(prng, n)
+
+		rt := new(Table[*MyInt])
+		for i, pfx := range randomPfxs {
+			myInt := MyInt(i)
+			rt.Insert(pfx, &myInt)
+		}
+
+		prt := rt
+
+		probe := randomPrefix(prng)
+		myInt := MyInt(42)
+
+		b.ResetTimer()
+		b.Run(fmt.Sprintf("mutable into %d", n), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				rt.Insert(probe, &myInt)
+			}
+
+			s4 := rt.root4.nodeStatsRec()
+			s6 := rt.root6.nodeStatsRec()
+			stats := stats{
+				s4.pfxs + s6.pfxs,
+				s4.childs + s6.childs,
+				s4.nodes + s6.nodes,
+				s4.leaves + s6.leaves,
+				s4.fringes + s6.fringes,
+			}
+
+			b.ReportMetric(float64(rt.Size())/float64(stats.nodes), "Prefix/Node")
+		})
+
+		b.ResetTimer()
+		b.Run(fmt.Sprintf("persist into %d", n), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				_ = prt.InsertPersist(probe, &myInt)
+			}
+
+			s4 := rt.root4.nodeStatsRec()
+			s6 := rt.root6.nodeStatsRec()
+			stats := stats{
+				s4.pfxs + s6.pfxs,
+				s4.childs + s6.childs,
+				s4.nodes + s6.nodes,
+				s4.leaves + s6.leaves,
+				s4.fringes + s6.fringes,
+			}
+
+			b.ReportMetric(float64(rt.Size())/float64(stats.nodes), "Prefix/Node")
+		})
+
+	}
+}
+
+func BenchmarkTableDelete(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+	for _, n := range benchRouteCount {
+		rt := new(Table[*MyInt])
+		for i, route := range randomPrefixes(prng, n) {
+			myInt := MyInt(i)
+			rt.Insert(route.pfx, &myInt)
+		}
+
+		prt := rt
+		probe := randomPrefix(prng)
+
+		b.ResetTimer()
+		b.Run(fmt.Sprintf("mutable from_%d", n), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				rt.Delete(probe)
+			}
+		})
+
+		b.ResetTimer()
+		b.Run(fmt.Sprintf("persist from_%d", n), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				_ = prt.DeletePersist(probe)
+			}
+		})
+	}
+}
+
+func BenchmarkTableRecompress(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+	for _, n := range benchRouteCount {
+		pfxs := randomPrefixes(prng, n)
+
+		b.Run(fmt.Sprintf("bulk delete from_%d", n), func(b *testing.B) {
+			var rt *Table[int]
+			for j := 0; j < b.N; j++ {
+				b.StopTimer()
+				rt = new(Table[int])
+				for _, p := range pfxs {
+					rt.Insert(p.pfx, p.val)
+				}
+				rt.sizeUpdate(true, -rt.root4.deleteRandomRec(prng))
+				rt.sizeUpdate(false, -rt.root6.deleteRandomRec(prng))
+				b.StartTimer()
+
+				rt.Recompress()
+			}
+
+			stats4 := rt.root4.nodeStatsRec()
+			stats6 := rt.root6.nodeStatsRec()
+			b.ReportMetric(float64(stats4.nodes+stats6.nodes), "node")
+			b.ReportMetric(float64(rt.Size()), "pfxs")
+		})
+	}
+}
+
+func BenchmarkTableGet(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+	for _, fam := range []string{"ipv4", "ipv6"} {
+		rng := randomPrefixes4
+		if fam == "ipv6" {
+			rng = randomPrefixes6
+		}
+
+		for _, nroutes := range benchRouteCount {
+			rt := new(Table[int])
+			for _, route := range rng(prng, nroutes) {
+				rt.Insert(route.pfx, route.val)
+			}
+
+			probe := rng(prng, 1)[0]
+
+			b.ResetTimer()
+			b.Run(fmt.Sprintf("%s/From_%d", fam, nroutes), func(b *testing.B) {
+				for j := 0; j < b.N; j++ {
+					_, boolSink = rt.Get(probe.pfx)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkTableLPM(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+	for _, fam := range []string{"ipv4", "ipv6"} {
+		rng := randomPrefixes4
+		if fam == "ipv6" {
+			rng = randomPrefixes6
+		}
+
+		for _, nroutes := range benchRouteCount {
+			rt := new(Table[int])
+			for _, route := range rng(prng, nroutes) {
+				rt.Insert(route.pfx, route.val)
+			}
+
+			probe := rng(prng, 1)[0]
+
+			b.ResetTimer()
+			b.Run(fmt.Sprintf("%s/In_%6d/%s", fam, nroutes, "Contains"), func(b *testing.B) {
+				for j := 0; j < b.N; j++ {
+					boolSink = rt.Contains(probe.pfx.Addr())
+				}
+			})
+
+			b.ResetTimer()
+			b.Run(fmt.Sprintf("%s/In_%6d/%s", fam, nroutes, "Lookup"), func(b *testing.B) {
+				for j := 0; j < b.N; j++ {
+					_, boolSink = rt.Lookup(probe.pfx.Addr())
+				}
+			})
+
+			b.ResetTimer()
+			b.Run(fmt.Sprintf("%s/In_%6d/%s", fam, nroutes, "Prefix"), func(b *testing.B) {
+				for j := 0; j < b.N; j++ {
+					_, boolSink = rt.LookupPrefix(probe.pfx)
+				}
+			})
+
+			b.ResetTimer()
+			b.Run(fmt.Sprintf("%s/In_%6d/%s", fam, nroutes, "PrefixLPM"), func(b *testing.B) {
+				for j := 0; j < b.N; j++ {
+					_, _, boolSink = rt.LookupPrefixLPM(probe.pfx)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkTableOverlapsPrefix(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+	for _, fam := range []string{"ipv4", "ipv6"} {
+		rng := randomPrefixes4
+		if fam == "ipv6" {
+			rng = randomPrefixes6
+		}
+
+		for _, nroutes := range benchRouteCount {
+			rt := new(Table[int])
+			for _, route := range rng(prng, nroutes) {
+				rt.Insert(route.pfx, route.val)
+			}
+
+			probe := rng(prng, 1)[0]
+
+			b.ResetTimer()
+			b.Run(fmt.Sprintf("%s/With_%d", fam, nroutes), func(b *testing.B) {
+				for j := 0; j < b.N; j++ {
+					boolSink = rt.OverlapsPrefix(probe.pfx)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkTableOverlaps(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+	for _, fam := range []string{"ipv4", "ipv6"} {
+		rng := randomPrefixes4
+		if fam == "ipv6" {
+			rng = randomPrefixes6
+		}
+
+		for _, nroutes := range benchRouteCount {
+			rt := new(Table[int])
+			for _, route := range rng(prng, nroutes) {
+				rt.Insert(route.pfx, route.val)
+			}
+
+			inter := new(Table[int])
+			for _, route := range rng(prng, nroutes) {
+				inter.Insert(route.pfx, route.val)
+			}
+
+			b.ResetTimer()
+			b.Run(fmt.Sprintf("%s/%d_with_%d", fam, nroutes, nroutes), func(b *testing.B) {
+				for j := 0; j < b.N; j++ {
+					boolSink = rt.Overlaps(inter)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkTableClone(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+	for _, fam := range []string{"ipv4", "ipv6"} {
+		rng := randomPrefixes4
+		if fam == "ipv6" {
+			rng = randomPrefixes6
+		}
+
+		for _, nroutes := range benchRouteCount {
+			rt := new(Table[int])
+			for _, route := range rng(prng, nroutes) {
+				rt.Insert(route.pfx, route.val)
+			}
+
+			b.ResetTimer()
+			b.Run(fmt.Sprintf("%s/%d", fam, nroutes), func(b *testing.B) {
+				for j := 0; j < b.N; j++ {
+					rt.Clone()
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkMemIP4(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+	for _, k := range []int{1_000, 10_000, 100_000, 1_000_000} {
+		var startMem, endMem runtime.MemStats
+
+		runtime.GC()
+		runtime.ReadMemStats(&startMem)
+
+		b.Run(strconv.Itoa(k), func(b *testing.B) {
+			rt := new(Table[struct{}])
+			for j := 0; j < b.N; j++ {
+				rt = new(Table[struct{}])
+				for _, pfx := range randomRealWorldPrefixes4(prng, k) {
+					rt.Insert(pfx, struct{}{})
+				}
+			}
+
+			runtime.GC()
+			runtime.ReadMemStats(&endMem)
+
+			stats := rt.root4.nodeStatsRec()
+			b.ReportMetric(float64(int(endMem.HeapAlloc-startMem.HeapAlloc)/k), "bytes/pfx")
+			b.ReportMetric(float64(stats.nodes), "node")
+			b.ReportMetric(float64(stats.pfxs), "pfxs")
+			b.ReportMetric(float64(stats.leaves), "leaf")
+			b.ReportMetric(float64(stats.fringes), "fringe")
+			b.ReportMetric(0, "ns/op")
+		})
+	}
+}
+
+func BenchmarkMemIP6(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+	for _, k := range []int{1_000, 10_000, 100_000, 1_000_000} {
+		var startMem, endMem runtime.MemStats
+
+		runtime.GC()
+		runtime.ReadMemStats(&startMem)
+
+		b.Run(strconv.Itoa(k), func(b *testing.B) {
+			rt := new(Table[struct{}])
+			for j := 0; j < b.N; j++ {
+				rt = new(Table[struct{}])
+				for _, pfx := range randomRealWorldPrefixes6(prng, k) {
+					rt.Insert(pfx, struct{}{})
+				}
+			}
+
+			runtime.GC()
+			runtime.ReadMemStats(&endMem)
+
+			stats := rt.root6.nodeStatsRec()
+			b.ReportMetric(float64(int(endMem.HeapAlloc-startMem.HeapAlloc)/k), "bytes/pfx")
+			b.ReportMetric(float64(stats.nodes), "node")
+			b.ReportMetric(float64(stats.pfxs), "pfxs")
+			b.ReportMetric(float64(stats.leaves), "leaf")
+			b.ReportMetric(float64(stats.fringes), "fringe")
+			b.ReportMetric(0, "ns/op")
+		})
+	}
+}
+
+func BenchmarkMem(b *testing.B) {
+	prng := rand.New(rand.NewSource(42))
+	for _, k := range []int{1_000, 10_000, 100_000, 1_000_000} {
+		var startMem, endMem runtime.MemStats
+
+		runtime.GC()
+		runtime.ReadMemStats(&startMem)
 
 		b.Run(strconv.Itoa(k), func(b *testing.B) {
 			rt := new(Table[struct{}])
@@ -3192,94 +5631,713 @@ func BenchmarkMem(b *testing.B) {
 				}
 			}
 
-			runtime.GC()
-			runtime.ReadMemStats(&endMem)
+			runtime.GC()
+			runtime.ReadMemStats(&endMem)
+
+			s4 := rt.root4.nodeStatsRec()
+			s6 := rt.root6.nodeStatsRec()
+			stats := stats{
+				s4.pfxs + s6.pfxs,
+				s4.childs + s6.childs,
+				s4.nodes + s6.nodes,
+				s4.leaves + s6.leaves,
+				s4.fringes + s6.fringes,
+			}
+
+			b.ReportMetric(float64(int(endMem.HeapAlloc-startMem.HeapAlloc)/k), "bytes/pfx")
+			b.ReportMetric(float64(stats.nodes), "node")
+			b.ReportMetric(float64(stats.pfxs), "pfxs")
+			b.ReportMetric(float64(stats.leaves), "leaf")
+			b.ReportMetric(float64(stats.fringes), "fringe")
+			b.ReportMetric(0, "ns/op")
+		})
+	}
+}
+
+// ##################### helpers ############################
+
+type tableOverlapsTest struct {
+	prefix string
+	want   bool
+}
+
+// checkOverlapsPrefix verifies that the overlaps lookups in tt return the
+// expected results on tbl.
+func checkOverlapsPrefix(t *testing.T, tbl *Table[int], tests []tableOverlapsTest) {
+	t.Helper()
+	for _, tt := range tests {
+		got := tbl.OverlapsPrefix(mpp(tt.prefix))
+		if got != tt.want {
+			t.Log(tbl.String())
+			t.Errorf("OverlapsPrefix(%v) = %v, want %v", mpp(tt.prefix), got, tt.want)
+		}
+	}
+}
+
+type tableTest struct {
+	// addr is an IP address string to look up in a route table.
+	addr string
+	// want is the expected >=0 value associated with the route, or -1
+	// if we expect a lookup miss.
+	want int
+}
+
+// checkRoutes verifies that the route lookups in tt return the
+// expected results on tbl.
+func checkRoutes(t *testing.T, tbl *Table[int], tt []tableTest) {
+	t.Helper()
+	for _, tc := range tt {
+		v, ok := tbl.Lookup(mpa(tc.addr))
+
+		if !ok && tc.want != -1 {
+			t.Errorf("Lookup %q got (%v, %v), want (%v, true)", tc.addr, v, ok, tc.want)
+		}
+		if ok && v != tc.want {
+			t.Errorf("Lookup %q got (%v, %v), want (%v, true)", tc.addr, v, ok, tc.want)
+		}
+	}
+}
+
+func checkNumNodes(t *testing.T, tbl *Table[int], want int) {
+	t.Helper()
+
+	s4 := tbl.root4.nodeStatsRec()
+	s6 := tbl.root6.nodeStatsRec()
+	nodes := s4.nodes + s6.nodes
+
+	if got := nodes; got != want {
+		t.Errorf("wrong table dump, got %d nodes want %d", got, want)
+		t.Error(tbl.dumpString())
+	}
+}
+
+// dumpAsGoldTable, just a helper to compare with golden table.
+func (t *Table[V]) dumpAsGoldTable() goldTable[V] {
+	var tbl goldTable[V]
+
+	t.AllSorted()(func(p netip.Prefix, v V) bool {
+		tbl = append(tbl, goldTableItem[V]{pfx: p, val: v})
+		return true
+	})
+
+	return tbl
+}
+
+func TestIsSubsetOf(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	eq := func(a, b int) bool { return a == b }
+
+	pfxs := randomPrefixes(prng, 2_000)
+
+	sup := new(Table[int])
+	for _, pfx := range pfxs {
+		sup.Insert(pfx.pfx, pfx.val)
+	}
+
+	sub := new(Table[int])
+	for _, pfx := range pfxs[:1_000] {
+		sub.Insert(pfx.pfx, pfx.val)
+	}
+
+	if !sub.IsSubsetOf(sup, eq) {
+		t.Errorf("IsSubsetOf, sub of sup: false, want true")
+	}
+	if sup.IsSubsetOf(sub, eq) {
+		t.Errorf("IsSubsetOf, sup of sub: true, want false")
+	}
+	if !sup.IsSubsetOf(sup, eq) {
+		t.Errorf("IsSubsetOf, sup of itself: false, want true")
+	}
+
+	// empty and nil tables
+	var nilTbl *Table[int]
+	if !new(Table[int]).IsSubsetOf(sub, eq) || !nilTbl.IsSubsetOf(nil, eq) {
+		t.Errorf("IsSubsetOf, empty table: false, want true")
+	}
+	if sub.IsSubsetOf(nil, eq) {
+		t.Errorf("IsSubsetOf, of nil table: true, want false")
+	}
+
+	// mismatched value
+	sub.Insert(pfxs[0].pfx, pfxs[0].val+1)
+	if sub.IsSubsetOf(sup, eq) {
+		t.Errorf("IsSubsetOf, mismatched value: true, want false")
+	}
+
+	// eq decides
+	if !sub.IsSubsetOf(sup, func(_, _ int) bool { return true }) {
+		t.Errorf("IsSubsetOf, eq always true: false, want true")
+	}
+}
+
+func TestInsertIfAbsent(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+
+	if !tbl.InsertIfAbsent(netip.MustParsePrefix("10.1.2.3/8"), 1) {
+		t.Errorf("InsertIfAbsent, new prefix: false, want true")
+	}
+	if tbl.InsertIfAbsent(mpp("10.0.0.0/8"), 2) {
+		t.Errorf("InsertIfAbsent, existing prefix: true, want false")
+	}
+	if val, _ := tbl.Get(mpp("10.0.0.0/8")); val != 1 {
+		t.Errorf("InsertIfAbsent, value overwritten: %d, want 1", val)
+	}
+
+	// leaves and fringes
+	for _, pfx := range []netip.Prefix{mpp("10.1.0.0/16"), mpp("10.1.2.3/32"), mpp("2001:db8::/37")} {
+		if !tbl.InsertIfAbsent(pfx, 3) || tbl.InsertIfAbsent(pfx, 4) {
+			t.Errorf("InsertIfAbsent(%s), wrong insert decision", pfx)
+		}
+		if val, _ := tbl.Get(pfx); val != 3 {
+			t.Errorf("InsertIfAbsent(%s), value: %d, want 3", pfx, val)
+		}
+	}
+
+	if tbl.Size() != 4 {
+		t.Errorf("InsertIfAbsent, Size() = %d, want 4", tbl.Size())
+	}
+
+	if tbl.InsertIfAbsent(netip.Prefix{}, 1) {
+		t.Errorf("InsertIfAbsent, invalid prefix: true, want false")
+	}
+}
+
+func TestCountWhere(t *testing.T) {
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for _, pfx := range randomPrefixes(prng, 5_000) {
+		tbl.Insert(pfx.pfx, pfx.val%7)
+	}
+
+	for nh := 0; nh < 7; nh++ {
+		want := 0
+		tbl.All()(func(_ netip.Prefix, val int) bool {
+			if val == nh {
+				want++
+			}
+			return true
+		})
+
+		if got := tbl.CountWhere(func(_ netip.Prefix, val int) bool { return val == nh }); got != want {
+			t.Errorf("CountWhere(val == %d) = %d, want %d", nh, got, want)
+		}
+	}
+
+	is4 := func(pfx netip.Prefix, _ int) bool { return pfx.Addr().Is4() }
+	if got := tbl.CountWhere(is4); got != tbl.Size4() {
+		t.Errorf("CountWhere(is4) = %d, want %d", got, tbl.Size4())
+	}
 
-			s4 := rt.root4.nodeStatsRec()
-			s6 := rt.root6.nodeStatsRec()
-			stats := stats{
-				s4.pfxs + s6.pfxs,
-				s4.childs + s6.childs,
-				s4.nodes + s6.nodes,
-				s4.leaves + s6.leaves,
-				s4.fringes + s6.fringes,
-			}
+	allocs := testing.AllocsPerRun(10, func() { tbl.CountWhere(is4) })
+	if allocs != 0 {
+		t.Errorf("CountWhere, allocs: %v, want 0", allocs)
+	}
 
-			b.ReportMetric(float64(int(endMem.HeapAlloc-startMem.HeapAlloc)/k), "bytes/pfx")
-			b.ReportMetric(float64(stats.nodes), "node")
-			b.ReportMetric(float64(stats.pfxs), "pfxs")
-			b.ReportMetric(float64(stats.leaves), "leaf")
-			b.ReportMetric(float64(stats.fringes), "fringe")
-			b.ReportMetric(0, "ns/op")
-		})
+	var nilTbl *Table[int]
+	if got := nilTbl.CountWhere(is4); got != 0 {
+		t.Errorf("CountWhere on nil table = %d, want 0", got)
 	}
 }
 
-// ##################### helpers ############################
+func TestLookupProfiled(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
 
-type tableOverlapsTest struct {
-	prefix string
-	want   bool
+	tbl := new(Table[int])
+	for _, pfx := range randomPrefixes(prng, 5_000) {
+		tbl.Insert(pfx.pfx, pfx.val)
+	}
+
+	for i := 0; i < 10_000; i++ {
+		ip := randomAddr(prng)
+		wantVal, wantOK := tbl.Lookup(ip)
+		gotVal, gotOK, steps := tbl.LookupProfiled(ip)
+
+		if gotVal != wantVal || gotOK != wantOK {
+			t.Fatalf("LookupProfiled(%s) = (%d, %v), want (%d, %v)", ip, gotVal, gotOK, wantVal, wantOK)
+		}
+
+		// at least the root node, at most down and up again
+		if steps < 1 || steps > 2*len(ip.AsSlice()) {
+			t.Fatalf("LookupProfiled(%s), steps: %d out of range", ip, steps)
+		}
+	}
+
+	// fixed example
+	tbl = new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Insert(mpp("10.1.0.0/16"), 2)
+	tbl.Insert(mpp("10.1.2.0/23"), 3)
+
+	tests := []struct {
+		ip    netip.Addr
+		val   int
+		ok    bool
+		steps int
+	}{
+		{mpa("11.0.0.1"), 0, false, 1}, // root, no prefixes to backtrack
+		{mpa("10.2.0.1"), 1, true, 3},  // root, 10, backtrack in 10
+		{mpa("10.1.0.1"), 2, true, 4},  // root, 10, 10.1, backtrack in 10.1
+		{mpa("10.1.3.1"), 3, true, 4},  // root, 10, 10.1, backtrack in 10.1
+	}
+
+	for _, tt := range tests {
+		val, ok, steps := tbl.LookupProfiled(tt.ip)
+		if val != tt.val || ok != tt.ok || steps != tt.steps {
+			t.Errorf("LookupProfiled(%s) = (%d, %v, %d), want (%d, %v, %d)",
+				tt.ip, val, ok, steps, tt.val, tt.ok, tt.steps)
+		}
+	}
 }
 
-// checkOverlapsPrefix verifies that the overlaps lookups in tt return the
-// expected results on tbl.
-func checkOverlapsPrefix(t *testing.T, tbl *Table[int], tests []tableOverlapsTest) {
-	t.Helper()
+func TestAllCtx(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for _, pfx := range randomPrefixes(prng, 10_000) {
+		tbl.Insert(pfx.pfx, pfx.val)
+	}
+
+	// complete walk
+	n := 0
+	if err := tbl.AllCtx(context.Background(), func(netip.Prefix, int) bool {
+		n++
+		return true
+	}); err != nil || n != tbl.Size() {
+		t.Errorf("AllCtx, (%d, %v), want (%d, nil)", n, err, tbl.Size())
+	}
+
+	// early exit by yield
+	n = 0
+	if err := tbl.AllCtx(context.Background(), func(netip.Prefix, int) bool {
+		n++
+		return n < 10
+	}); err != nil || n != 10 {
+		t.Errorf("AllCtx, early exit, (%d, %v), want (10, nil)", n, err)
+	}
+
+	// canceled during the walk
+	ctx, cancel := context.WithCancel(context.Background())
+	n = 0
+	err := tbl.AllCtx(ctx, func(netip.Prefix, int) bool {
+		if n++; n == 100 {
+			cancel()
+		}
+		return true
+	})
+	if !errors.Is(err, context.Canceled) || n >= tbl.Size() {
+		t.Errorf("AllCtx, canceled, (%d, %v), want (<%d, %v)", n, err, tbl.Size(), context.Canceled)
+	}
+
+	// canceled before the walk
+	n = 0
+	if err := tbl.AllCtx(ctx, func(netip.Prefix, int) bool {
+		n++
+		return true
+	}); !errors.Is(err, context.Canceled) || n != 0 {
+		t.Errorf("AllCtx, canceled before, (%d, %v), want (0, %v)", n, err, context.Canceled)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	if got := new(Table[int]).Similarity(new(Table[int])); got != 1.0 {
+		t.Errorf("Similarity, empty tables: %v, want 1", got)
+	}
+
+	pfxs := randomPrefixes(prng, 3_000)
+
+	a := new(Table[int])
+	b := new(Table[int])
+	for _, pfx := range pfxs[:2_000] {
+		a.Insert(pfx.pfx, 1)
+	}
+	for _, pfx := range pfxs[1_000:] {
+		b.Insert(pfx.pfx, 2) // values are ignored
+	}
+
+	// 1000 common out of 3000
+	want := 1.0 / 3.0
+	if got := a.Similarity(b); got != want {
+		t.Errorf("Similarity: %v, want %v", got, want)
+	}
+	if got := b.Similarity(a); got != want {
+		t.Errorf("Similarity, symmetric: %v, want %v", got, want)
+	}
+
+	if got := a.Similarity(a); got != 1.0 {
+		t.Errorf("Similarity, same table: %v, want 1", got)
+	}
+	if got := a.Similarity(new(Table[int])); got != 0.0 {
+		t.Errorf("Similarity, with empty table: %v, want 0", got)
+	}
+}
+
+func TestInsertScoped(t *testing.T) {
+	t.Parallel()
+
+	allowed := mpp("10.1.0.0/16")
+
+	tests := []struct {
+		pfx     netip.Prefix
+		allowed netip.Prefix
+		scope   bool // error is ErrOutOfScope
+		ok      bool
+	}{
+		{mpp("10.1.0.0/16"), allowed, false, true},
+		{mpp("10.1.2.0/24"), allowed, false, true},
+		{netip.MustParsePrefix("10.1.3.3/24"), netip.MustParsePrefix("10.1.7.7/16"), false, true},
+		{mpp("10.1.2.3/32"), allowed, false, true},
+		{mpp("10.0.0.0/8"), allowed, true, false},
+		{mpp("10.2.0.0/24"), allowed, true, false},
+		{mpp("::ffff:10.1.2.0/120"), allowed, true, false},
+		{mpp("2001:db8::/32"), mpp("2001:db8::/32"), false, true},
+		{netip.Prefix{}, allowed, false, false},
+		{mpp("10.1.2.0/24"), netip.Prefix{}, false, false},
+	}
+
+	tbl := new(Table[int])
+	want := 0
+
 	for _, tt := range tests {
-		got := tbl.OverlapsPrefix(mpp(tt.prefix))
-		if got != tt.want {
-			t.Log(tbl.String())
-			t.Errorf("OverlapsPrefix(%v) = %v, want %v", mpp(tt.prefix), got, tt.want)
+		err := tbl.InsertScoped(tt.pfx, 1, tt.allowed)
+
+		if (err == nil) != tt.ok || errors.Is(err, ErrOutOfScope) != tt.scope {
+			t.Errorf("InsertScoped(%s, %s), err: %v", tt.pfx, tt.allowed, err)
+		}
+
+		if tt.ok {
+			want++
+		}
+		if tbl.Size() != want {
+			t.Errorf("InsertScoped(%s, %s), Size() = %d, want %d", tt.pfx, tt.allowed, tbl.Size(), want)
 		}
 	}
 }
 
-type tableTest struct {
-	// addr is an IP address string to look up in a route table.
-	addr string
-	// want is the expected >=0 value associated with the route, or -1
-	// if we expect a lookup miss.
-	want int
+func TestGetPtr(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 5_000)
+
+	tbl := new(Table[int])
+	for _, item := range pfxs {
+		tbl.Insert(item.pfx, item.val)
+	}
+
+	// edit all values in place, prefixes, leaves and fringes
+	for _, item := range pfxs {
+		p := tbl.GetPtr(item.pfx)
+		if p == nil || *p != item.val {
+			t.Fatalf("GetPtr(%s), wrong pointer", item.pfx)
+		}
+		*p = -item.val
+	}
+
+	for _, item := range pfxs {
+		if val, ok := tbl.Get(item.pfx); !ok || val != -item.val {
+			t.Fatalf("GetPtr(%s), in-place edit lost, got (%d, %v), want (%d, true)", item.pfx, val, ok, -item.val)
+		}
+	}
+
+	if p := tbl.GetPtr(mpp("0.0.0.0/0")); p != nil && !tbl.Has(mpp("0.0.0.0/0")) {
+		t.Errorf("GetPtr, missing prefix, got non-nil pointer")
+	}
+	if p := tbl.GetPtr(netip.Prefix{}); p != nil {
+		t.Errorf("GetPtr, invalid prefix, got non-nil pointer")
+	}
+
+	// lazy clone, the edit must not leak into the clone
+	src := new(Table[int])
+	src.Insert(mpp("10.0.0.0/8"), 1)
+	clone := src.Clone()
+
+	*src.GetPtr(mpp("10.0.0.0/8")) = 2
+	if val, _ := clone.Get(mpp("10.0.0.0/8")); val != 1 {
+		t.Errorf("GetPtr, edit leaked into lazy clone, got %d, want 1", val)
+	}
 }
 
-// checkRoutes verifies that the route lookups in tt return the
-// expected results on tbl.
-func checkRoutes(t *testing.T, tbl *Table[int], tt []tableTest) {
-	t.Helper()
-	for _, tc := range tt {
-		v, ok := tbl.Lookup(mpa(tc.addr))
+func TestLookupEx(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
 
-		if !ok && tc.want != -1 {
-			t.Errorf("Lookup %q got (%v, %v), want (%v, true)", tc.addr, v, ok, tc.want)
+	tbl := new(Table[int])
+	for _, pfx := range randomPrefixes(prng, 5_000) {
+		tbl.Insert(pfx.pfx, pfx.val)
+	}
+
+	for i := 0; i < 10_000; i++ {
+		ip := randomAddr(prng)
+		lpmPfx, wantVal, wantOK := tbl.LookupPrefixLPM(netip.PrefixFrom(ip, ip.BitLen()))
+		gotVal, bits, depth, gotOK := tbl.LookupEx(ip)
+
+		if gotVal != wantVal || gotOK != wantOK {
+			t.Fatalf("LookupEx(%s) = (%d, %v), want (%d, %v)", ip, gotVal, gotOK, wantVal, wantOK)
 		}
-		if ok && v != tc.want {
-			t.Errorf("Lookup %q got (%v, %v), want (%v, true)", tc.addr, v, ok, tc.want)
+		if !gotOK {
+			continue
+		}
+		if bits != lpmPfx.Bits() {
+			t.Fatalf("LookupEx(%s), bits: %d, want %d", ip, bits, lpmPfx.Bits())
+		}
+		// stride prefix in [depth*8, depth*8+7] or leaf and fringe below
+		if depth < 0 || bits < depth<<3 {
+			t.Fatalf("LookupEx(%s), bits: %d, depth %d out of range", ip, bits, depth)
+		}
+	}
+
+	// fixed example
+	tbl = new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)    // fringe in root, pushed down by the next prefix
+	tbl.Insert(mpp("10.1.0.0/16"), 2)   // fringe in node 10
+	tbl.Insert(mpp("10.2.0.0/20"), 3)   // leaf in node 10
+	tbl.Insert(mpp("192.0.0.0/2"), 4)   // prefix in root
+	tbl.Insert(mpp("172.16.0.0/12"), 5) // leaf in root
+
+	tests := []struct {
+		ip    netip.Addr
+		val   int
+		bits  int
+		depth int
+		ok    bool
+	}{
+		{mpa("10.9.0.1"), 1, 8, 1, true},
+		{mpa("10.1.0.1"), 2, 16, 1, true},
+		{mpa("10.2.0.1"), 3, 20, 1, true},
+		{mpa("192.168.0.1"), 4, 2, 0, true},
+		{mpa("172.16.0.1"), 5, 12, 0, true},
+		{mpa("11.0.0.1"), 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		val, bits, depth, ok := tbl.LookupEx(tt.ip)
+		if val != tt.val || bits != tt.bits || depth != tt.depth || ok != tt.ok {
+			t.Errorf("LookupEx(%s) = (%d, %d, %d, %v), want (%d, %d, %d, %v)",
+				tt.ip, val, bits, depth, ok, tt.val, tt.bits, tt.depth, tt.ok)
 		}
 	}
 }
 
-func checkNumNodes(t *testing.T, tbl *Table[int], want int) {
-	t.Helper()
+func TestNearestSupernet(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	tbl.Insert(mpp("0.0.0.0/0"), 0)
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Insert(mpp("10.1.0.0/20"), 2)
+	tbl.Insert(mpp("2001:db8::/32"), 3)
+
+	tests := []struct {
+		ip        netip.Addr
+		pfx       netip.Prefix
+		val       int
+		isDefault bool
+		ok        bool
+	}{
+		{mpa("10.1.0.1"), mpp("10.1.0.0/20"), 2, false, true},
+		{mpa("10.9.0.1"), mpp("10.0.0.0/8"), 1, false, true},
+		{mpa("11.0.0.1"), mpp("0.0.0.0/0"), 0, true, true},
+		{mpa("2001:db8::1"), mpp("2001:db8::/32"), 3, false, true},
+		{mpa("2001:db9::1"), netip.Prefix{}, 0, false, false},
+		{netip.Addr{}, netip.Prefix{}, 0, false, false},
+	}
+
+	for _, tt := range tests {
+		pfx, val, isDefault, ok := tbl.NearestSupernet(tt.ip)
+		if pfx != tt.pfx || val != tt.val || isDefault != tt.isDefault || ok != tt.ok {
+			t.Errorf("NearestSupernet(%s) = (%s, %d, %v, %v), want (%s, %d, %v, %v)",
+				tt.ip, pfx, val, isDefault, ok, tt.pfx, tt.val, tt.isDefault, tt.ok)
+		}
+	}
+}
+func TestSetAll(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 5_000)
+
+	tbl := new(Table[int])
+	for _, item := range pfxs {
+		tbl.Insert(item.pfx, item.val)
+	}
+	before := tbl.dumpString()
+	clone := tbl.Clone()
+
+	tbl.SetAll(42)
+
+	if got := tbl.CountWhere(func(_ netip.Prefix, val int) bool { return val == 42 }); got != len(pfxs) {
+		t.Errorf("SetAll, %d values set, want %d", got, len(pfxs))
+	}
+
+	// the lazy clone is untouched
+	if got := clone.dumpString(); got != before {
+		t.Errorf("SetAll, clone modified")
+	}
+
+	// with observer
+	var events int
+	clone.OnChange(func(op ChangeOp, _ netip.Prefix, val int) {
+		if op == ChangeUpdate && val == 7 {
+			events++
+		}
+	})
+	clone.SetAll(7)
+	if events != len(pfxs) {
+		t.Errorf("SetAll, observer events: %d, want %d", events, len(pfxs))
+	}
+}
+
+func TestCompressionRatio(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	if got := tbl.CompressionRatio(); got != 0 {
+		t.Errorf("CompressionRatio, empty table: %v, want 0", got)
+	}
+
+	// root node with two leaves and one fringe
+	tbl.Insert(mpp("10.1.0.0/16"), 1)
+	tbl.Insert(mpp("11.1.0.0/16"), 1)
+	tbl.Insert(mpp("12.0.0.0/8"), 1)
+	if got := tbl.CompressionRatio(); got != 3 {
+		t.Errorf("CompressionRatio: %v, want 3", got)
+	}
+
+	// push the leaf 10.1.0.0/16 down into a new node
+	tbl.Insert(mpp("10.2.0.0/16"), 1)
+	if got := tbl.CompressionRatio(); got != 2 {
+		t.Errorf("CompressionRatio, after push down: %v, want 2", got)
+	}
+}
+
+func TestLeaves(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	tbl := new(Table[int])
+	for _, item := range randomPrefixes(prng, 5_000) {
+		tbl.Insert(item.pfx, item.val)
+	}
 
 	s4 := tbl.root4.nodeStatsRec()
 	s6 := tbl.root6.nodeStatsRec()
-	nodes := s4.nodes + s6.nodes
 
-	if got := nodes; got != want {
-		t.Errorf("wrong table dump, got %d nodes want %d", got, want)
-		t.Error(tbl.dumpString())
+	n := 0
+	tbl.Leaves()(func(pfx netip.Prefix, val int) bool {
+		if got, ok := tbl.Get(pfx); !ok || got != val {
+			t.Fatalf("Leaves, %s: (%d, %v), want (%d, true)", pfx, got, ok, val)
+		}
+		n++
+		return true
+	})
+
+	if want := s4.leaves + s4.fringes + s6.leaves + s6.fringes; n != want {
+		t.Errorf("Leaves, got %d entries, want %d", n, want)
 	}
-}
 
-// dumpAsGoldTable, just a helper to compare with golden table.
-func (t *Table[V]) dumpAsGoldTable() goldTable[V] {
-	var tbl goldTable[V]
+	// fixed example, 10.0.0.0/8 is pushed down into a node as stride prefix
+	tbl = new(Table[int])
+	tbl.Insert(mpp("10.0.0.0/8"), 1)
+	tbl.Insert(mpp("10.1.0.0/16"), 2)
+	tbl.Insert(mpp("192.0.0.0/2"), 3)
 
-	t.AllSorted()(func(p netip.Prefix, v V) bool {
-		tbl = append(tbl, goldTableItem[V]{pfx: p, val: v})
+	var got []netip.Prefix
+	tbl.Leaves()(func(pfx netip.Prefix, _ int) bool {
+		got = append(got, pfx)
 		return true
 	})
 
-	return tbl
+	if want := []netip.Prefix{mpp("10.1.0.0/16")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Leaves, got %v, want %v", got, want)
+	}
+}
+
+func TestInsertCanonical(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pfx netip.Prefix
+		ok  bool
+	}{
+		{mpp("10.0.0.0/24"), true},
+		{netip.MustParsePrefix("10.0.0.5/24"), false},
+		{mpp("0.0.0.0/0"), true},
+		{netip.MustParsePrefix("1.2.3.4/0"), false},
+		{mpp("2001:db8::/32"), true},
+		{netip.MustParsePrefix("2001:db8::1/64"), false},
+		{mpp("::1/128"), true},
+		{mpp("::ffff:10.0.0.0/104"), true},
+		{netip.MustParsePrefix("::ffff:10.1.2.3/104"), false},
+		{netip.Prefix{}, false},
+	}
+
+	tbl := new(Table[int])
+	want := 0
+
+	for _, tt := range tests {
+		err := tbl.InsertCanonical(tt.pfx, 1)
+		if (err == nil) != tt.ok {
+			t.Errorf("InsertCanonical(%s), err: %v, want ok: %v", tt.pfx, err, tt.ok)
+		}
+
+		if tt.ok {
+			want++
+		}
+		if tbl.Size() != want {
+			t.Errorf("InsertCanonical(%s), Size() = %d, want %d", tt.pfx, tbl.Size(), want)
+		}
+	}
+}
+
+func TestChildrenOf(t *testing.T) {
+	t.Parallel()
+
+	tbl := new(Table[int])
+	for i, s := range []string{
+		"10.0.0.0/8",
+		"10.0.0.0/16",
+		"10.0.1.0/24",
+		"10.1.0.0/16",
+		"10.2.3.0/24",
+		"10.2.3.128/25",
+		"11.0.0.0/8",
+	} {
+		tbl.Insert(mpp(s), i)
+	}
+
+	collect := func(pfx netip.Prefix) (got []string) {
+		tbl.ChildrenOf(pfx)(func(sub netip.Prefix, _ int) bool {
+			got = append(got, sub.String())
+			return true
+		})
+		return got
+	}
+
+	tests := []struct {
+		pfx  netip.Prefix
+		want []string
+	}{
+		{mpp("10.0.0.0/8"), []string{"10.0.0.0/16", "10.1.0.0/16", "10.2.3.0/24"}},
+		{mpp("10.0.0.0/16"), []string{"10.0.1.0/24"}},
+		{mpp("0.0.0.0/0"), []string{"10.0.0.0/8", "11.0.0.0/8"}}, // not stored
+		{mpp("10.2.0.0/15"), []string{"10.2.3.0/24"}},
+		{mpp("10.2.3.128/25"), nil},
+		{netip.Prefix{}, nil},
+	}
+
+	for _, tt := range tests {
+		if got := collect(tt.pfx); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ChildrenOf(%s) = %v, want %v", tt.pfx, got, tt.want)
+		}
+	}
 }
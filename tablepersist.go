@@ -38,9 +38,15 @@ func (t *Table[V]) InsertPersist(pfx netip.Prefix, val V) *Table[V] {
 
 	// share size counters; root nodes cloned selectively.
 	pt := &Table[V]{
-		size4: t.size4,
-		size6: t.size6,
+		size4:    t.size4,
+		size6:    t.size6,
+		filter:   t.filter.clone(),
+		intern:   t.intern,
+		fallback: t.fallback,
 	}
+	pt.shared.Store(t.shared.Load())
+	pt.filter.add(pfx)
+	val = pt.internVal(val)
 
 	// Pointer to the root node we will modify in this operation.
 	var n *node[V]
@@ -151,8 +157,7 @@ func (t *Table[V]) InsertPersist(pfx netip.Prefix, val V) *Table[V] {
 		}
 	}
 
-	// Should never happen: traversal always returns or panics inside loop.
-	panic("unreachable")
+	panic(malformedPrefix("InsertPersist", pfx, 0))
 }
 
 // UpdatePersist is similar to Update but does not modify the receiver.
@@ -183,9 +188,15 @@ func (t *Table[V]) UpdatePersist(pfx netip.Prefix, cb func(val V, ok bool) V) (p
 
 	// share size counters; root nodes cloned selectively.
 	pt = &Table[V]{
-		size4: t.size4,
-		size6: t.size6,
+		size4:    t.size4,
+		size6:    t.size6,
+		filter:   t.filter.clone(),
+		intern:   t.intern,
+		fallback: t.fallback,
 	}
+	pt.shared.Store(t.shared.Load())
+	pt.filter.add(pfx)
+	cb = pt.internCb(cb)
 
 	// Pointer to the root node we will modify in this operation.
 	var n *node[V]
@@ -300,7 +311,7 @@ func (t *Table[V]) UpdatePersist(pfx netip.Prefix, cb func(val V, ok bool) V) (p
 	}
 
 	// Should never reach here: the loop should always return or panic.
-	panic("unreachable")
+	panic(malformedPrefix("UpdatePersist", pfx, 0))
 }
 
 // DeletePersist is similar to Delete but does not modify the receiver.
@@ -349,9 +360,13 @@ func (t *Table[V]) getAndDeletePersist(pfx netip.Prefix) (pt *Table[V], val V, e
 
 	// share size counters; root nodes cloned selectively.
 	pt = &Table[V]{
-		size4: t.size4,
-		size6: t.size6,
+		size4:    t.size4,
+		size6:    t.size6,
+		filter:   t.filter.clone(),
+		intern:   t.intern,
+		fallback: t.fallback,
 	}
+	pt.shared.Store(t.shared.Load())
 
 	// Pointer to the root node we will modify in this operation.
 	var n *node[V]
@@ -484,9 +499,13 @@ func (t *Table[V]) UnionPersist(o *Table[V]) *Table[V] {
 		root4: t.root4,
 		root6: t.root6,
 		//
-		size4: t.size4,
-		size6: t.size6,
+		size4:    t.size4,
+		size6:    t.size6,
+		filter:   t.filter.clone(),
+		intern:   t.intern,
+		fallback: t.fallback,
 	}
+	pt.shared.Store(t.shared.Load())
 
 	// only clone the root node if there is something to union
 	if o.size4 != 0 {
@@ -499,6 +518,7 @@ func (t *Table[V]) UnionPersist(o *Table[V]) *Table[V] {
 	if cloneFn == nil {
 		cloneFn = copyVal[V]
 	}
+	cloneFn = pt.internCloneFn(cloneFn)
 
 	dup4 := pt.root4.unionRecPersist(cloneFn, &o.root4, 0)
 	dup6 := pt.root6.unionRecPersist(cloneFn, &o.root6, 0)
@@ -506,5 +526,7 @@ func (t *Table[V]) UnionPersist(o *Table[V]) *Table[V] {
 	pt.size4 += o.size4 - dup4
 	pt.size6 += o.size6 - dup6
 
+	pt.missFilterUnion(o)
+
 	return pt
 }
@@ -1,11 +1,20 @@
 package bart
 
+import "net/netip"
+
+// mergeFunc combines the values of a duplicate prefix in a union, a from
+// the receiver and b, already cloned, from the other table.
+// With a nil mergeFunc the value from the other table overwrites a.
+type mergeFunc[V any] func(pfx netip.Prefix, a, b V) V
+
 // unionRec recursively merges another node o into the receiver node n.
 //
 // All prefix and child entries from o are cloned and inserted into n.
 // If a prefix already exists in n, its value is overwritten by the value from o,
-// and the duplicate is counted in the return value. This count can later be used
-// to update size-related metadata in the parent trie.
+// or combined with it by merge if not nil, and the duplicate is counted in the
+// return value. This count can later be used to update size-related metadata
+// in the parent trie. The path and is4 of n are only needed to build the
+// duplicate prefixes for merge.
 //
 // The union handles all possible combinations of child node types (node, leaf, fringe)
 // between the two nodes. Structural conflicts are resolved by creating new intermediate
@@ -15,14 +24,14 @@ package bart
 // The merge operation is destructive on the receiver n, but leaves the source node o unchanged.
 //
 // Returns the number of duplicate prefixes that were overwritten during merging.
-func (n *node[V]) unionRec(cloneFn cloneFunc[V], o *node[V], depth int) (duplicates int) {
+func (n *node[V]) unionRec(cloneFn cloneFunc[V], merge mergeFunc[V], o *node[V], path stridePath, depth int, is4 bool) (duplicates int) {
 	// for all prefixes in other node do ...
 	for i, oIdx := range o.prefixes.AsSlice(&[256]uint8{}) {
 		// clone/copy the value from other node at idx
 		clonedVal := cloneFn(o.prefixes.Items[i])
 
 		// insert/overwrite cloned value from o into n
-		if n.prefixes.InsertAt(oIdx, clonedVal) {
+		if n.mergeAt(oIdx, clonedVal, merge, path, depth, is4) {
 			// this prefix is duplicate in n and o
 			duplicates++
 		}
@@ -71,18 +80,21 @@ func (n *node[V]) unionRec(cloneFn cloneFunc[V], o *node[V], depth int) (duplica
 			}
 		}
 
+		// the path of the kids at addr
+		path[depth] = addr
+
 		switch thisKid := thisChild.(type) {
 		case *node[V]: // node, ...
 			switch otherKid := o.children.Items[i].(type) {
 			case *node[V]: // node, node
 				// both childs have node at addr, call union rec-descent on child nodes
-				duplicates += thisKid.unionRec(cloneFn, otherKid.cloneRec(cloneFn), depth+1)
+				duplicates += thisKid.unionRec(cloneFn, merge, otherKid.cloneRec(cloneFn), path, depth+1, is4)
 				continue
 
 			case *leafNode[V]: // node, leaf
 				// push this cloned leaf down, count duplicate entry
 				clonedLeaf := otherKid.cloneLeaf(cloneFn)
-				if thisKid.insertAtDepth(clonedLeaf.prefix, clonedLeaf.value, depth+1) {
+				if thisKid.mergeAtDepth(clonedLeaf.prefix, clonedLeaf.value, depth+1, merge) {
 					duplicates++
 				}
 				continue
@@ -90,7 +102,7 @@ func (n *node[V]) unionRec(cloneFn cloneFunc[V], o *node[V], depth int) (duplica
 			case *fringeNode[V]: // node, fringe
 				// push this fringe down, a fringe becomes a default route one level down
 				clonedFringe := otherKid.cloneFringe(cloneFn)
-				if thisKid.prefixes.InsertAt(1, clonedFringe.value) {
+				if thisKid.mergeAt(1, clonedFringe.value, merge, path, depth+1, is4) {
 					duplicates++
 				}
 				continue
@@ -109,13 +121,17 @@ func (n *node[V]) unionRec(cloneFn cloneFunc[V], o *node[V], depth int) (duplica
 				n.children.InsertAt(addr, nc)
 
 				// unionRec this new node with other kid node
-				duplicates += nc.unionRec(cloneFn, otherKid.cloneRec(cloneFn), depth+1)
+				duplicates += nc.unionRec(cloneFn, merge, otherKid.cloneRec(cloneFn), path, depth+1, is4)
 				continue
 
 			case *leafNode[V]: // leaf, leaf
 				// shortcut, prefixes are equal
 				if thisKid.prefix == otherKid.prefix {
-					thisKid.value = cloneFn(otherKid.value)
+					clonedVal := cloneFn(otherKid.value)
+					if merge != nil {
+						clonedVal = merge(thisKid.prefix, thisKid.value, clonedVal)
+					}
+					thisKid.value = clonedVal
 					duplicates++
 					continue
 				}
@@ -128,7 +144,7 @@ func (n *node[V]) unionRec(cloneFn cloneFunc[V], o *node[V], depth int) (duplica
 
 				// insert at depth cloned leaf, maybe duplicate
 				clonedLeaf := otherKid.cloneLeaf(cloneFn)
-				if nc.insertAtDepth(clonedLeaf.prefix, clonedLeaf.value, depth+1) {
+				if nc.mergeAtDepth(clonedLeaf.prefix, clonedLeaf.value, depth+1, merge) {
 					duplicates++
 				}
 
@@ -167,7 +183,7 @@ func (n *node[V]) unionRec(cloneFn cloneFunc[V], o *node[V], depth int) (duplica
 				n.children.InsertAt(addr, nc)
 
 				// unionRec this new node with other kid node
-				duplicates += nc.unionRec(cloneFn, otherKid.cloneRec(cloneFn), depth+1)
+				duplicates += nc.unionRec(cloneFn, merge, otherKid.cloneRec(cloneFn), path, depth+1, is4)
 				continue
 
 			case *leafNode[V]: // fringe, leaf
@@ -188,7 +204,11 @@ func (n *node[V]) unionRec(cloneFn cloneFunc[V], o *node[V], depth int) (duplica
 				continue
 
 			case *fringeNode[V]: // fringe, fringe
-				thisKid.value = otherKid.cloneFringe(cloneFn).value
+				clonedVal := otherKid.cloneFringe(cloneFn).value
+				if merge != nil {
+					clonedVal = merge(cidrForFringe(path[:], depth, is4, addr), thisKid.value, clonedVal)
+				}
+				thisKid.value = clonedVal
 				duplicates++
 				continue
 			}
@@ -201,6 +221,22 @@ func (n *node[V]) unionRec(cloneFn cloneFunc[V], o *node[V], depth int) (duplica
 	return duplicates
 }
 
+// mergeAt inserts val at idx into the prefixes of n, the node at depth on path.
+// An existing value is combined with val by merge, if not nil.
+func (n *node[V]) mergeAt(idx uint8, val V, merge mergeFunc[V], path stridePath, depth int, is4 bool) (exists bool) {
+	if merge == nil {
+		return n.prefixes.InsertAt(idx, val)
+	}
+
+	_, exists = n.prefixes.UpdateAt(idx, func(old V, ok bool) V {
+		if ok {
+			return merge(cidrFromPath(path, depth, is4, idx), old, val)
+		}
+		return val
+	})
+	return exists
+}
+
 // unionRecPersist is similar to unionRec but performs an immutable union of nodes.
 func (n *node[V]) unionRecPersist(cloneFn cloneFunc[V], o *node[V], depth int) (duplicates int) {
 	// for all prefixes in other node do ...
@@ -271,7 +307,7 @@ func (n *node[V]) unionRecPersist(cloneFn cloneFunc[V], o *node[V], depth int) (
 			switch otherKid := o.children.Items[i].(type) {
 			case *node[V]: // node, node
 				// both childs have node at addr, call union rec-descent on child nodes
-				duplicates += thisKid.unionRec(cloneFn, otherKid.cloneRec(cloneFn), depth+1)
+				duplicates += thisKid.unionRec(cloneFn, nil, otherKid.cloneRec(cloneFn), stridePath{}, depth+1, false)
 				continue
 
 			case *leafNode[V]: // node, leaf
@@ -304,7 +340,7 @@ func (n *node[V]) unionRecPersist(cloneFn cloneFunc[V], o *node[V], depth int) (
 				n.children.InsertAt(addr, nc)
 
 				// unionRec this new node with other kid node
-				duplicates += nc.unionRec(cloneFn, otherKid.cloneRec(cloneFn), depth+1)
+				duplicates += nc.unionRec(cloneFn, nil, otherKid.cloneRec(cloneFn), stridePath{}, depth+1, false)
 				continue
 
 			case *leafNode[V]: // leaf, leaf
@@ -362,7 +398,7 @@ func (n *node[V]) unionRecPersist(cloneFn cloneFunc[V], o *node[V], depth int) (
 				n.children.InsertAt(addr, nc)
 
 				// unionRec this new node with other kid node
-				duplicates += nc.unionRec(cloneFn, otherKid.cloneRec(cloneFn), depth+1)
+				duplicates += nc.unionRec(cloneFn, nil, otherKid.cloneRec(cloneFn), stridePath{}, depth+1, false)
 				continue
 
 			case *leafNode[V]: // fringe, leaf
@@ -395,3 +431,97 @@ func (n *node[V]) unionRecPersist(cloneFn cloneFunc[V], o *node[V], depth int) (
 
 	return duplicates
 }
+
+// absorbRec is similar to unionRec but moves the nodes, leaves and fringes
+// from o into n without cloning, o must not be used afterwards.
+//
+// Returns the number of duplicate prefixes that were overwritten during merging.
+func (n *node[V]) absorbRec(o *node[V], depth int) (duplicates int) {
+	// for all prefixes in other node do ...
+	for i, oIdx := range o.prefixes.AsSlice(&[256]uint8{}) {
+		// move value from o into n
+		if n.prefixes.InsertAt(oIdx, o.prefixes.Items[i]) {
+			// this prefix is duplicate in n and o
+			duplicates++
+		}
+	}
+
+	// for all child addrs in other node do ...
+	for i, addr := range o.children.AsSlice(&[256]uint8{}) {
+		otherChild := o.children.Items[i]
+
+		// try to get child at same addr from n
+		thisChild, thisExists := n.children.Get(addr)
+		if !thisExists {
+			// NULL, ... slot at addr is empty, just steal the other kid
+			n.children.InsertAt(addr, otherChild)
+			continue
+		}
+
+		switch thisKid := thisChild.(type) {
+		case *node[V]: // node, ...
+			duplicates += thisKid.absorbKid(otherChild, depth+1)
+
+		case *leafNode[V]: // leaf, ...
+			// shortcut, prefixes are equal
+			if otherKid, ok := otherChild.(*leafNode[V]); ok && thisKid.prefix == otherKid.prefix {
+				thisKid.value = otherKid.value
+				duplicates++
+				continue
+			}
+
+			// create new node, push this leaf down
+			nc := new(node[V])
+			nc.insertAtDepth(thisKid.prefix, thisKid.value, depth+1)
+			duplicates += nc.absorbKid(otherChild, depth+1)
+
+			// insert the new node at current addr
+			n.children.InsertAt(addr, nc)
+
+		case *fringeNode[V]: // fringe, ...
+			// shortcut, both are fringes
+			if otherKid, ok := otherChild.(*fringeNode[V]); ok {
+				thisKid.value = otherKid.value
+				duplicates++
+				continue
+			}
+
+			// create new node, push this fringe down, it becomes the default route
+			nc := new(node[V])
+			nc.prefixes.InsertAt(1, thisKid.value)
+			duplicates += nc.absorbKid(otherChild, depth+1)
+
+			// insert the new node at current addr
+			n.children.InsertAt(addr, nc)
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	return duplicates
+}
+
+// absorbKid moves the other kid into node n at depth.
+func (n *node[V]) absorbKid(otherChild any, depth int) (duplicates int) {
+	switch otherKid := otherChild.(type) {
+	case *node[V]: // node, node
+		return n.absorbRec(otherKid, depth)
+
+	case *leafNode[V]: // node, leaf
+		if n.insertAtDepth(otherKid.prefix, otherKid.value, depth) {
+			return 1
+		}
+		return 0
+
+	case *fringeNode[V]: // node, fringe
+		// a fringe becomes a default route one level down
+		if n.prefixes.InsertAt(1, otherKid.value) {
+			return 1
+		}
+		return 0
+
+	default:
+		panic("logic error, wrong node type")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import "net/netip"
+
+// Updater batches inserts and deletes for a table with concurrent
+// readers, created by [Table.BeginUpdate]. Commit applies the batch
+// copy-on-write and returns the new version of the table.
+//
+// Only the trie nodes along the paths of the modified prefixes are copied,
+// all untouched nodes are shared between the old and the new version, see
+// [Table.InsertPersist]. For small deltas on a huge table this needs much
+// less memory and time than a full clone-and-swap.
+//
+// The old version is never modified, readers continue on it without any
+// synchronization. Publish the new version for the readers with an atomic
+// pointer swap, see the concurrent example of Table. There is no epoch or
+// hazard pointer scheme, the memory reclamation is left to the garbage
+// collector: the nodes of an old version, not shared with the new one,
+// are freed as soon as the last reader drops its reference.
+//
+// The Updater itself is for a single writer, it's not safe for concurrent use.
+type Updater[V any] struct {
+	base *Table[V]
+	ops  []updateOp[V]
+}
+
+// updateOp is a batched insert or delete.
+type updateOp[V any] struct {
+	pfx    netip.Prefix
+	val    V
+	delete bool
+}
+
+// BeginUpdate returns an Updater for batched copy-on-write
+// modifications of the table, see [Updater].
+func (t *Table[V]) BeginUpdate() *Updater[V] {
+	if t == nil {
+		t = new(Table[V])
+	}
+
+	return &Updater[V]{base: t}
+}
+
+// Insert adds pfx with val to the batch.
+func (u *Updater[V]) Insert(pfx netip.Prefix, val V) {
+	u.ops = append(u.ops, updateOp[V]{pfx: pfx, val: val})
+}
+
+// Delete adds the removal of pfx to the batch.
+func (u *Updater[V]) Delete(pfx netip.Prefix) {
+	u.ops = append(u.ops, updateOp[V]{pfx: pfx, delete: true})
+}
+
+// Len returns the number of pending operations.
+func (u *Updater[V]) Len() int {
+	return len(u.ops)
+}
+
+// Commit applies the pending operations in order and returns the
+// new version of the table, the table of BeginUpdate is left unmodified.
+// Without pending operations, the current version itself is returned.
+//
+// The Updater stays usable, the next batch is based on the returned version.
+func (u *Updater[V]) Commit() *Table[V] {
+	pt := u.base
+
+	for _, op := range u.ops {
+		if op.delete {
+			pt = pt.DeletePersist(op.pfx)
+			continue
+		}
+		pt = pt.InsertPersist(op.pfx, op.val)
+	}
+
+	u.base = pt
+	u.ops = nil
+
+	return pt
+}
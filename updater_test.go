@@ -0,0 +1,111 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestUpdaterCommit(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	base := new(Table[int])
+	for _, pfx := range randomPrefixes(prng, 5_000) {
+		base.Insert(pfx.pfx, pfx.val)
+	}
+	baseDump := base.String()
+
+	want := base.Clone()
+	u := base.BeginUpdate()
+
+	for _, pfx := range randomPrefixes(prng, 200) {
+		if prng.Intn(4) == 0 {
+			want.Delete(pfx.pfx)
+			u.Delete(pfx.pfx)
+			continue
+		}
+		want.Insert(pfx.pfx, pfx.val)
+		u.Insert(pfx.pfx, pfx.val)
+	}
+
+	if u.Len() != 200 {
+		t.Errorf("Updater.Len() = %d, want 200", u.Len())
+	}
+
+	got := u.Commit()
+
+	if got.String() != want.String() || got.Size() != want.Size() {
+		t.Errorf("Updater.Commit, new version differs from sequential Insert and Delete")
+	}
+	if base.String() != baseDump {
+		t.Errorf("Updater.Commit, base table modified")
+	}
+	if err := got.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	// empty batch, next version is based on the last commit
+	if u.Len() != 0 || u.Commit() != got {
+		t.Errorf("Updater.Commit, empty batch must return the current version")
+	}
+
+	u.Delete(mpp("0.0.0.0/0"))
+	u.Insert(mpp("0.0.0.0/0"), 1)
+	if val, ok := u.Commit().Get(mpp("0.0.0.0/0")); !ok || val != 1 {
+		t.Errorf("Updater.Commit, ops not applied in order, got (%d, %v)", val, ok)
+	}
+}
+
+// run with -race
+func TestUpdaterConcurrentReaders(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 1_000)
+
+	tbl := new(Table[int])
+	for _, pfx := range pfxs[:500] {
+		tbl.Insert(pfx.pfx, pfx.val)
+	}
+
+	var current atomic.Pointer[Table[int]]
+	current.Store(tbl)
+
+	var wg sync.WaitGroup
+	var done atomic.Bool
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !done.Load() {
+				for _, pfx := range pfxs {
+					current.Load().Lookup(pfx.pfx.Addr())
+				}
+			}
+		}()
+	}
+
+	u := tbl.BeginUpdate()
+	for i, pfx := range pfxs[500:] {
+		u.Insert(pfx.pfx, pfx.val)
+		u.Delete(pfxs[i].pfx)
+
+		if u.Len() == 20 {
+			current.Store(u.Commit())
+		}
+	}
+	current.Store(u.Commit())
+
+	done.Store(true)
+	wg.Wait()
+
+	if err := current.Load().Validate(); err != nil {
+		t.Error(err)
+	}
+}
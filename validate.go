@@ -0,0 +1,126 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Validate checks the structural invariants of the table and returns an
+// error for the first violation found, e.g. as correctness guard in tests
+// after heavy Insert, Delete and Union churn.
+//
+// Every prefix must have exactly one canonical storage location: as prefix
+// in the stride of a node, as fringe or as leaf, in the child slot matching
+// its address. Leaves must not be fringes in disguise, subtries must not
+// be empty and the cached sizes must match the number of stored prefixes.
+//
+// Validate walks the whole table, it's meant for tests and debugging.
+func (t *Table[V]) Validate() error {
+	if t == nil {
+		return nil
+	}
+
+	seen := make(map[netip.Prefix]struct{}, t.Size())
+
+	count4, err := t.root4.validateRec(stridePath{}, 0, true, seen)
+	if err != nil {
+		return err
+	}
+
+	count6, err := t.root6.validateRec(stridePath{}, 0, false, seen)
+	if err != nil {
+		return err
+	}
+
+	if count4 != t.size4 || count6 != t.size6 {
+		return fmt.Errorf("bart: size mismatch, stored (%d, %d), cached (%d, %d)",
+			count4, count6, t.size4, t.size6)
+	}
+
+	return nil
+}
+
+// validateRec, check the invariants of n and all descendants
+// and return the number of stored prefixes.
+func (n *node[V]) validateRec(path stridePath, depth int, is4 bool, seen map[netip.Prefix]struct{}) (count int, err error) {
+	maxDepth := maxTreeDepth
+	if is4 {
+		maxDepth = 4
+	}
+
+	// register the CIDR at its storage location, duplicates are an error
+	store := func(pfx netip.Prefix, where string) error {
+		if _, ok := seen[pfx]; ok {
+			return fmt.Errorf("bart: %s stored twice, again as %s at depth %d", pfx, where, depth)
+		}
+		seen[pfx] = struct{}{}
+		count++
+		return nil
+	}
+
+	if n.prefixes.Test(0) {
+		return 0, fmt.Errorf("bart: invalid prefix index 0 at depth %d, path %v", depth, path[:depth])
+	}
+
+	for _, idx := range n.prefixes.AsSlice(&[256]uint8{}) {
+		if err = store(cidrFromPath(path, depth, is4, idx), "prefix"); err != nil {
+			return 0, err
+		}
+	}
+
+	for i, addr := range n.children.AsSlice(&[256]uint8{}) {
+		switch kid := n.children.Items[i].(type) {
+		case *node[V]:
+			if depth+1 >= maxDepth {
+				return 0, fmt.Errorf("bart: node below the last stride at depth %d, path %v", depth+1, path[:depth])
+			}
+			if kid.isEmpty() {
+				return 0, fmt.Errorf("bart: empty node at depth %d, path %v, octet %d", depth+1, path[:depth], addr)
+			}
+
+			path[depth] = addr
+			kidCount, err := kid.validateRec(path, depth+1, is4, seen)
+			if err != nil {
+				return 0, err
+			}
+			count += kidCount
+
+		case *fringeNode[V]:
+			if err = store(cidrForFringe(path[:], depth, is4, addr), "fringe"); err != nil {
+				return 0, err
+			}
+
+		case *leafNode[V]:
+			pfx := kid.prefix
+			bits := pfx.Bits()
+
+			if !pfx.IsValid() || pfx != pfx.Masked() || pfx.Addr().Is4() != is4 {
+				return 0, fmt.Errorf("bart: malformed leaf %s at depth %d", pfx, depth)
+			}
+
+			// the leaf must belong to this child slot
+			octets := pfx.Addr().AsSlice()
+			if string(octets[:depth]) != string(path[:depth]) || octets[depth] != addr {
+				return 0, fmt.Errorf("bart: leaf %s in wrong slot, depth %d, path %v, octet %d",
+					pfx, depth, path[:depth], addr)
+			}
+
+			// shorter prefixes belong into the node, fringes into a fringeNode
+			if bits < (depth+1)<<3 || isFringe(depth, bits) {
+				return 0, fmt.Errorf("bart: leaf %s must not be stored as leaf at depth %d", pfx, depth)
+			}
+
+			if err = store(pfx, "leaf"); err != nil {
+				return 0, err
+			}
+
+		default:
+			panic("logic error, wrong node type")
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2025 Karl Gaissmaier
+// SPDX-License-Identifier: MIT
+
+package bart
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+func TestValidateChurn(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewSource(42))
+
+	pfxs := randomPrefixes(prng, 2_000)
+
+	other := new(Table[int])
+	for _, item := range randomPrefixes(prng, 500) {
+		other.Insert(item.pfx, item.val)
+	}
+
+	tbl := new(Table[int])
+	if err := tbl.Validate(); err != nil {
+		t.Fatalf("Validate, empty table: %v", err)
+	}
+
+	for i := 0; i < 20_000; i++ {
+		item := pfxs[prng.Intn(len(pfxs))]
+
+		switch prng.Intn(8) {
+		case 0, 1, 2:
+			tbl.Insert(item.pfx, i)
+		case 3, 4:
+			tbl.Delete(item.pfx)
+		case 5:
+			tbl.Update(item.pfx, func(val int, _ bool) int { return val + 1 })
+		case 6:
+			tbl = tbl.InsertPersist(item.pfx, i)
+		case 7:
+			tbl = tbl.DeletePersist(item.pfx)
+		}
+
+		if i%1_000 == 0 {
+			tbl.Union(other)
+			tbl.DeleteMany([]netip.Prefix{pfxs[0].pfx, pfxs[1].pfx})
+		}
+
+		if i%100 == 0 {
+			if err := tbl.Validate(); err != nil {
+				t.Fatalf("Validate, after %d ops: %v", i, err)
+			}
+		}
+	}
+
+	if err := tbl.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestValidateCorrupt(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		corrupt func(tbl *Table[int])
+	}{
+		{
+			name: "size",
+			corrupt: func(tbl *Table[int]) {
+				tbl.size4++
+			},
+		},
+		{
+			name: "leaf in wrong slot",
+			corrupt: func(tbl *Table[int]) {
+				tbl.root4.children.InsertAt(12, newLeafNode(mpp("13.0.0.0/12"), 3))
+				tbl.size4++
+			},
+		},
+		{
+			name: "fringe as leaf",
+			corrupt: func(tbl *Table[int]) {
+				tbl.root4.children.InsertAt(12, newLeafNode(mpp("12.0.0.0/8"), 3))
+				tbl.size4++
+			},
+		},
+		{
+			name: "empty node",
+			corrupt: func(tbl *Table[int]) {
+				tbl.root4.children.InsertAt(12, new(node[int]))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tbl := new(Table[int])
+		tbl.Insert(mpp("10.0.0.0/8"), 1)
+		tbl.Insert(mpp("10.1.0.0/16"), 2)
+
+		if err := tbl.Validate(); err != nil {
+			t.Fatalf("%s: Validate before corruption: %v", tt.name, err)
+		}
+
+		tt.corrupt(tbl)
+
+		if err := tbl.Validate(); err == nil {
+			t.Errorf("%s: Validate, expected error, got nil", tt.name)
+		}
+	}
+}